@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// ExportBundle returns the whole configuration as a bundle.
+func ExportBundle(c *gin.Context, cfg *oto.Instance) {
+	bundle, err := cfg.ExportBundle(c)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't export bundle", err))
+		return
+	}
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportBundle creates or updates the records of the bundle, and returns the plan of the changes.
+// With ?dry_run=true, nothing is saved.
+func ImportBundle(c *gin.Context, cfg *oto.Instance) {
+	var bundle oto.Bundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	plan, err := cfg.ImportBundle(c, &bundle, c.Query("dry_run") == "true")
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't import bundle", err))
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// ApplyManifest converges the configuration on the YAML manifest of the body, and returns the plan of the changes.
+// With ?dry_run=true, nothing is saved. With ?prune=true, the records missing from the manifest are deleted.
+func ApplyManifest(c *gin.Context, cfg *oto.Instance) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bundle := &oto.Bundle{Version: oto.BundleVersion}
+	if err := oto.ParseManifest(bundle, data, "body"); err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't read manifest", err))
+		return
+	}
+
+	opts := oto.ApplyOptions{DryRun: c.Query("dry_run") == "true", Prune: c.Query("prune") == "true"}
+	plan, err := cfg.Apply(c, bundle, opts)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't apply manifest", err))
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
@@ -1,39 +1,82 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/Bl4omArchie/oto/models"
 	oto "github.com/Bl4omArchie/oto/pkg"
-	"github.com/Bl4omArchie/simple"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator"
 )
 
-func CreateCommand(c *gin.Context, cfg *oto.Instance) {
-	var cmd models.Command
+// commandRequest is the body of POST /cmds. Flags are resolved among the parameters of the executable.
+type commandRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Executable  string   `json:"executable" binding:"required"`
+	Flags       []string `json:"flags"`
+}
 
-	if err := c.ShouldBindJSON(&cmd); err != nil {
+// CreateCommand saves a command of an executable, refused with its diagnostic when its flags don't match the schema.
+func CreateCommand(c *gin.Context, cfg *oto.Instance) {
+	var req commandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	validate := validator.New()
-	if err := validate.Struct(&cmd); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := cfg.AddCommand(c, req.Executable, req.Name, req.Description, req.Flags); err != nil {
+		c.JSON(lookupStatus(err), errorBody("failed to create command", err))
 		return
 	}
 
-	if err := cfg.Database.Create(&cmd).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create command", "details": err.Error()})
+	cmd, err := cfg.Repos.Commands.GetByName(c, req.Name)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("failed to create command", err))
 		return
 	}
-
 	c.JSON(http.StatusOK, cmd)
 }
 
-func GetCommands(c *gin.Context, oto *oto.Instance) {
-	cmds, err := simple.GetTable[models.Command](c, oto.Database, -1)
+// commandLineRequest is the body of POST /cmds/import : a shell command line and the names of its command and job.
+type commandLineRequest struct {
+	Line string `json:"line" binding:"required"`
+	oto.CommandLineOptions
+}
+
+// ImportCommandLine matches a shell command line to a command and a job of an executable, and saves them with "create": true.
+// The proposal is returned with the tokens matching no parameter, and along with the error when they can't be saved.
+func ImportCommandLine(c *gin.Context, cfg *oto.Instance) {
+	var req commandLineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imported, err := cfg.ImportCommandLine(c, req.Line, req.CommandLineOptions)
+	if err != nil {
+		body := errorBody("couldn't import command line", err)
+		if imported != nil {
+			body["import"] = imported
+		}
+		c.JSON(lookupStatus(err), body)
+		return
+	}
+	if imported.Created {
+		c.JSON(http.StatusCreated, imported)
+		return
+	}
+	c.JSON(http.StatusOK, imported)
+}
+
+func GetCommands(execTag string, c *gin.Context, oto *oto.Instance) {
+	exec, err := oto.Repos.Executables.GetByTag(c, execTag)
+	if err != nil {
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get commands": err.Error()})
+		return
+	}
+
+	cmds, err := oto.Repos.Commands.Find(c, models.CommandFilter{ExecutableID: exec.ID})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get commands": err.Error()})
 		return
@@ -42,9 +85,13 @@ func GetCommands(c *gin.Context, oto *oto.Instance) {
 }
 
 func GetCommand(execTag string, cmdName string, c *gin.Context, oto *oto.Instance) {
-	cmd, err := simple.GetRowBy[models.Command](c, oto.Database, "name", cmdName)
+	cmd, err := oto.Repos.Commands.GetByName(c, cmdName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get command": err.Error()})
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get command": err.Error()})
+		return
+	}
+	if cmd.Executable.Tag != execTag {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't get command": fmt.Sprintf("command %s doesn't belong to %s", cmdName, execTag)})
 		return
 	}
 	c.JSON(http.StatusOK, cmd)
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Bl4omArchie/oto/models"
+	"github.com/gin-gonic/gin"
+)
+
+// SetConcurrency saves the concurrency limit in the body with the given setter, such as Instance.SetJobConcurrency.
+func SetConcurrency(name string, set func(context.Context, string, models.ConcurrencyLimit) error, c *gin.Context) {
+	var limit models.ConcurrencyLimit
+
+	if err := c.ShouldBindJSON(&limit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := limit.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := set(c, name, limit); err != nil {
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't set concurrency limit": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, limit)
+}
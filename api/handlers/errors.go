@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Bl4omArchie/oto/models"
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// lookupStatus returns 404 when the record doesn't exist, 422 for an invalid flag combination, catalog or bundle,
+// 400 for an unknown graph format, 409 when a record with unfinished runs or a scheduled job is deleted, 500 otherwise.
+func lookupStatus(err error) int {
+	var invalid *oto.CombinationError
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &invalid), errors.Is(err, oto.ErrInvalidCatalog), errors.Is(err, oto.ErrInvalidBundle),
+		errors.Is(err, oto.ErrInvalidCommandLine), errors.Is(err, oto.ErrUnknownTokens), errors.Is(err, oto.ErrInvalidPack):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, oto.ErrUnknownGraphFormat):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrActiveRuns), errors.Is(err, models.ErrScheduled):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// errorBody returns the body of an error response, with the diagnostic of an invalid flag combination.
+func errorBody(msg string, err error) gin.H {
+	body := gin.H{"error": msg, "details": err.Error()}
+	var invalid *oto.CombinationError
+	if errors.As(err, &invalid) {
+		body["diagnostic"] = invalid.Diagnostic
+	}
+	return body
+}
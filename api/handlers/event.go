@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"io"
+
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// StreamEvents sends the events of the instance, such as pending approvals, as server-sent events.
+func StreamEvents(c *gin.Context, oto *oto.Instance) {
+	events, unsubscribe := oto.Events.Subscribe(16)
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		}
+	})
+}
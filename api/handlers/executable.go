@@ -5,7 +5,6 @@ import (
 
 	"github.com/Bl4omArchie/oto/models"
 	oto "github.com/Bl4omArchie/oto/pkg"
-	"github.com/Bl4omArchie/simple"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 )
@@ -24,7 +23,7 @@ func CreateExecutable(c *gin.Context, cfg *oto.Instance) {
 		return
 	}
 
-	if err := cfg.Database.Create(&executable).Error; err != nil {
+	if err := cfg.Repos.Executables.Create(c, &executable); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create Executable", "details": err.Error()})
 		return
 	}
@@ -33,7 +32,7 @@ func CreateExecutable(c *gin.Context, cfg *oto.Instance) {
 }
 
 func GetExecutables(c *gin.Context, cfg *oto.Instance) {
-	executables, err := simple.GetTable[models.Executable](c, cfg.Database, -1)
+	executables, err := cfg.Repos.Executables.List(c)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get Executables": err.Error()})
 		return
@@ -41,11 +40,122 @@ func GetExecutables(c *gin.Context, cfg *oto.Instance) {
 	c.JSON(http.StatusOK, executables)
 }
 
+// flagsRequest is the body of the endpoints checking a selection of flags.
+type flagsRequest struct {
+	Flags []string `json:"flags"`
+}
+
+// ValidateFlags returns the diagnostic of a combination of flags of the executable, sent as {"flags": [...]}.
+func ValidateFlags(execTag string, c *gin.Context, cfg *oto.Instance) {
+	var req flagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diagnostic, err := cfg.ValidateFlags(c, execTag, req.Flags)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't validate flags", err))
+		return
+	}
+	c.JSON(http.StatusOK, diagnostic)
+}
+
+// CompleteFlags returns the flags which can be added to a partial selection, sent as {"flags": [...]},
+// the flags it requires and the flags it rules out.
+func CompleteFlags(execTag string, c *gin.Context, cfg *oto.Instance) {
+	var req flagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	completion, err := cfg.CompleteFlags(c, execTag, req.Flags)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't complete flags", err))
+		return
+	}
+	c.JSON(http.StatusOK, completion)
+}
+
 func GetExecutable(binTag string, c *gin.Context, cfg *oto.Instance) {
-	executable, err := simple.GetRowBy[models.Executable](c, cfg.Database, "tag", binTag)
+	executable, err := cfg.Repos.Executables.GetByTag(c, binTag)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get Executable": err.Error()})
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get Executable": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, executable)
 }
+
+// versionRequest is the body of the endpoints creating a new version of an executable. Without catalog,
+// the new version has the same parameters.
+type versionRequest struct {
+	Version string                `json:"version" binding:"required"`
+	Path    string                `json:"path"`
+	Catalog []models.ParameterRaw `json:"catalog"`
+}
+
+// CloneExecutable creates a version of the executable with the same parameters.
+func CloneExecutable(execTag string, c *gin.Context, cfg *oto.Instance) {
+	var req versionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exec, err := cfg.CloneExecutable(c, execTag, req.Version, req.Path)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't clone executable", err))
+		return
+	}
+	c.JSON(http.StatusCreated, exec)
+}
+
+// DiffCatalog compares the parameters of the executable with the catalog of a new version, and lists the commands and jobs it breaks.
+func DiffCatalog(execTag string, c *gin.Context, cfg *oto.Instance) {
+	var req versionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	diff, err := cfg.DiffCatalog(c, execTag, req.Version, req.Catalog)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't compare catalogs", err))
+		return
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+// MigrateExecutable creates a new version of the executable and moves to it the commands and jobs the catalog doesn't break.
+func MigrateExecutable(execTag string, c *gin.Context, cfg *oto.Instance) {
+	var req versionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	migration, err := cfg.MigrateExecutable(c, execTag, req.Version, req.Path, req.Catalog)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't migrate executable", err))
+		return
+	}
+	c.JSON(http.StatusOK, migration)
+}
+
+// GetParameterGraph returns the graph of the parameters of the executable, ?format=dot (default) or mermaid,
+// limited to the flags of a command with ?command=.
+func GetParameterGraph(execTag string, c *gin.Context, cfg *oto.Instance) {
+	format := c.DefaultQuery("format", oto.GraphDOT)
+	graph, err := cfg.ParameterGraph(c, execTag, c.Query("command"), format)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't export graph", err))
+		return
+	}
+
+	contentType := "text/vnd.graphviz; charset=utf-8"
+	if format == oto.GraphMermaid {
+		contentType = "text/vnd.mermaid; charset=utf-8"
+	}
+	c.Data(http.StatusOK, contentType, []byte(graph))
+}
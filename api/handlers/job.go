@@ -5,35 +5,40 @@ import (
 
 	"github.com/Bl4omArchie/oto/models"
 	oto "github.com/Bl4omArchie/oto/pkg"
-	"github.com/Bl4omArchie/simple"
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator"
 )
 
-func CreateJob(c *gin.Context, cfg *oto.Instance) {
-	var job models.Job
+// jobRequest is the body of POST /jobs. Values are given to the flags of the command in order, a repeatable flag may appear several times.
+type jobRequest struct {
+	Name       string             `json:"name" binding:"required"`
+	Command    string             `json:"command" binding:"required"`
+	FlagValues []oto.JobFlagValue `json:"flag_values"`
+}
 
-	if err := c.ShouldBindJSON(&job); err != nil {
+// CreateJob saves a job of a command, refused with its diagnostic when a value is given to a flag the command doesn't have
+// or breaks a value rule.
+func CreateJob(c *gin.Context, cfg *oto.Instance) {
+	var req jobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	validate := validator.New()
-	if err := validate.Struct(&job); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := cfg.AddJob(c, req.Command, req.Name, req.FlagValues); err != nil {
+		c.JSON(lookupStatus(err), errorBody("failed to create job", err))
 		return
 	}
 
-	if err := cfg.Database.Create(&job).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job", "details": err.Error()})
+	job, err := cfg.Repos.Jobs.GetByName(c, req.Name)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("failed to create job", err))
 		return
 	}
-
 	c.JSON(http.StatusOK, job)
 }
 
 func GetJobs(c *gin.Context, oto *oto.Instance) {
-	execs, err := simple.GetTable[models.Job](c, oto.Database, -1)
+	execs, err := oto.Repos.Jobs.Find(c, models.JobFilter{})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get commands": err.Error()})
 		return
@@ -42,10 +47,20 @@ func GetJobs(c *gin.Context, oto *oto.Instance) {
 }
 
 func GetJob(jobName string, c *gin.Context, oto *oto.Instance) {
-	jobCmd, err := simple.GetRowBy[models.Job](c, oto.Database, "name", jobName)
+	jobCmd, err := oto.Repos.Jobs.GetByName(c, jobName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get command": err.Error()})
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get command": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, jobCmd)
 }
+
+// GetJobScript returns a POSIX shell script running the job by hand, outside of OTO.
+func GetJobScript(jobName string, c *gin.Context, cfg *oto.Instance) {
+	script, err := cfg.JobScript(c, jobName)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't export job script", err))
+		return
+	}
+	c.Data(http.StatusOK, "text/x-shellscript; charset=utf-8", []byte(script))
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// packRequest is the optional body of POST /packs/:name/install. Empty fields take the last supported version and the path of the pack.
+type packRequest struct {
+	Version string `json:"version"`
+	Path    string `json:"path"`
+}
+
+// GetPacks returns the tool packs embedded in OTO, with the tags of their installed executables.
+func GetPacks(c *gin.Context, cfg *oto.Instance) {
+	packs, err := cfg.ListPacks(c)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't list packs", err))
+		return
+	}
+	c.JSON(http.StatusOK, packs)
+}
+
+// InstallPack creates the executable of a tool pack with its parameters.
+func InstallPack(name string, c *gin.Context, cfg *oto.Instance) {
+	var req packRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	exec, err := cfg.InstallPack(c, name, req.Version, req.Path)
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't install pack", err))
+		return
+	}
+	c.JSON(http.StatusCreated, exec)
+}
@@ -5,13 +5,13 @@ import (
 
 	"github.com/Bl4omArchie/oto/models"
 	oto "github.com/Bl4omArchie/oto/pkg"
-	"github.com/Bl4omArchie/simple"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 )
 
+// CreateParameter saves a parameter given in the catalog format. It is refused when its relations make the schema invalid.
 func CreateParameter(c *gin.Context, cfg *oto.Instance) {
-	var param models.Parameter
+	var param models.ParameterRaw
 
 	if err := c.ShouldBindJSON(&param); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -24,27 +24,39 @@ func CreateParameter(c *gin.Context, cfg *oto.Instance) {
 		return
 	}
 
-	if err := cfg.Database.Create(&param).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create parameter", "details": err.Error()})
+	if err := cfg.AddParameterRaw(c, &param); err != nil {
+		c.JSON(lookupStatus(err), errorBody("failed to create parameter", err))
 		return
 	}
 
 	c.JSON(http.StatusOK, param)
 }
 
-func GetParameters(c *gin.Context, oto *oto.Instance) {
-	execs, err := simple.GetTable[models.Parameter](c, oto.Database, -1)
+func GetParameters(execTag string, c *gin.Context, oto *oto.Instance) {
+	exec, err := oto.Repos.Executables.GetByTag(c, execTag)
+	if err != nil {
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get parameters": err.Error()})
+		return
+	}
+
+	params, err := oto.Repos.Parameters.Find(c, models.ParameterFilter{ExecutableID: exec.ID})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get parameters": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, execs)
+	c.JSON(http.StatusOK, params)
 }
 
 func GetParameter(execTag string, paramName string, c *gin.Context, oto *oto.Instance) {
-	param, err := simple.GetRowBy[models.Parameter](c, oto.Database, execTag, paramName)
+	exec, err := oto.Repos.Executables.GetByTag(c, execTag)
+	if err != nil {
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get parameter": err.Error()})
+		return
+	}
+
+	param, err := oto.Repos.Parameters.GetByFlag(c, exec.ID, paramName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get parameter": err.Error()})
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get parameter": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, param)
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func GetRun(runID string, c *gin.Context, oto *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	run, err := oto.GetRun(c, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't get run": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// GetRunScript returns a POSIX shell script starting the process of the last attempt of the run again.
+func GetRunScript(runID string, c *gin.Context, cfg *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	script, err := cfg.RunScript(c, uint(id))
+	if err != nil {
+		c.JSON(lookupStatus(err), errorBody("couldn't export run script", err))
+		return
+	}
+	c.Data(http.StatusOK, "text/x-shellscript; charset=utf-8", []byte(script))
+}
+
+func GetJobRuns(jobName string, c *gin.Context, oto *oto.Instance) {
+	runs, err := oto.GetJobRuns(c, jobName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get runs": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+func CancelRun(runID string, c *gin.Context, oto *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	if err := oto.CancelRun(c, uint(id)); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error, couldn't cancel run": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"status": "cancelling"})
+}
+
+// StartJobRun starts a run of the job. The `priority` query overrides the priority of the job.
+// A request repeated with the same `Idempotency-Key` header returns the run started the first time.
+func StartJobRun(jobName string, c *gin.Context, cfg *oto.Instance) {
+	opts := oto.RunOptions{IdempotencyKey: c.GetHeader("Idempotency-Key")}
+	if value, ok := c.GetQuery("priority"); ok {
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid priority"})
+			return
+		}
+		opts.Priority = &priority
+	}
+
+	run, err := cfg.StartJobRun(c, jobName, opts)
+	if errors.Is(err, oto.ErrIdempotencyConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error, couldn't start run": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't start run": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/runs/%d", run.ID))
+	c.JSON(http.StatusAccepted, gin.H{"run_id": run.ID, "workflow_id": run.WorkflowID, "status": run.Status})
+}
+
+func GetRunState(runID string, c *gin.Context, oto *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	state, err := oto.GetRunState(c, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't get run": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
+// WaitRun waits for the end of the run, at most for the duration given by the `timeout` query (30s by default).
+// When the run is still in progress, its state is returned with 202.
+func WaitRun(runID string, c *gin.Context, cfg *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	timeout, err := time.ParseDuration(c.DefaultQuery("timeout", "30s"))
+	if err != nil || timeout <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timeout"})
+		return
+	}
+
+	output, err := cfg.WaitRun(c, uint(id), timeout)
+	if errors.Is(err, oto.ErrWaitTimeout) {
+		state, err := cfg.GetRunState(c, uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get run": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, state)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, run failed": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, output)
+}
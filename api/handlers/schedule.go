@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Bl4omArchie/oto/models"
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func CreateSchedule(c *gin.Context, cfg *oto.Instance) {
+	var req models.ScheduleRaw
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := cfg.AddSchedule(c, req.Name, req.Job, req.Spec, req.Priority); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule", "details": err.Error()})
+		return
+	}
+
+	if req.Enabled != nil && !*req.Enabled {
+		if err := cfg.SetScheduleEnabled(c, req.Name, false); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable schedule", "details": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+func GetSchedules(c *gin.Context, oto *oto.Instance) {
+	schedules, err := oto.GetSchedules(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get schedules": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+func SetScheduleEnabled(name string, enabled bool, c *gin.Context, oto *oto.Instance) {
+	if err := oto.SetScheduleEnabled(c, name, enabled); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't update schedule": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"name": name, "enabled": enabled})
+}
+
+func DeleteSchedule(name string, c *gin.Context, oto *oto.Instance) {
+	if err := oto.DeleteSchedule(c, name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't delete schedule": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type workerRequest struct {
+	WorkerID string `json:"worker_id" binding:"required"`
+	oto.WorkerOptions
+}
+
+func CreateWorker(c *gin.Context, cfg *oto.Instance) {
+	var req workerRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := cfg.AddWorker(req.WorkerID, req.WorkerOptions); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "failed to start worker", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+func GetWorkers(c *gin.Context, cfg *oto.Instance) {
+	c.JSON(http.StatusOK, cfg.ListWorkers())
+}
+
+func StopWorker(workerID string, c *gin.Context, cfg *oto.Instance) {
+	if err := cfg.StopWorker(workerID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error, couldn't stop worker": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"worker_id": workerID, "status": oto.WorkerStopped})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Bl4omArchie/oto/models"
+	oto "github.com/Bl4omArchie/oto/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type workflowRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	Nodes       []models.WorkflowNodeRaw `json:"nodes" binding:"required"`
+}
+
+type decisionRequest struct {
+	By      string `json:"by" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+func CreateWorkflow(c *gin.Context, cfg *oto.Instance) {
+	var req workflowRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := cfg.AddWorkflow(c, req.Name, req.Description, req.Nodes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create workflow", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, req)
+}
+
+func GetWorkflows(c *gin.Context, oto *oto.Instance) {
+	workflows, err := oto.Repos.Workflows.List(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't get workflows": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, workflows)
+}
+
+func GetWorkflow(name string, c *gin.Context, oto *oto.Instance) {
+	workflow, err := oto.Repos.Workflows.GetByName(c, name)
+	if err != nil {
+		c.JSON(lookupStatus(err), gin.H{"error, couldn't get workflow": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, workflow)
+}
+
+func StartWorkflowRun(name string, c *gin.Context, oto *oto.Instance) {
+	run, err := oto.StartWorkflowRun(c, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error, couldn't start workflow": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+func GetWorkflowRun(runID string, c *gin.Context, oto *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workflow run id"})
+		return
+	}
+
+	run, err := oto.GetWorkflowRun(c, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error, couldn't get workflow run": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+func ApproveRun(runID string, c *gin.Context, oto *oto.Instance) {
+	decideRun(runID, true, c, oto)
+}
+
+func RejectRun(runID string, c *gin.Context, oto *oto.Instance) {
+	decideRun(runID, false, c, oto)
+}
+
+func decideRun(runID string, approved bool, c *gin.Context, oto *oto.Instance) {
+	id, err := strconv.ParseUint(runID, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workflow run id"})
+		return
+	}
+
+	var req decisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if approved {
+		err = oto.ApproveRun(c, uint(id), req.By, req.Comment)
+	} else {
+		err = oto.RejectRun(c, uint(id), req.By, req.Comment)
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error, couldn't decide": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"approved": approved, "by": req.By})
+}
@@ -13,10 +13,10 @@ func SetupRouter(cfg *oto.Instance) *gin.Engine {
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://127.0.0.1:5500", "http://localhost:5500"},
+		AllowOrigins:     []string{"http://127.0.0.1:5500", "http://localhost:5500", "http://127.0.0.1:2929", "http://localhost:2929"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
 		AllowHeaders:     []string{"Origin", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Location"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -30,12 +30,19 @@ func SetupRouter(cfg *oto.Instance) *gin.Engine {
 		handlers.GetExecutable(value, c, cfg)
 	})
 
+	r.GET("/executables/:execTag/graph", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.GetParameterGraph(value, c, cfg)
+	})
+
 	r.GET("/params/:execTag", func(c *gin.Context) {
-		handlers.GetParameters(c, cfg)
+		value := c.Param("execTag")
+		handlers.GetParameters(value, c, cfg)
 	})
 
 	r.GET("/cmds/:execTag", func(c *gin.Context) {
-		handlers.GetCommands(c, cfg)
+		value := c.Param("execTag")
+		handlers.GetCommands(value, c, cfg)
 	})
 
 	r.GET("/jobs", func(c *gin.Context) {
@@ -59,6 +66,70 @@ func SetupRouter(cfg *oto.Instance) *gin.Engine {
 		handlers.GetJob(value, c, cfg)
 	})
 
+	r.GET("/jobs/:name/runs", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.GetJobRuns(value, c, cfg)
+	})
+
+	r.GET("/jobs/:name/script", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.GetJobScript(value, c, cfg)
+	})
+
+	r.GET("/runs/:id/script", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.GetRunScript(value, c, cfg)
+	})
+
+	r.GET("/runs/:id", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.GetRun(value, c, cfg)
+	})
+
+	r.GET("/runs/:id/status", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.GetRunState(value, c, cfg)
+	})
+
+	r.GET("/runs/:id/wait", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.WaitRun(value, c, cfg)
+	})
+
+	r.GET("/workflows", func(c *gin.Context) {
+		handlers.GetWorkflows(c, cfg)
+	})
+
+	r.GET("/workflows/:name", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.GetWorkflow(value, c, cfg)
+	})
+
+	r.GET("/workflow-runs/:id", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.GetWorkflowRun(value, c, cfg)
+	})
+
+	r.GET("/workers", func(c *gin.Context) {
+		handlers.GetWorkers(c, cfg)
+	})
+
+	r.GET("/schedules", func(c *gin.Context) {
+		handlers.GetSchedules(c, cfg)
+	})
+
+	r.GET("/events", func(c *gin.Context) {
+		handlers.StreamEvents(c, cfg)
+	})
+
+	r.GET("/bundle", func(c *gin.Context) {
+		handlers.ExportBundle(c, cfg)
+	})
+
+	r.GET("/packs", func(c *gin.Context) {
+		handlers.GetPacks(c, cfg)
+	})
+
 	r.GET("/valuetypes", func(c *gin.Context) {
 		handlers.GetValueTypes(c)
 	})
@@ -68,15 +139,129 @@ func SetupRouter(cfg *oto.Instance) *gin.Engine {
 	})
 
 	r.POST("/params", func(c *gin.Context) {
-		handlers.CreateCommand(c, cfg)
+		handlers.CreateParameter(c, cfg)
 	})
 
 	r.POST("/cmds", func(c *gin.Context) {
-		handlers.CreateParameter(c, cfg)
+		handlers.CreateCommand(c, cfg)
+	})
+
+	r.POST("/cmds/import", func(c *gin.Context) {
+		handlers.ImportCommandLine(c, cfg)
+	})
+
+	r.POST("/executables/:execTag/validate", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.ValidateFlags(value, c, cfg)
+	})
+
+	r.POST("/executables/:execTag/complete", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.CompleteFlags(value, c, cfg)
+	})
+
+	r.POST("/executables/:execTag/clone", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.CloneExecutable(value, c, cfg)
+	})
+
+	r.POST("/executables/:execTag/diff", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.DiffCatalog(value, c, cfg)
+	})
+
+	r.POST("/executables/:execTag/migrate", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.MigrateExecutable(value, c, cfg)
+	})
+
+	r.POST("/packs/:name/install", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.InstallPack(value, c, cfg)
+	})
+
+	r.POST("/bundle", func(c *gin.Context) {
+		handlers.ImportBundle(c, cfg)
+	})
+
+	r.POST("/apply", func(c *gin.Context) {
+		handlers.ApplyManifest(c, cfg)
 	})
 
 	r.POST("/jobs", func(c *gin.Context) {
 		handlers.CreateJob(c, cfg)
 	})
+
+	r.POST("/workflows", func(c *gin.Context) {
+		handlers.CreateWorkflow(c, cfg)
+	})
+
+	r.POST("/workflows/:name/runs", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.StartWorkflowRun(value, c, cfg)
+	})
+
+	r.POST("/workflow-runs/:id/approve", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.ApproveRun(value, c, cfg)
+	})
+
+	r.POST("/workflow-runs/:id/reject", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.RejectRun(value, c, cfg)
+	})
+
+	r.POST("/jobs/:name/runs", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.StartJobRun(value, c, cfg)
+	})
+
+	r.POST("/workers", func(c *gin.Context) {
+		handlers.CreateWorker(c, cfg)
+	})
+
+	r.POST("/workers/:id/stop", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.StopWorker(value, c, cfg)
+	})
+
+	r.POST("/runs/:id/cancel", func(c *gin.Context) {
+		value := c.Param("id")
+		handlers.CancelRun(value, c, cfg)
+	})
+
+	r.POST("/schedules", func(c *gin.Context) {
+		handlers.CreateSchedule(c, cfg)
+	})
+
+	r.POST("/schedules/:name/enable", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.SetScheduleEnabled(value, true, c, cfg)
+	})
+
+	r.POST("/schedules/:name/disable", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.SetScheduleEnabled(value, false, c, cfg)
+	})
+
+	r.PUT("/executables/:execTag/concurrency", func(c *gin.Context) {
+		value := c.Param("execTag")
+		handlers.SetConcurrency(value, cfg.SetExecutableConcurrency, c)
+	})
+
+	r.PUT("/cmds/:execTag/:name/concurrency", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.SetConcurrency(value, cfg.SetCommandConcurrency, c)
+	})
+
+	r.PUT("/jobs/:name/concurrency", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.SetConcurrency(value, cfg.SetJobConcurrency, c)
+	})
+
+	r.DELETE("/schedules/:name", func(c *gin.Context) {
+		value := c.Param("name")
+		handlers.DeleteSchedule(value, c, cfg)
+	})
 	return r
 }
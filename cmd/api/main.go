@@ -4,30 +4,78 @@ package main
 import (
 	"fmt"
 	"flag"
+	"context"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Bl4omArchie/oto/api"
 	"github.com/Bl4omArchie/oto/pkg"
 )
 
-func RunAPiServer(host, port string) {
+func RunAPiServer(host, port, workerID string, caps oto.Capabilities, scheduler bool) {
 	cfg, err := oto.NewInstanceOto(".env")
 	if err != nil {
 		fmt.Println(err)
+		return
+	}
+	defer cfg.Close()
+
+	// Optional worker running in the same process as the API
+	if workerID != "" {
+		if err := cfg.AddWorker(workerID, oto.WorkerOptions{Capabilities: caps}); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if scheduler {
+		cfg.StartScheduler()
 	}
 
     r := api.SetupRouter(cfg)
-    r.Run(fmt.Sprintf("%s:%s", host, port))
+	srv := &http.Server{Addr: fmt.Sprintf("%s:%s", host, port), Handler: r}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Println(err)
+			stop()
+		}
+	}()
+
+	// On shutdown, stop accepting requests then drain the workers
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	srv.Shutdown(shutdownCtx)
 }
 
 
 func main() {
     var host string    
     var port string      
+	var workerID string
+	var executables string
+	var root bool
+	var scheduler bool
  
     flag.StringVar(&host, "h", "0.0.0.0", "Specify host. Default is localhost")
     flag.StringVar(&port, "p", "8080", "Specify port number. Default is 8080")
+	flag.StringVar(&workerID, "worker", "", "Start a worker with the given ID alongside the API. Default is no worker")
+	flag.StringVar(&executables, "executables", "", "Comma separated tags of the executables the worker can run")
+	flag.BoolVar(&root, "root", false, "Advertise root availability for the worker")
+	flag.BoolVar(&scheduler, "scheduler", true, "Trigger the schedules from this process. Default is true")
 	
 	flag.Parse()
 
-	RunAPiServer(host, port)
+	caps := oto.Capabilities{Root: root}
+	if executables != "" {
+		caps.Executables = strings.Split(executables, ",")
+	}
+	RunAPiServer(host, port, workerID, caps, scheduler)
 }
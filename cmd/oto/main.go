@@ -0,0 +1,573 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+	"github.com/Bl4omArchie/oto/pkg"
+)
+
+const usage = `Usage: oto [-env file] <command> [arguments]
+
+Commands:
+  run [-detach] [-priority n] [-key k] <job>
+                               run a job and print its output, Ctrl+C cancels the run
+  status <run-id>              print the state of a run
+  wait [-timeout d] <run-id>   wait for the end of a run and print its output
+  cancel <run-id>              cancel a run
+  script job|run <name|run-id> print a shell script running the job, or the last attempt of the run, by hand
+  schedule add <name> <job> <spec> [priority]
+                               run a job each time the cron spec is due, e.g. "0 3 * * *" or "@every 1h"
+  schedule list                print every schedule
+  schedule enable|disable|rm <name>
+                               resume, pause or remove a schedule
+  limit job|command|executable <name> <max-runs> [queue|skip|cancel-previous]
+                               limit the runs in progress at once, 0 removes the limit. Default behavior is queue
+  flags <executable> [flag...] list the flags which can be added to the selection, the ones it requires and the ones it rules out
+  graph [-format dot|mermaid] [-command c] <executable>
+                               print the requirements and interferences of the parameters, e.g. | dot -Tsvg > nmap.svg
+  pack list                    print the tool packs embedded in oto, with their installed executables
+  pack install [-path p] <pack> [version]
+                               create the executable of a tool pack with its parameters, the last supported version by default
+  catalog clone [-path p] <executable> <version>
+                               create the version of an executable with the same parameters
+  catalog diff <executable> <version> <file>
+                               compare the parameters with the catalog file of a new version, and list the commands and jobs it breaks
+  catalog migrate [-path p] <executable> <version> [file]
+                               create the new version and move to it the commands and jobs it doesn't break
+  import-cmd [-executable tag] [-command c] [-job j] [-create] <command line>
+                               match a shell command line to a command and a job, -create saves them
+  bundle export [file]         write the whole configuration, to stdout without file
+  bundle import [-dry-run] <file>
+                               create or update the records of a bundle and print the changes, -dry-run only prints them
+  apply [-prune] [-dry-run] <path>...
+                               converge on the YAML manifests of the files and directories, -prune deletes the records missing from them
+  plan [-prune] <path>...      print the changes apply would make
+  manifests [file]             write the whole configuration as a YAML manifest, to stdout without file
+  serve                        trigger the schedules and, with the local runner, execute the queued runs until interrupted
+`
+
+// runJob starts a run of the job through the runner of the instance.
+// Unless detached, it waits for the output and cancels the run on SIGINT or SIGTERM.
+func runJob(instance *oto.Instance, args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	detach := fs.Bool("detach", false, "Print the run ID and return without waiting. Not available with the local runner")
+	var opts oto.RunOptions
+	fs.Func("priority", "Override the priority of the job, higher runs first", func(value string) error {
+		priority, err := strconv.Atoi(value)
+		opts.Priority = &priority
+		return err
+	})
+	fs.StringVar(&opts.IdempotencyKey, "key", "", "Idempotency key, repeating the command with the same key returns the same run")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("run expects the name of a job")
+	}
+	if *detach && instance.Config.Runner == oto.RunnerLocal {
+		return errors.New("the local runner stops its runs with the process, -detach requires the temporal runner")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	run, err := instance.StartJobRun(ctx, fs.Arg(0), opts)
+	if err != nil {
+		return err
+	}
+	if *detach {
+		fmt.Println(run.ID)
+		return nil
+	}
+
+	output, err := instance.WaitRun(ctx, run.ID, 0)
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "cancelling run %d\n", run.ID)
+		if err := instance.CancelRun(context.Background(), run.ID); err != nil {
+			return err
+		}
+		output, err = instance.WaitRun(context.Background(), run.ID, 0)
+	}
+	printOutput(output)
+	return err
+}
+
+func runStatus(instance *oto.Instance, args []string) error {
+	runID, err := parseRunID(args)
+	if err != nil {
+		return err
+	}
+
+	state, err := instance.GetRunState(context.Background(), runID)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(state)
+}
+
+func runWait(instance *oto.Instance, args []string) error {
+	fs := flag.NewFlagSet("wait", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 0, "Give up after this duration. Default is no timeout")
+	fs.Parse(args)
+
+	runID, err := parseRunID(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	output, err := instance.WaitRun(context.Background(), runID, *timeout)
+	printOutput(output)
+	return err
+}
+
+func runCancel(instance *oto.Instance, args []string) error {
+	runID, err := parseRunID(args)
+	if err != nil {
+		return err
+	}
+	return instance.CancelRun(context.Background(), runID)
+}
+
+// runScript prints the shell script of a job, or of the last attempt of a run.
+func runScript(instance *oto.Instance, args []string) error {
+	if len(args) != 2 {
+		return errors.New("script expects job <name> or run <run-id>")
+	}
+
+	var script string
+	var err error
+	switch args[0] {
+	case "job":
+		script, err = instance.JobScript(context.Background(), args[1])
+	case "run":
+		var runID uint
+		if runID, err = parseRunID(args[1:]); err == nil {
+			script, err = instance.RunScript(context.Background(), runID)
+		}
+	default:
+		return fmt.Errorf("invalid script command, see oto -h")
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Print(script)
+	return nil
+}
+
+func runSchedule(instance *oto.Instance, args []string) error {
+	ctx := context.Background()
+	if len(args) == 0 {
+		return errors.New("schedule expects add, list, enable, disable or rm")
+	}
+
+	switch action, args := args[0], args[1:]; {
+	case action == "add" && len(args) == 3:
+		return instance.AddSchedule(ctx, args[0], args[1], args[2], nil)
+	case action == "add" && len(args) == 4:
+		priority, err := strconv.Atoi(args[3])
+		if err != nil {
+			return fmt.Errorf("invalid priority %q", args[3])
+		}
+		return instance.AddSchedule(ctx, args[0], args[1], args[2], &priority)
+	case action == "list" && len(args) == 0:
+		schedules, err := instance.GetSchedules(ctx)
+		if err != nil {
+			return err
+		}
+		for _, schedule := range schedules {
+			job := "-"
+			if schedule.Job != nil {
+				job = schedule.Job.Name
+			}
+			fmt.Printf("%s\t%s\t%s\tenabled=%t\tnext=%s\n", schedule.Name, job, schedule.Spec, schedule.Enabled, schedule.NextRunAt.Format(time.RFC3339))
+		}
+		return nil
+	case action == "enable" && len(args) == 1:
+		return instance.SetScheduleEnabled(ctx, args[0], true)
+	case action == "disable" && len(args) == 1:
+		return instance.SetScheduleEnabled(ctx, args[0], false)
+	case action == "rm" && len(args) == 1:
+		return instance.DeleteSchedule(ctx, args[0])
+	default:
+		return fmt.Errorf("invalid schedule command, see oto -h")
+	}
+}
+
+func runLimit(instance *oto.Instance, args []string) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("limit expects a kind, a name, a number of runs and optionally a behavior")
+	}
+
+	maxRuns, err := strconv.Atoi(args[2])
+	if err != nil {
+		return fmt.Errorf("invalid number of runs %q", args[2])
+	}
+	limit := models.ConcurrencyLimit{MaxRuns: maxRuns}
+	if len(args) == 4 {
+		limit.OnLimit = models.LimitBehavior(args[3])
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "job":
+		return instance.SetJobConcurrency(ctx, args[1], limit)
+	case "command":
+		return instance.SetCommandConcurrency(ctx, args[1], limit)
+	case "executable":
+		return instance.SetExecutableConcurrency(ctx, args[1], limit)
+	default:
+		return fmt.Errorf("unknown kind %q, expected job, command or executable", args[0])
+	}
+}
+
+// runFlags prints the completion of a selection of flags, to build a valid command step by step.
+func runFlags(instance *oto.Instance, args []string) error {
+	if len(args) == 0 {
+		return errors.New("flags expects the tag of an executable")
+	}
+
+	completion, err := instance.CompleteFlags(context.Background(), args[0], args[1:])
+	if err != nil {
+		return err
+	}
+
+	for _, flag := range completion.Required {
+		fmt.Printf("required\t%s\t%s\n", flag.Flag, strings.Join(flag.Path, " -> "))
+	}
+	for _, flag := range completion.Addable {
+		fmt.Printf("addable\t%s\n", flag)
+	}
+	for _, flag := range completion.Excluded {
+		fmt.Printf("excluded\t%s\t%s\n", flag.Flag, flag.Reason)
+	}
+	for _, group := range completion.Groups {
+		fmt.Printf("group\t%s\t%s\n", group.Group, strings.Join(group.Members, ", "))
+	}
+	for _, flag := range completion.Unknown {
+		fmt.Printf("unknown\t%s\n", flag)
+	}
+	return nil
+}
+
+// runGraph prints the graph of the parameters of an executable, to render with Graphviz or Mermaid.
+func runGraph(instance *oto.Instance, args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	format := fs.String("format", oto.GraphDOT, "Format of the graph, dot or mermaid")
+	command := fs.String("command", "", "Limit the graph to the flags of this command and the flags they require")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("graph expects the tag of an executable")
+	}
+
+	graph, err := instance.ParameterGraph(context.Background(), fs.Arg(0), *command, *format)
+	if err != nil {
+		return err
+	}
+	fmt.Print(graph)
+	return nil
+}
+
+// runPack lists the embedded tool packs, or installs one of them.
+func runPack(instance *oto.Instance, args []string) error {
+	if len(args) == 0 {
+		return errors.New("pack expects list or install")
+	}
+
+	fs := flag.NewFlagSet("pack "+args[0], flag.ExitOnError)
+	path := fs.String("path", "", "Path of the executable. Default is the path of the pack")
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	switch action, args := args[0], fs.Args(); {
+	case action == "list" && len(args) == 0:
+		packs, err := instance.ListPacks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range packs {
+			fmt.Printf("%s\t%s\t%s %s\t%s\t%s\n", p.Name, p.Version, p.Executable, strings.Join(p.Versions, ", "), p.Author, strings.Join(p.Installed, ", "))
+		}
+		return nil
+	case action == "install" && (len(args) == 1 || len(args) == 2):
+		version := ""
+		if len(args) == 2 {
+			version = args[1]
+		}
+		exec, err := instance.InstallPack(ctx, args[0], version, *path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(exec.Tag)
+		return nil
+	default:
+		return fmt.Errorf("invalid pack command, see oto -h")
+	}
+}
+
+// runCatalog clones, compares or migrates the parameter catalog of an executable to a new version.
+func runCatalog(instance *oto.Instance, args []string) error {
+	if len(args) == 0 {
+		return errors.New("catalog expects clone, diff or migrate")
+	}
+
+	fs := flag.NewFlagSet("catalog "+args[0], flag.ExitOnError)
+	path := fs.String("path", "", "Path of the new version. Default is the path of the executable")
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	switch action, args := args[0], fs.Args(); {
+	case action == "clone" && len(args) == 2:
+		exec, err := instance.CloneExecutable(ctx, args[0], args[1], *path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(exec.Tag)
+		return nil
+	case action == "diff" && len(args) == 3:
+		catalog, err := oto.LoadCatalog(args[2])
+		if err != nil {
+			return err
+		}
+		diff, err := instance.DiffCatalog(ctx, args[0], args[1], catalog)
+		if err != nil {
+			return err
+		}
+		return printJSON(diff)
+	case action == "migrate" && (len(args) == 2 || len(args) == 3):
+		var catalog []models.ParameterRaw
+		if len(args) == 3 {
+			var err error
+			if catalog, err = oto.LoadCatalog(args[2]); err != nil {
+				return err
+			}
+		}
+		migration, err := instance.MigrateExecutable(ctx, args[0], args[1], *path, catalog)
+		if err != nil {
+			return err
+		}
+		return printJSON(migration)
+	default:
+		return fmt.Errorf("invalid catalog command, see oto -h")
+	}
+}
+
+// runImportCommand prints the command and the job matching a shell command line, and saves them with -create.
+func runImportCommand(instance *oto.Instance, args []string) error {
+	fs := flag.NewFlagSet("import-cmd", flag.ExitOnError)
+	var opts oto.CommandLineOptions
+	fs.StringVar(&opts.Executable, "executable", "", "Tag of the executable, when the program matches several versions")
+	fs.StringVar(&opts.Command, "command", "", "Name of the command. Default is a command with the same flags, or a name made of the executable and the first flag")
+	fs.StringVar(&opts.Job, "job", "", "Name of the job. Default is the name of the command followed by -job")
+	fs.BoolVar(&opts.Create, "create", false, "Save the command and the job")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("import-cmd expects a command line, e.g. 'openssl genpkey -algorithm RSA -out key.pem'")
+	}
+
+	imported, err := instance.ImportCommandLine(context.Background(), strings.Join(fs.Args(), " "), opts)
+	if imported != nil {
+		if err := printJSON(imported); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// runBundle exports the configuration, or imports a bundle and prints its plan.
+func runBundle(instance *oto.Instance, args []string) error {
+	if len(args) == 0 {
+		return errors.New("bundle expects export or import")
+	}
+
+	fs := flag.NewFlagSet("bundle "+args[0], flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Print the changes without saving them")
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	switch action, args := args[0], fs.Args(); {
+	case action == "export" && len(args) <= 1:
+		bundle, err := instance.ExportBundle(ctx)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			return printJSON(bundle)
+		}
+		data, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(args[0], data, 0o644)
+	case action == "import" && len(args) == 1:
+		bundle, err := oto.LoadBundle(args[0])
+		if err != nil {
+			return err
+		}
+		plan, err := instance.ImportBundle(ctx, bundle, *dryRun)
+		if err != nil {
+			return err
+		}
+		fmt.Print(plan)
+		return nil
+	default:
+		return fmt.Errorf("invalid bundle command, see oto -h")
+	}
+}
+
+// runApply converges the configuration on YAML manifests and prints the plan.
+func runApply(instance *oto.Instance, args []string) error {
+	return applyManifests(instance, "apply", args)
+}
+
+// runPlan prints the plan of apply, without saving it.
+func runPlan(instance *oto.Instance, args []string) error {
+	return applyManifests(instance, "plan", args)
+}
+
+func applyManifests(instance *oto.Instance, name string, args []string) error {
+	dryRun := name == "plan"
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	opts := oto.ApplyOptions{DryRun: dryRun}
+	fs.BoolVar(&opts.Prune, "prune", false, "Delete the schedules, workflows, jobs, commands and executables missing from the manifests")
+	if !dryRun {
+		fs.BoolVar(&opts.DryRun, "dry-run", false, "Print the changes without saving them")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("expected manifest files or directories")
+	}
+	bundle, err := oto.LoadManifests(fs.Args()...)
+	if err != nil {
+		return err
+	}
+	plan, err := instance.Apply(context.Background(), bundle, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan)
+	return nil
+}
+
+// runManifests writes the configuration as a YAML manifest, to start managing it in git.
+func runManifests(instance *oto.Instance, args []string) error {
+	if len(args) > 1 {
+		return errors.New("manifests expects at most a file")
+	}
+	bundle, err := instance.ExportBundle(context.Background())
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return oto.WriteManifests(os.Stdout, bundle)
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := oto.WriteManifests(file, bundle); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runServe keeps the instance alive : the scheduler triggers the schedules and the local runner claims the queued runs.
+func runServe(instance *oto.Instance, args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	instance.StartScheduler()
+	<-ctx.Done()
+	return nil
+}
+
+func parseRunID(args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a run ID")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid run ID %q", args[0])
+	}
+	return uint(id), nil
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func printOutput(output *oto.JobOutput) {
+	if output == nil {
+		return
+	}
+	fmt.Fprint(os.Stdout, output.Stdout)
+	fmt.Fprint(os.Stderr, output.Stderr)
+}
+
+func main() {
+	var envPath string
+
+	flag.StringVar(&envPath, "env", ".env", "Specify the env file. Default is .env")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	commands := map[string]func(*oto.Instance, []string) error{
+		"run":        runJob,
+		"status":     runStatus,
+		"wait":       runWait,
+		"cancel":     runCancel,
+		"script":     runScript,
+		"schedule":   runSchedule,
+		"limit":      runLimit,
+		"flags":      runFlags,
+		"graph":      runGraph,
+		"pack":       runPack,
+		"catalog":    runCatalog,
+		"import-cmd": runImportCommand,
+		"bundle":     runBundle,
+		"apply":      runApply,
+		"plan":       runPlan,
+		"manifests":  runManifests,
+		"serve":      runServe,
+	}
+	command, ok := commands[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	instance, err := oto.NewInstanceOto(envPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	err = command(instance, flag.Args()[1:])
+	instance.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -18,59 +18,23 @@ func full_data() error {
 		return err
 	}
 
-	err = instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool")
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	err = instance.AddExecutable("openssl", "3.5.3", "/usr/bin/openssl", "cryptographic tool")
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	err = instance.AddExecutable("masscan", "1.3.9", "/usr/bin/masscan", "scanning tool")
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	s1, err := instance.AddExecutableSchema(ctx, "nmap - 7.98")
-	if err != nil {
-		return err
-	}
-	s2, err := instance.AddExecutableSchema(ctx, "openssl - 3.5.3")
-	if err != nil {
-		return err
-	}
-	s3, err := instance.AddExecutableSchema(ctx, "masscan - 1.3.9")
-	if err != nil {
-		return err
+	// The catalogs are embedded tool packs, nothing is read from the working directory
+	for _, pack := range []string{"nmap", "openssl", "masscan"} {
+		if _, err := instance.InstallPack(ctx, pack, "", ""); err != nil {
+			fmt.Println(err)
+		}
 	}
 
-	err = instance.ImportParameters(ctx, "data/nmap.json", s1)
-	if err != nil {
-		return err
-	}
-
-	err = instance.ImportParameters(ctx, "data/openssl.json", s2)
-	if err != nil {
-		return err
-	}
-
-	err = instance.ImportParameters(ctx, "data/masscan.json", s3)
-	if err != nil {
-		return err
-	}
-
-	err = instance.AddCommand(ctx, "openssl - 3.5.3", "GenRSA", "Generate an rsa keypair", []string{"genpkey", "-algorithm", "-pkeyopt", "-out"}, s2)
+	err = instance.AddCommand(ctx, "openssl - 3.5.3", "GenRSA", "Generate an rsa keypair", []string{"genpkey", "-algorithm", "-pkeyopt", "-out"})
 	if err != nil {
 		return err
 	}
 	
-	if err := instance.AddJob(ctx, "GenRSA", "GenRSA-2048", map[string]string{"genpkey": "", "-algorithm": "RSA", "-pkeyopt": "rsa_keygen_bits:2048", "-out": "key.pem"}); err != nil {
+	if err := instance.AddJob(ctx, "GenRSA", "GenRSA-2048", []oto.JobFlagValue{{Flag: "genpkey"}, {Flag: "-algorithm", Value: "RSA"}, {Flag: "-pkeyopt", Value: "rsa_keygen_bits:2048"}, {Flag: "-out", Value: "key.pem"}}); err != nil {
 		return err
 	}
 
-	out, err := instance.RunJobDemo(ctx, "GenRSA-2048")
+	out, err := instance.RunJob(ctx, "GenRSA-2048")
 	if err != nil {
 		return err
 	}
@@ -88,30 +52,21 @@ func launch_demo() error {
 		return err
 	}
 
-	err = instance.AddExecutable("openssl", "3.5.3", "/usr/bin/openssl", "cryptographic tool")
+	_, err = instance.InstallPack(ctx, "openssl", "", "")
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	s2, err := instance.AddExecutableSchema(ctx, "openssl - 3.5.3")
-	if err != nil {
-		return err
-	}
-	err = instance.ImportParameters(ctx, "data/openssl.json", s2)
-	if err != nil {
-		return err
-	}
-
-	err = instance.AddCommand(ctx, "openssl - 3.5.3", "GenRSA", "Generate an rsa keypair", []string{"genpkey", "-algorithm", "-pkeyopt", "-out"}, s2)
+	err = instance.AddCommand(ctx, "openssl - 3.5.3", "GenRSA", "Generate an rsa keypair", []string{"genpkey", "-algorithm", "-pkeyopt", "-out"})
 	if err != nil {
 		return err
 	}
 
-	if err := instance.AddJob(ctx, "GenRSA", "GenRSA-2048", map[string]string{"genpkey": "", "-algorithm": "RSA", "-pkeyopt": "rsa_keygen_bits:2048", "-out": "key.pem"}); err != nil {
+	if err := instance.AddJob(ctx, "GenRSA", "GenRSA-2048", []oto.JobFlagValue{{Flag: "genpkey"}, {Flag: "-algorithm", Value: "RSA"}, {Flag: "-pkeyopt", Value: "rsa_keygen_bits:2048"}, {Flag: "-out", Value: "key.pem"}}); err != nil {
 		return err
 	}
 
-	out, err := instance.RunJobDemo(ctx, "GenRSA-2048")
+	out, err := instance.RunJob(ctx, "GenRSA-2048")
 	if err != nil {
 		return err
 	}
@@ -128,7 +83,7 @@ func test() error {
 		return err
 	}
 
-	out, err := instance.RunJobDemo(ctx, "GenRSA-2048")
+	out, err := instance.RunJob(ctx, "GenRSA-2048")
 	if err != nil {
 		return err
 	}
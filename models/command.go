@@ -9,12 +9,14 @@ import (
 
 type Command struct {
 	gorm.Model
-	Name         string      `gorm:"unique;not null"`
-	Description  string      `gorm:"type:text"`
-	ExecutableID int         `gorm:"not null"`
-	Executable   Executable  `gorm:"foreignKey:ExecutableID"`
-	RequiresRoot bool        `gorm:"not null"`
-	Parameters   []Parameter `gorm:"many2many:command_parameters"`
+	Name         string           `gorm:"unique;not null"`
+	Description  string           `gorm:"type:text"`
+	ExecutableID int              `gorm:"not null"`
+	Executable   Executable       `gorm:"foreignKey:ExecutableID"`
+	RequiresRoot bool             `gorm:"not null"`
+	Parameters   []Parameter      `gorm:"many2many:command_parameters"`
+	Retry        RetryPolicy      `gorm:"embedded;embeddedPrefix:retry_"`
+	Concurrency  ConcurrencyLimit `gorm:"embedded;embeddedPrefix:concurrency_"`
 }
 
 func NewCommand(cmdName, description string, exec *Executable, flags []Parameter) *Command {
@@ -27,32 +29,84 @@ func NewCommand(cmdName, description string, exec *Executable, flags []Parameter
 	}
 }
 
-// FetchCommand returns the first command corresponding to the given column and value.
-func FetchCommand(ctx context.Context, db *gorm.DB, column string, value any) (*Command, error) {
-	var cmd Command
+type gormCommands struct {
+	db *gorm.DB
+}
 
-	err := db.WithContext(ctx).
-		Preload("Parameters").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		First(&cmd).Error
-	if err != nil {
-		return nil, err
+func (r *gormCommands) preload(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Preload("Executable").
+		Preload("Parameters")
+}
+
+func (r *gormCommands) Get(ctx context.Context, id uint) (*Command, error) {
+	var cmd Command
+	if err := r.preload(ctx).First(&cmd, id).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find command %d: %w", id, err)
 	}
+	return &cmd, nil
+}
 
+func (r *gormCommands) GetByName(ctx context.Context, name string) (*Command, error) {
+	var cmd Command
+	if err := r.preload(ctx).Where("name = ?", name).First(&cmd).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find command %s: %w", name, err)
+	}
 	return &cmd, nil
 }
 
-// FetchCommands returns every commands corresponding to the given column and value.
-func FetchCommands(ctx context.Context, db *gorm.DB, column string, value any) ([]Command, error) {
+func (r *gormCommands) Find(ctx context.Context, filter CommandFilter) ([]Command, error) {
 	var cmds []Command
 
-	err := db.WithContext(ctx).
-		Preload("Parameter").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		Find(&cmds).Error
-	if err != nil {
+	query := r.preload(ctx).Order("id")
+	if filter.ExecutableID != 0 {
+		query = query.Where("executable_id = ?", filter.ExecutableID)
+	}
+	if err := query.Find(&cmds).Error; err != nil {
 		return nil, err
 	}
-
 	return cmds, nil
 }
+
+func (r *gormCommands) Create(ctx context.Context, cmd *Command) error {
+	return r.db.WithContext(ctx).Create(cmd).Error
+}
+
+func (r *gormCommands) SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error {
+	cmd := &Command{Retry: policy}
+	cmd.ID = id
+	return r.db.WithContext(ctx).Model(cmd).Select(RetryColumns).Updates(cmd).Error
+}
+
+func (r *gormCommands) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	cmd := &Command{Concurrency: limit}
+	cmd.ID = id
+	return r.db.WithContext(ctx).Model(cmd).Select(ConcurrencyColumns).Updates(cmd).Error
+}
+
+func (r *gormCommands) Update(ctx context.Context, cmd *Command) error {
+	db := r.db.WithContext(ctx)
+	if err := updateColumns(db, cmd); err != nil {
+		return err
+	}
+	return replaceAssociation(db, cmd, "Parameters", cmd.Parameters)
+}
+
+func (r *gormCommands) Rebind(ctx context.Context, id uint, exec *Executable, params []Parameter) error {
+	cmd := &Command{}
+	cmd.ID = id
+	db := r.db.WithContext(ctx)
+	if err := db.Model(cmd).Update("executable_id", exec.ID).Error; err != nil {
+		return err
+	}
+	return db.Model(cmd).Association("Parameters").Replace(params)
+}
+
+func (r *gormCommands) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM command_parameters WHERE command_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tombstone(tx, &Command{}, id, "name")
+	})
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LimitBehavior tells what happens to a new run when a concurrency limit is reached.
+type LimitBehavior string
+
+const (
+	// LimitQueue makes the new run wait for a free slot.
+	LimitQueue LimitBehavior = "queue"
+	// LimitSkip finishes the new run as skipped, without executing it.
+	LimitSkip LimitBehavior = "skip"
+	// LimitCancelPrevious cancels the oldest run in progress, then the new run takes its slot.
+	LimitCancelPrevious LimitBehavior = "cancel-previous"
+)
+
+// ConcurrencyLimit caps the number of runs in progress at once. A zero MaxRuns means no limit.
+// Limits are set on jobs, commands and executables, and globally with OTO_MAX_RUNS : a run must fit in all of them.
+type ConcurrencyLimit struct {
+	MaxRuns int           `json:"max_runs"`
+	OnLimit LimitBehavior `json:"on_limit"`
+}
+
+// ConcurrencyColumns are the columns of a ConcurrencyLimit embedded in an Executable, a Command or a Job.
+var ConcurrencyColumns = []string{"concurrency_max_runs", "concurrency_on_limit"}
+
+// Behavior returns OnLimit, LimitQueue when unset.
+func (l ConcurrencyLimit) Behavior() LimitBehavior {
+	if l.OnLimit == "" {
+		return LimitQueue
+	}
+	return l.OnLimit
+}
+
+func (l ConcurrencyLimit) Validate() error {
+	if l.MaxRuns < 0 {
+		return fmt.Errorf("max runs can't be negative, got %d", l.MaxRuns)
+	}
+	switch l.Behavior() {
+	case LimitQueue, LimitSkip, LimitCancelPrevious:
+		return nil
+	}
+	return fmt.Errorf("unknown limit behavior %q, expected %s, %s or %s", l.OnLimit, LimitQueue, LimitSkip, LimitCancelPrevious)
+}
+
+// ScopedLimit is a concurrency limit and the scope of the runs it counts.
+type ScopedLimit struct {
+	Scope string
+	Limit ConcurrencyLimit
+}
+
+// GlobalScope counts every run.
+const GlobalScope = "global"
+
+func ExecutableScope(id uint) string { return fmt.Sprintf("executable:%d", id) }
+func CommandScope(id uint) string    { return fmt.Sprintf("command:%d", id) }
+func JobScope(id uint) string        { return fmt.Sprintf("job:%d", id) }
+
+// ConcurrencyLimits returns the limits a run of the job must fit in, from the widest scope to the job itself.
+// The command and its executable must be loaded.
+func (j *Job) ConcurrencyLimits(global ConcurrencyLimit) []ScopedLimit {
+	limits := []ScopedLimit{{GlobalScope, global}}
+	if j.Command != nil {
+		limits = append(limits,
+			ScopedLimit{ExecutableScope(j.Command.Executable.ID), j.Command.Executable.Concurrency},
+			ScopedLimit{CommandScope(j.Command.ID), j.Command.Concurrency})
+	}
+	limits = append(limits, ScopedLimit{JobScope(j.ID), j.Concurrency})
+
+	set := limits[:0]
+	for _, l := range limits {
+		if l.Limit.MaxRuns > 0 {
+			set = append(set, l)
+		}
+	}
+	return set
+}
+
+// RunLease holds one of the MaxRuns slots of a scope for a run in progress.
+// The unique slot index is what enforces the limit across every worker sharing the database.
+// The holder renews ExpiresAt while it runs, so the slots of a crashed worker are freed once expired.
+type RunLease struct {
+	ID        uint      `gorm:"primarykey"`
+	Scope     string    `gorm:"not null;uniqueIndex:uid_lease_slot"`
+	Slot      int       `gorm:"not null;uniqueIndex:uid_lease_slot"`
+	RunID     int       `gorm:"not null;index"`
+	Revoked   bool      `gorm:"not null"` // set by a newer run with LimitCancelPrevious, the holder cancels its run
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
@@ -4,17 +4,17 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/Bl4omArchie/simple"
 	"gorm.io/gorm"
 )
 
 type Executable struct {
 	gorm.Model
-	Tag         string `gorm:"unique; not null type:string"`
-	Name        string `gorm:"not null type:string"`
-	Version     string `gorm:"not null type:string"`
-	Path        string `gorm:"not null type:string"`
-	Description string `gorm:"not null type:string"`
+	Tag         string           `gorm:"unique; not null type:string"`
+	Name        string           `gorm:"not null type:string"`
+	Version     string           `gorm:"not null type:string"`
+	Path        string           `gorm:"not null type:string"`
+	Description string           `gorm:"not null type:string"`
+	Concurrency ConcurrencyLimit `gorm:"embedded;embeddedPrefix:concurrency_"`
 }
 
 func NewExecutable(name, version, path, description string) *Executable {
@@ -28,8 +28,61 @@ func NewExecutable(name, version, path, description string) *Executable {
 	}
 }
 
-func FetchExecutable(ctx context.Context, db *gorm.DB, column string, tag any) (*Executable, error) {
-	return simple.GetRowBy[Executable](ctx, db, column, tag)
+type gormExecutables struct {
+	db *gorm.DB
+}
+
+func (r *gormExecutables) Get(ctx context.Context, id uint) (*Executable, error) {
+	var exec Executable
+	if err := r.db.WithContext(ctx).First(&exec, id).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find executable %d: %w", id, err)
+	}
+	return &exec, nil
+}
+
+func (r *gormExecutables) GetByTag(ctx context.Context, tag string) (*Executable, error) {
+	var exec Executable
+	if err := r.db.WithContext(ctx).Where("tag = ?", tag).First(&exec).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find executable %s: %w", tag, err)
+	}
+	return &exec, nil
+}
+
+func (r *gormExecutables) List(ctx context.Context) ([]Executable, error) {
+	var execs []Executable
+	if err := r.db.WithContext(ctx).Order("id").Find(&execs).Error; err != nil {
+		return nil, err
+	}
+	return execs, nil
+}
+
+func (r *gormExecutables) Create(ctx context.Context, exec *Executable) error {
+	return r.db.WithContext(ctx).Create(exec).Error
+}
+
+func (r *gormExecutables) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	exec := &Executable{Concurrency: limit}
+	exec.ID = id
+	return r.db.WithContext(ctx).Model(exec).Select(ConcurrencyColumns).Updates(exec).Error
+}
+
+func (r *gormExecutables) Update(ctx context.Context, exec *Executable) error {
+	return updateColumns(r.db.WithContext(ctx), exec)
+}
+
+func (r *gormExecutables) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var params []uint
+		if err := tx.Model(&Parameter{}).Where("executable_id = ?", id).Pluck("id", &params).Error; err != nil {
+			return err
+		}
+		for _, param := range params {
+			if err := (&gormParameters{tx}).Delete(ctx, param); err != nil {
+				return err
+			}
+		}
+		return tombstone(tx, &Executable{}, id, "tag")
+	})
 }
 
 func GetTag(name string, version string) string {
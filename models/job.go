@@ -5,15 +5,26 @@ import (
 	"context"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 
 type Job struct {
 	gorm.Model
-	Name       string       `gorm:"unique;not null"`
-	CommandId  int          `gorm:"not null"`
-	Command    *Command     `gorm:"foreignKey:CommandId"`
-	FlagValues []*FlagValue `gorm:"many2many:job_flagvalues"`
+	Name        string           `gorm:"unique;not null"`
+	CommandId   int              `gorm:"not null"`
+	Command     *Command         `gorm:"foreignKey:CommandId"`
+	FlagValues  []*FlagValue     `gorm:"many2many:job_flagvalues"`
+	Retry       RetryPolicy      `gorm:"embedded;embeddedPrefix:retry_"`
+	Concurrency ConcurrencyLimit `gorm:"embedded;embeddedPrefix:concurrency_"`
+	// Runs with a higher priority are dispatched first, triggers may override it
+	Priority int `gorm:"not null;default:0"`
+	// Only workers advertising every one of these labels can run the job
+	WorkerLabels map[string]string `gorm:"serializer:json"`
+	// WorkingDir is the directory the process starts in, the one of the worker when empty
+	WorkingDir string
+	// Env is added to the environment of the worker for the process
+	Env map[string]string `gorm:"serializer:json"`
 }
 
 type FlagValue struct {
@@ -41,36 +52,118 @@ func NewFlagValue(param *Parameter, value string) *FlagValue {
 	}
 }
 
-func FetchJob(ctx context.Context, db *gorm.DB, column, value any) (*Job, error) {
-	var job Job
+// RunPriority returns the priority of a new run : the one given by its trigger when set, the one of the job otherwise.
+func (j *Job) RunPriority(override *int) int {
+	if override != nil {
+		return *override
+	}
+	return j.Priority
+}
 
-	err := db.WithContext(ctx).
+// RetryPolicy returns the policy of the job, completed by the one of its command and then by DefaultRetryPolicy.
+func (j *Job) RetryPolicy() RetryPolicy {
+	p := j.Retry
+	if j.Command != nil {
+		p = p.Merge(j.Command.Retry)
+	}
+	return p.Merge(DefaultRetryPolicy())
+}
+
+type gormJobs struct {
+	db *gorm.DB
+}
+
+func (r *gormJobs) preload(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
 		Preload("Command").
 		Preload("Command.Executable").
 		Preload("FlagValues").
-		Preload("FlagValues.Parameter").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		First(&job).Error
-	if err != nil {
-		return nil, err
+		Preload("FlagValues.Parameter")
+}
+
+func (r *gormJobs) Get(ctx context.Context, id uint) (*Job, error) {
+	var job Job
+	if err := r.preload(ctx).First(&job, id).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find job %d: %w", id, err)
 	}
+	return &job, nil
+}
 
+func (r *gormJobs) GetByName(ctx context.Context, name string) (*Job, error) {
+	var job Job
+	if err := r.preload(ctx).Where("name = ?", name).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find job %s: %w", name, err)
+	}
 	return &job, nil
 }
 
-func FetchJobs(ctx context.Context, db *gorm.DB, column, value any) ([]Job, error) {
+func (r *gormJobs) Find(ctx context.Context, filter JobFilter) ([]Job, error) {
 	var jobs []Job
 
-	err := db.WithContext(ctx).
-		Preload("Command").
-		Preload("Command.Executable").
-		Preload("FlagValues").
-		Preload("FlagValues.Parameter").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		Find(&jobs).Error
-	if err != nil {
+	query := r.preload(ctx).Order("id")
+	if filter.CommandID != 0 {
+		query = query.Where("command_id = ?", filter.CommandID)
+	}
+	if err := query.Find(&jobs).Error; err != nil {
 		return nil, err
 	}
-
 	return jobs, nil
 }
+
+func (r *gormJobs) Create(ctx context.Context, job *Job) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+func (r *gormJobs) SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error {
+	job := &Job{Retry: policy}
+	job.ID = id
+	return r.db.WithContext(ctx).Model(job).Select(RetryColumns).Updates(job).Error
+}
+
+func (r *gormJobs) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	job := &Job{Concurrency: limit}
+	job.ID = id
+	return r.db.WithContext(ctx).Model(job).Select(ConcurrencyColumns).Updates(job).Error
+}
+
+func (r *gormJobs) SaveFlagValues(ctx context.Context, id uint, values []*FlagValue) error {
+	db := r.db.WithContext(ctx)
+	for _, fv := range values {
+		if err := db.Omit(clause.Associations).Where("parameter_id = ? AND value = ?", fv.ParameterId, fv.Value).FirstOrCreate(fv).Error; err != nil {
+			return err
+		}
+	}
+
+	job := &Job{}
+	job.ID = id
+	return db.Model(job).Omit("FlagValues.*").Association("FlagValues").Replace(values)
+}
+
+func (r *gormJobs) Update(ctx context.Context, job *Job) error {
+	return updateColumns(r.db.WithContext(ctx), job)
+}
+
+// Delete removes the job and its values, its runs are kept. It is refused while a schedule starts it.
+func (r *gormJobs) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var schedules int64
+		if err := tx.Model(&Schedule{}).Where("job_id = ?", id).Count(&schedules).Error; err != nil {
+			return err
+		}
+		if schedules > 0 {
+			return fmt.Errorf("%w : job %d has %d schedules", ErrScheduled, id, schedules)
+		}
+
+		var active int64
+		if err := tx.Model(&Run{}).Where("job_id = ? AND status NOT IN ?", id, finalStatuses).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return fmt.Errorf("%w : job %d has %d", ErrActiveRuns, id, active)
+		}
+		if err := tx.Exec("DELETE FROM job_flagvalues WHERE job_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tombstone(tx, &Job{}, id, "name")
+	})
+}
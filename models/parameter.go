@@ -2,7 +2,9 @@ package models
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -31,17 +33,44 @@ type Parameter struct {
 	ValueType     ValueType		`gorm:"not null"`
 	Require       []Parameter	`gorm:"many2many:flag_dependencies;joinForeignKey:flag_id;joinReferences:requires_id"`
 	Interfer      []Parameter	`gorm:"many2many:flag_conflicts;joinForeignKey:flag_id;joinReferences:interfer_id"`
+	Repeatable    bool			`gorm:"not null;default:false"`	// the flag can be given more than once
+	OneOf         []string		`gorm:"serializer:json"`			// groups of the executable in which exactly one flag is selected
+	AtLeastOne    []string		`gorm:"serializer:json"`			// groups of the executable in which one flag at least is selected
+	ValueRules    []ValueRule	`gorm:"serializer:json"`
+}
+
+// Kinds of groups of parameters.
+const (
+	GroupOneOf      = "one-of"
+	GroupAtLeastOne = "at-least-one"
+)
+
+// ValueRule requires another flag with a given value, when the value of the parameter starts with When.
+// For instance {When: "rsa_keygen_bits", Flag: "-algorithm", Value: "RSA"} on -pkeyopt. An empty When matches any value.
+type ValueRule struct {
+	When  string `json:"when"`
+	Flag  string `json:"flag"`
+	Value string `json:"value"`
+}
+
+// Applies reports whether the rule applies to the given value of its parameter.
+func (r ValueRule) Applies(value string) bool {
+	return strings.HasPrefix(value, r.When)
 }
 
 type ParameterRaw struct {
-	Flag          string    `json:"flag"`
-	Description   string    `json:"description"`
-	ExecutableTag string    `json:"executable_tag"`
-	RequiresRoot  bool      `json:"requires_root"`
-	RequiresValue bool      `json:"requires_value"`
-	ValueType     ValueType `json:"value_type"`
-	RequireIDs    []string  `json:"require_ids"`
-	InterferIDs   []string  `json:"interfer_ids"`
+	Flag          string      `json:"flag"`
+	Description   string      `json:"description"`
+	ExecutableTag string      `json:"executable_tag"`
+	RequiresRoot  bool        `json:"requires_root"`
+	RequiresValue bool        `json:"requires_value"`
+	ValueType     ValueType   `json:"value_type"`
+	RequireIDs    []string    `json:"require_ids"`
+	InterferIDs   []string    `json:"interfer_ids"`
+	Repeatable    bool        `json:"repeatable"`
+	OneOf         []string    `json:"one_of"`
+	AtLeastOne    []string    `json:"at_least_one"`
+	ValueRules    []ValueRule `json:"value_rules"`
 }
 
 // Newmodels.Parameter returns a new models.Parameter with a flag, description, the corresponding Executable ID, if the flag needs root access or a value and the value type
@@ -73,52 +102,120 @@ func NewParameterRaw(flag, description, executableTag string, requiresRoot, requ
 	}
 }
 
-// FetchParameter returns the first parameter corresponding to the given column and value.
-func FetchParameter(ctx context.Context, db *gorm.DB, column string, value any) (*Parameter, error) {
-	var param Parameter
+// Raw returns the parameter in the catalog format, its relations given by flag.
+func (p *Parameter) Raw(executableTag string) ParameterRaw {
+	raw := ParameterRaw{
+		Flag:          p.Flag,
+		Description:   p.Description,
+		ExecutableTag: executableTag,
+		RequiresRoot:  p.RequiresRoot,
+		RequiresValue: p.RequiresValue,
+		ValueType:     p.ValueType,
+		RequireIDs:    []string{},
+		InterferIDs:   []string{},
+		Repeatable:    p.Repeatable,
+		OneOf:         p.OneOf,
+		AtLeastOne:    p.AtLeastOne,
+		ValueRules:    p.ValueRules,
+	}
+	for _, r := range p.Require {
+		raw.RequireIDs = append(raw.RequireIDs, r.Flag)
+	}
+	for _, i := range p.Interfer {
+		raw.InterferIDs = append(raw.InterferIDs, i.Flag)
+	}
+	return raw
+}
+
+type gormParameters struct {
+	db *gorm.DB
+}
 
-	err := db.WithContext(ctx).
+func (r *gormParameters) preload(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).
 		Preload("Executable").
 		Preload("Interfer").
-		Preload("Require").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		First(&param).Error
-	if err != nil {
-		return nil, err
+		Preload("Require")
+}
+
+func (r *gormParameters) Get(ctx context.Context, id uint) (*Parameter, error) {
+	var param Parameter
+	if err := r.preload(ctx).First(&param, id).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find parameter %d: %w", id, err)
 	}
+	return &param, nil
+}
+
+func (r *gormParameters) GetByFlag(ctx context.Context, executableID uint, flag string) (*Parameter, error) {
+	var param Parameter
 
+	query := r.preload(ctx).Where("executable_id = ? AND flag = ?", executableID, flag)
+	if err := query.First(&param).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find parameter %s: %w", flag, err)
+	}
 	return &param, nil
 }
 
-// FetchParameter returns every parameters corresponding to the given column and value.
-func FetchParameters(ctx context.Context, db *gorm.DB, column string, value any) ([]Parameter, error) {
-	var params []Parameter
+// Find returns the parameters matching the filter. With Flags set, they are returned in the same order, and a missing flag is an error.
+func (r *gormParameters) Find(ctx context.Context, filter ParameterFilter) ([]Parameter, error) {
+	if filter.Flags != nil {
+		params := make([]Parameter, 0, len(filter.Flags))
+		for _, flag := range filter.Flags {
+			param, err := r.GetByFlag(ctx, filter.ExecutableID, flag)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, *param)
+		}
+		return params, nil
+	}
 
-	err := db.WithContext(ctx).
-		Preload("Executable").
-		Preload("Interfer").
-		Preload("Require").
-		Where(fmt.Sprintf("%s = ?", column), value).
-		Find(&params).Error
-	if err != nil {
+	var params []Parameter
+	query := r.preload(ctx).Order("id")
+	if filter.ExecutableID != 0 {
+		query = query.Where("executable_id = ?", filter.ExecutableID)
+	}
+	if err := query.Find(&params).Error; err != nil {
 		return nil, err
 	}
-
 	return params, nil
 }
 
-// FetchFlagParameters returns parameters corresponding to the given flags
-func FetchFlagParameters(ctx context.Context, db *gorm.DB, column string, flags []string) ([]Parameter, error) {
-	var result []Parameter
+func (r *gormParameters) Create(ctx context.Context, param *Parameter) error {
+	return r.db.WithContext(ctx).Create(param).Error
+}
 
-	for _, flag := range flags {
-		param, err := FetchParameter(ctx, db, column, flag)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, *param)
+func (r *gormParameters) Update(ctx context.Context, param *Parameter) error {
+	db := r.db.WithContext(ctx)
+	if err := updateColumns(db, param); err != nil {
+		return err
+	}
+	if err := replaceAssociation(db, param, "Require", param.Require); err != nil {
+		return err
 	}
-	return result, nil
+	return replaceAssociation(db, param, "Interfer", param.Interfer)
+}
+
+func (r *gormParameters) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		values := tx.Model(&FlagValue{}).Select("id").Where("parameter_id = ?", id)
+		if err := tx.Exec("DELETE FROM job_flagvalues WHERE flag_value_id IN (?)", values).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("parameter_id = ?", id).Delete(&FlagValue{}).Error; err != nil {
+			return err
+		}
+		for _, join := range []string{
+			"DELETE FROM flag_dependencies WHERE flag_id = @id OR requires_id = @id",
+			"DELETE FROM flag_conflicts WHERE flag_id = @id OR interfer_id = @id",
+			"DELETE FROM command_parameters WHERE parameter_id = @id",
+		} {
+			if err := tx.Exec(join, sql.Named("id", id)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&Parameter{}, id).Error
+	})
 }
 
 // AllValueTypes list every supported type for a parameter value
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFound is returned by the repositories when no record matches. Check it with errors.Is.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrActiveRuns is returned when a job or a workflow is deleted while some of its runs aren't finished.
+var ErrActiveRuns = errors.New("runs still in progress")
+
+// ErrScheduled is returned when a job is deleted while a schedule still starts it.
+var ErrScheduled = errors.New("job still scheduled")
+
+// ErrIdempotencyKeyUsed is returned when a run is saved with an idempotency key already held by another run.
+var ErrIdempotencyKeyUsed = errors.New("idempotency key already used")
+
+// Repositories gives access to every model of OTO.
+// Lookups are typed : records are found by ID, name, tag or explicit filters, never by a column given by the caller.
+type Repositories struct {
+	Executables  ExecutableRepository
+	Parameters   ParameterRepository
+	Commands     CommandRepository
+	Jobs         JobRepository
+	Runs         RunRepository
+	Workflows    WorkflowRepository
+	WorkflowRuns WorkflowRunRepository
+	Schedules    ScheduleRepository
+
+	// db is the database of the gorm repositories, nil for the memory ones
+	db *gorm.DB
+}
+
+// Transaction calls fn with repositories bound to a single transaction, committed when fn returns nil
+// and rolled back otherwise. The memory repositories have no transaction : fn uses them directly.
+func (r *Repositories) Transaction(ctx context.Context, fn func(tx *Repositories) error) error {
+	if r.db == nil {
+		return fn(r)
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(NewGormRepositories(tx))
+	})
+}
+
+type ExecutableRepository interface {
+	Get(ctx context.Context, id uint) (*Executable, error)
+	GetByTag(ctx context.Context, tag string) (*Executable, error)
+	List(ctx context.Context) ([]Executable, error)
+	Create(ctx context.Context, exec *Executable) error
+	SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error
+	// Update saves the path, the description and the concurrency limit of the executable.
+	Update(ctx context.Context, exec *Executable) error
+	// Delete removes the executable with its parameters, its tag can be taken again. Its commands must be deleted first.
+	Delete(ctx context.Context, id uint) error
+}
+
+// ParameterFilter selects parameters. Zero fields don't filter, except that Flags are only looked up within ExecutableID.
+type ParameterFilter struct {
+	ExecutableID uint
+	Flags        []string
+}
+
+type ParameterRepository interface {
+	Get(ctx context.Context, id uint) (*Parameter, error)
+	// GetByFlag returns the parameter of the executable with the given flag. A flag is always resolved within its executable :
+	// nmap, masscan and openssl may each have a -p.
+	GetByFlag(ctx context.Context, executableID uint, flag string) (*Parameter, error)
+	Find(ctx context.Context, filter ParameterFilter) ([]Parameter, error)
+	Create(ctx context.Context, param *Parameter) error
+	// Update saves the fields of the parameter and replaces its requirements and interferences.
+	Update(ctx context.Context, param *Parameter) error
+	// Delete removes the parameter, its relations and its values.
+	Delete(ctx context.Context, id uint) error
+}
+
+// CommandFilter selects commands. Zero fields don't filter.
+type CommandFilter struct {
+	ExecutableID uint
+}
+
+type CommandRepository interface {
+	Get(ctx context.Context, id uint) (*Command, error)
+	GetByName(ctx context.Context, name string) (*Command, error)
+	Find(ctx context.Context, filter CommandFilter) ([]Command, error)
+	Create(ctx context.Context, cmd *Command) error
+	SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error
+	SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error
+	// Rebind moves the command to another executable, with the given parameters of this executable.
+	Rebind(ctx context.Context, id uint, exec *Executable, params []Parameter) error
+	// Update saves the fields of the command and replaces its parameters.
+	Update(ctx context.Context, cmd *Command) error
+	// Delete removes the command, its name can be taken again. Its jobs must be deleted first.
+	Delete(ctx context.Context, id uint) error
+}
+
+// JobFilter selects jobs. Zero fields don't filter.
+type JobFilter struct {
+	CommandID uint
+}
+
+type JobRepository interface {
+	Get(ctx context.Context, id uint) (*Job, error)
+	GetByName(ctx context.Context, name string) (*Job, error)
+	Find(ctx context.Context, filter JobFilter) ([]Job, error)
+	Create(ctx context.Context, job *Job) error
+	SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error
+	SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error
+	// SaveFlagValues replaces the values of the job. A value already saved for the same parameter is reused.
+	SaveFlagValues(ctx context.Context, id uint, values []*FlagValue) error
+	// Update saves the fields of the job. Its values are saved by SaveFlagValues.
+	Update(ctx context.Context, job *Job) error
+	// Delete removes the job, its name can be taken again. Its runs are kept, ErrActiveRuns is returned while one isn't finished.
+	// Its schedules must be deleted first, ErrScheduled is returned otherwise.
+	Delete(ctx context.Context, id uint) error
+}
+
+type RunRepository interface {
+	// Get returns the run with its job and its attempts.
+	Get(ctx context.Context, id uint) (*Run, error)
+	// ListByJob returns the runs of the job, most recent first.
+	ListByJob(ctx context.Context, jobID uint) ([]Run, error)
+	// Create saves the run. ErrIdempotencyKeyUsed is returned when its DedupKey is held by another run.
+	Create(ctx context.Context, run *Run) error
+	// GetByIdempotencyKey returns the run holding the key.
+	GetByIdempotencyKey(ctx context.Context, key string) (*Run, error)
+	// ReleaseIdempotencyKey frees the key when the run holding it was created before the given time.
+	ReleaseIdempotencyKey(ctx context.Context, key string, before time.Time) error
+}
+
+type WorkflowRepository interface {
+	// GetByName returns the workflow with its ordered nodes and their jobs.
+	GetByName(ctx context.Context, name string) (*Workflow, error)
+	List(ctx context.Context) ([]Workflow, error)
+	Create(ctx context.Context, wf *Workflow) error
+	// Update saves the description of the workflow and replaces its nodes.
+	Update(ctx context.Context, wf *Workflow) error
+	// Delete removes the workflow, its name can be taken again. Its runs are kept, ErrActiveRuns is returned while one isn't finished.
+	Delete(ctx context.Context, id uint) error
+}
+
+type WorkflowRunRepository interface {
+	// Get returns the workflow run with its definition, the runs of its jobs and its approvals.
+	Get(ctx context.Context, id uint) (*WorkflowRun, error)
+	Create(ctx context.Context, run *WorkflowRun) error
+}
+
+type ScheduleRepository interface {
+	GetByName(ctx context.Context, name string) (*Schedule, error)
+	// List returns every schedule, sorted by name.
+	List(ctx context.Context) ([]Schedule, error)
+	// Due returns the enabled schedules whose next run is due at the given time.
+	Due(ctx context.Context, now time.Time) ([]Schedule, error)
+	Create(ctx context.Context, schedule *Schedule) error
+	SetEnabled(ctx context.Context, id uint, enabled bool, nextRunAt time.Time) error
+	Delete(ctx context.Context, id uint) error
+	// Update saves the job, the spec, the priority and the state of the schedule.
+	Update(ctx context.Context, schedule *Schedule) error
+}
+
+// tombstone soft deletes the record and renames its unique column, so that a new record can take the name again
+// while the history keeps referring to the deleted one.
+func tombstone(db *gorm.DB, model any, id uint, column string) error {
+	return db.Model(model).Where("id = ?", id).Updates(map[string]any{
+		column:       gorm.Expr(column+" || ?", fmt.Sprintf("#deleted-%d", id)),
+		"deleted_at": time.Now(),
+	}).Error
+}
+
+// updateColumns saves every column of the record but its key, creation time and associations.
+func updateColumns(db *gorm.DB, record any) error {
+	return db.Model(record).Select("*").Omit("id", "created_at", "deleted_at", clause.Associations).Updates(record).Error
+}
+
+// replaceAssociation replaces the records of an association, none when values is empty.
+func replaceAssociation[T any](db *gorm.DB, model any, name string, values []T) error {
+	if len(values) == 0 {
+		return db.Model(model).Association(name).Clear()
+	}
+	return db.Model(model).Association(name).Replace(values)
+}
+
+// NewGormRepositories returns the repositories backed by the database.
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Executables:  &gormExecutables{db},
+		Parameters:   &gormParameters{db},
+		Commands:     &gormCommands{db},
+		Jobs:         &gormJobs{db},
+		Runs:         &gormRuns{db},
+		Workflows:    &gormWorkflows{db},
+		WorkflowRuns: &gormWorkflowRuns{db},
+		Schedules:    &gormSchedules{db},
+		db:           db,
+	}
+}
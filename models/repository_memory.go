@@ -0,0 +1,497 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewMemoryRepositories returns repositories keeping the records in memory, for tests.
+// Relations are kept as given on creation : nothing is preloaded from the other repositories.
+func NewMemoryRepositories() *Repositories {
+	params := newMemoryTable(func(p *Parameter) *gorm.Model { return &p.Model })
+	runs := newMemoryTable(func(r *Run) *gorm.Model { return &r.Model })
+	schedules := newMemoryTable(func(s *Schedule) *gorm.Model { return &s.Model })
+	workflowRuns := newMemoryTable(func(r *WorkflowRun) *gorm.Model { return &r.Model })
+	return &Repositories{
+		Executables:  &memoryExecutables{newMemoryTable(func(e *Executable) *gorm.Model { return &e.Model }), params},
+		Parameters:   &memoryParameters{params},
+		Commands:     &memoryCommands{newMemoryTable(func(c *Command) *gorm.Model { return &c.Model })},
+		Jobs:         &memoryJobs{newMemoryTable(func(j *Job) *gorm.Model { return &j.Model }), runs, schedules},
+		Runs:         &memoryRuns{runs},
+		Workflows:    &memoryWorkflows{newMemoryTable(func(w *Workflow) *gorm.Model { return &w.Model }), workflowRuns},
+		WorkflowRuns: &memoryWorkflowRuns{workflowRuns},
+		Schedules:    &memorySchedules{schedules},
+	}
+}
+
+// memoryTable stores the records of a model, in creation order.
+type memoryTable[T any] struct {
+	mu      sync.RWMutex
+	records []*T
+	nextID  uint
+	model   func(*T) *gorm.Model
+}
+
+func newMemoryTable[T any](model func(*T) *gorm.Model) *memoryTable[T] {
+	return &memoryTable[T]{model: model}
+}
+
+func (t *memoryTable[T]) create(record *T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.nextID++
+	m := t.model(record)
+	m.ID = t.nextID
+	m.CreatedAt = time.Now()
+	m.UpdatedAt = m.CreatedAt
+	t.records = append(t.records, record)
+}
+
+// first returns a copy of the first record matching, ErrNotFound otherwise.
+func (t *memoryTable[T]) first(match func(*T) bool) (*T, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, record := range t.records {
+		if match(record) {
+			found := *record
+			return &found, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// find returns a copy of every record matching.
+func (t *memoryTable[T]) find(match func(*T) bool) []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	found := []T{}
+	for _, record := range t.records {
+		if match(record) {
+			found = append(found, *record)
+		}
+	}
+	return found
+}
+
+func (t *memoryTable[T]) update(id uint, change func(*T)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, record := range t.records {
+		if t.model(record).ID == id {
+			change(record)
+			t.model(record).UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// replace overwrites the record with the given one, keeping its ID and creation time.
+func (t *memoryTable[T]) replace(id uint, record *T) error {
+	return t.update(id, func(current *T) {
+		m := *t.model(current)
+		*current = *record
+		*t.model(current) = m
+	})
+}
+
+func (t *memoryTable[T]) delete(id uint) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, record := range t.records {
+		if t.model(record).ID == id {
+			t.records = append(t.records[:i], t.records[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func all[T any](*T) bool { return true }
+
+type memoryExecutables struct {
+	t      *memoryTable[Executable]
+	params *memoryTable[Parameter]
+}
+
+func (r *memoryExecutables) Get(ctx context.Context, id uint) (*Executable, error) {
+	exec, err := r.t.first(func(e *Executable) bool { return e.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find executable %d: %w", id, err)
+	}
+	return exec, nil
+}
+
+func (r *memoryExecutables) GetByTag(ctx context.Context, tag string) (*Executable, error) {
+	exec, err := r.t.first(func(e *Executable) bool { return e.Tag == tag })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find executable %s: %w", tag, err)
+	}
+	return exec, nil
+}
+
+func (r *memoryExecutables) List(ctx context.Context) ([]Executable, error) {
+	return r.t.find(all[Executable]), nil
+}
+
+func (r *memoryExecutables) Create(ctx context.Context, exec *Executable) error {
+	if _, err := r.GetByTag(ctx, exec.Tag); err == nil {
+		return fmt.Errorf("executable %s already exists", exec.Tag)
+	}
+	r.t.create(exec)
+	return nil
+}
+
+func (r *memoryExecutables) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	return r.t.update(id, func(e *Executable) { e.Concurrency = limit })
+}
+
+func (r *memoryExecutables) Update(ctx context.Context, exec *Executable) error {
+	return r.t.replace(exec.ID, exec)
+}
+
+func (r *memoryExecutables) Delete(ctx context.Context, id uint) error {
+	for _, p := range r.params.find(func(p *Parameter) bool { return uint(p.ExecutableID) == id }) {
+		if err := r.params.delete(p.ID); err != nil {
+			return err
+		}
+	}
+	return r.t.delete(id)
+}
+
+type memoryParameters struct{ t *memoryTable[Parameter] }
+
+func (r *memoryParameters) Get(ctx context.Context, id uint) (*Parameter, error) {
+	param, err := r.t.first(func(p *Parameter) bool { return p.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find parameter %d: %w", id, err)
+	}
+	return param, nil
+}
+
+func (r *memoryParameters) GetByFlag(ctx context.Context, executableID uint, flag string) (*Parameter, error) {
+	param, err := r.t.first(func(p *Parameter) bool {
+		return p.Flag == flag && uint(p.ExecutableID) == executableID
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find parameter %s: %w", flag, err)
+	}
+	return param, nil
+}
+
+func (r *memoryParameters) Find(ctx context.Context, filter ParameterFilter) ([]Parameter, error) {
+	if filter.Flags != nil {
+		params := make([]Parameter, 0, len(filter.Flags))
+		for _, flag := range filter.Flags {
+			param, err := r.GetByFlag(ctx, filter.ExecutableID, flag)
+			if err != nil {
+				return nil, err
+			}
+			params = append(params, *param)
+		}
+		return params, nil
+	}
+	return r.t.find(func(p *Parameter) bool {
+		return filter.ExecutableID == 0 || uint(p.ExecutableID) == filter.ExecutableID
+	}), nil
+}
+
+func (r *memoryParameters) Create(ctx context.Context, param *Parameter) error {
+	if param.Executable != nil && param.ExecutableID == 0 {
+		param.ExecutableID = int(param.Executable.ID)
+	}
+	if _, err := r.GetByFlag(ctx, uint(param.ExecutableID), param.Flag); err == nil {
+		return fmt.Errorf("parameter %s already exists", param.Flag)
+	}
+	r.t.create(param)
+	return nil
+}
+
+func (r *memoryParameters) Update(ctx context.Context, param *Parameter) error {
+	return r.t.replace(param.ID, param)
+}
+
+func (r *memoryParameters) Delete(ctx context.Context, id uint) error {
+	return r.t.delete(id)
+}
+
+type memoryCommands struct{ t *memoryTable[Command] }
+
+func (r *memoryCommands) Get(ctx context.Context, id uint) (*Command, error) {
+	cmd, err := r.t.first(func(c *Command) bool { return c.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find command %d: %w", id, err)
+	}
+	return cmd, nil
+}
+
+func (r *memoryCommands) GetByName(ctx context.Context, name string) (*Command, error) {
+	cmd, err := r.t.first(func(c *Command) bool { return c.Name == name })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find command %s: %w", name, err)
+	}
+	return cmd, nil
+}
+
+func (r *memoryCommands) Find(ctx context.Context, filter CommandFilter) ([]Command, error) {
+	return r.t.find(func(c *Command) bool {
+		return filter.ExecutableID == 0 || uint(c.ExecutableID) == filter.ExecutableID
+	}), nil
+}
+
+func (r *memoryCommands) Create(ctx context.Context, cmd *Command) error {
+	if _, err := r.GetByName(ctx, cmd.Name); err == nil {
+		return fmt.Errorf("command %s already exists", cmd.Name)
+	}
+	r.t.create(cmd)
+	return nil
+}
+
+func (r *memoryCommands) SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error {
+	return r.t.update(id, func(c *Command) { c.Retry = policy })
+}
+
+func (r *memoryCommands) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	return r.t.update(id, func(c *Command) { c.Concurrency = limit })
+}
+
+func (r *memoryCommands) Update(ctx context.Context, cmd *Command) error {
+	return r.t.replace(cmd.ID, cmd)
+}
+
+func (r *memoryCommands) Delete(ctx context.Context, id uint) error {
+	return r.t.delete(id)
+}
+
+func (r *memoryCommands) Rebind(ctx context.Context, id uint, exec *Executable, params []Parameter) error {
+	return r.t.update(id, func(c *Command) {
+		c.ExecutableID = int(exec.ID)
+		c.Executable = *exec
+		c.Parameters = params
+	})
+}
+
+type memoryJobs struct {
+	t         *memoryTable[Job]
+	runs      *memoryTable[Run]
+	schedules *memoryTable[Schedule]
+}
+
+func (r *memoryJobs) Get(ctx context.Context, id uint) (*Job, error) {
+	job, err := r.t.first(func(j *Job) bool { return j.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find job %d: %w", id, err)
+	}
+	return job, nil
+}
+
+func (r *memoryJobs) GetByName(ctx context.Context, name string) (*Job, error) {
+	job, err := r.t.first(func(j *Job) bool { return j.Name == name })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find job %s: %w", name, err)
+	}
+	return job, nil
+}
+
+func (r *memoryJobs) Find(ctx context.Context, filter JobFilter) ([]Job, error) {
+	return r.t.find(func(j *Job) bool {
+		return filter.CommandID == 0 || uint(j.CommandId) == filter.CommandID
+	}), nil
+}
+
+func (r *memoryJobs) Create(ctx context.Context, job *Job) error {
+	if _, err := r.GetByName(ctx, job.Name); err == nil {
+		return fmt.Errorf("job %s already exists", job.Name)
+	}
+	r.t.create(job)
+	return nil
+}
+
+func (r *memoryJobs) SaveRetryPolicy(ctx context.Context, id uint, policy RetryPolicy) error {
+	return r.t.update(id, func(j *Job) { j.Retry = policy })
+}
+
+func (r *memoryJobs) SaveConcurrency(ctx context.Context, id uint, limit ConcurrencyLimit) error {
+	return r.t.update(id, func(j *Job) { j.Concurrency = limit })
+}
+
+func (r *memoryJobs) SaveFlagValues(ctx context.Context, id uint, values []*FlagValue) error {
+	return r.t.update(id, func(j *Job) { j.FlagValues = values })
+}
+
+// Update keeps the values of the job, saved by SaveFlagValues.
+func (r *memoryJobs) Update(ctx context.Context, job *Job) error {
+	return r.t.update(job.ID, func(j *Job) {
+		values := j.FlagValues
+		m := j.Model
+		*j = *job
+		j.Model, j.FlagValues = m, values
+	})
+}
+
+// Delete removes the job, its runs are kept.
+func (r *memoryJobs) Delete(ctx context.Context, id uint) error {
+	if schedules := r.schedules.find(func(s *Schedule) bool { return uint(s.JobID) == id }); len(schedules) > 0 {
+		return fmt.Errorf("%w : job %d has %d schedules", ErrScheduled, id, len(schedules))
+	}
+	active := r.runs.find(func(run *Run) bool { return uint(run.JobID) == id && !run.Status.Final() })
+	if len(active) > 0 {
+		return fmt.Errorf("%w : job %d has %d", ErrActiveRuns, id, len(active))
+	}
+	return r.t.delete(id)
+}
+
+type memoryRuns struct{ t *memoryTable[Run] }
+
+func (r *memoryRuns) Get(ctx context.Context, id uint) (*Run, error) {
+	run, err := r.t.first(func(run *Run) bool { return run.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find run %d: %w", id, err)
+	}
+	return run, nil
+}
+
+func (r *memoryRuns) ListByJob(ctx context.Context, jobID uint) ([]Run, error) {
+	runs := r.t.find(func(run *Run) bool { return uint(run.JobID) == jobID })
+	sort.Slice(runs, func(a, b int) bool { return runs[a].ID > runs[b].ID })
+	return runs, nil
+}
+
+func (r *memoryRuns) Create(ctx context.Context, run *Run) error {
+	if run.DedupKey != nil {
+		if _, err := r.GetByIdempotencyKey(ctx, *run.DedupKey); err == nil {
+			return fmt.Errorf("%w : %s", ErrIdempotencyKeyUsed, *run.DedupKey)
+		}
+	}
+	r.t.create(run)
+	return nil
+}
+
+func (r *memoryRuns) GetByIdempotencyKey(ctx context.Context, key string) (*Run, error) {
+	run, err := r.t.first(func(run *Run) bool { return run.DedupKey != nil && *run.DedupKey == key })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find run with key %s: %w", key, err)
+	}
+	return run, nil
+}
+
+func (r *memoryRuns) ReleaseIdempotencyKey(ctx context.Context, key string, before time.Time) error {
+	held, err := r.GetByIdempotencyKey(ctx, key)
+	if err != nil || !held.CreatedAt.Before(before) {
+		return nil
+	}
+	return r.t.update(held.ID, func(run *Run) { run.DedupKey = nil })
+}
+
+type memoryWorkflows struct {
+	t    *memoryTable[Workflow]
+	runs *memoryTable[WorkflowRun]
+}
+
+func (r *memoryWorkflows) GetByName(ctx context.Context, name string) (*Workflow, error) {
+	wf, err := r.t.first(func(w *Workflow) bool { return w.Name == name })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find workflow %s: %w", name, err)
+	}
+	return wf, nil
+}
+
+func (r *memoryWorkflows) List(ctx context.Context) ([]Workflow, error) {
+	workflows := r.t.find(all[Workflow])
+	sort.Slice(workflows, func(a, b int) bool { return workflows[a].Name < workflows[b].Name })
+	return workflows, nil
+}
+
+func (r *memoryWorkflows) Create(ctx context.Context, wf *Workflow) error {
+	if _, err := r.GetByName(ctx, wf.Name); err == nil {
+		return fmt.Errorf("workflow %s already exists", wf.Name)
+	}
+	r.t.create(wf)
+	return nil
+}
+
+func (r *memoryWorkflows) Update(ctx context.Context, wf *Workflow) error {
+	for n := range wf.Nodes {
+		wf.Nodes[n].WorkflowID = int(wf.ID)
+	}
+	return r.t.replace(wf.ID, wf)
+}
+
+// Delete removes the workflow, its runs are kept.
+func (r *memoryWorkflows) Delete(ctx context.Context, id uint) error {
+	active := r.runs.find(func(run *WorkflowRun) bool { return uint(run.DefinitionID) == id && !run.Status.Final() })
+	if len(active) > 0 {
+		return fmt.Errorf("%w : workflow %d has %d", ErrActiveRuns, id, len(active))
+	}
+	return r.t.delete(id)
+}
+
+type memoryWorkflowRuns struct{ t *memoryTable[WorkflowRun] }
+
+func (r *memoryWorkflowRuns) Get(ctx context.Context, id uint) (*WorkflowRun, error) {
+	run, err := r.t.first(func(run *WorkflowRun) bool { return run.ID == id })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find workflow run %d: %w", id, err)
+	}
+	return run, nil
+}
+
+func (r *memoryWorkflowRuns) Create(ctx context.Context, run *WorkflowRun) error {
+	r.t.create(run)
+	return nil
+}
+
+type memorySchedules struct{ t *memoryTable[Schedule] }
+
+func (r *memorySchedules) GetByName(ctx context.Context, name string) (*Schedule, error) {
+	schedule, err := r.t.first(func(s *Schedule) bool { return s.Name == name })
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find schedule %s: %w", name, err)
+	}
+	return schedule, nil
+}
+
+func (r *memorySchedules) List(ctx context.Context) ([]Schedule, error) {
+	schedules := r.t.find(all[Schedule])
+	sort.Slice(schedules, func(a, b int) bool { return schedules[a].Name < schedules[b].Name })
+	return schedules, nil
+}
+
+func (r *memorySchedules) Due(ctx context.Context, now time.Time) ([]Schedule, error) {
+	schedules := r.t.find(func(s *Schedule) bool { return s.Enabled && !s.NextRunAt.After(now) })
+	sort.Slice(schedules, func(a, b int) bool { return schedules[a].NextRunAt.Before(schedules[b].NextRunAt) })
+	return schedules, nil
+}
+
+func (r *memorySchedules) Create(ctx context.Context, schedule *Schedule) error {
+	if _, err := r.GetByName(ctx, schedule.Name); err == nil {
+		return fmt.Errorf("schedule %s already exists", schedule.Name)
+	}
+	r.t.create(schedule)
+	return nil
+}
+
+func (r *memorySchedules) SetEnabled(ctx context.Context, id uint, enabled bool, nextRunAt time.Time) error {
+	return r.t.update(id, func(s *Schedule) {
+		s.Enabled = enabled
+		s.NextRunAt = nextRunAt
+	})
+}
+
+func (r *memorySchedules) Delete(ctx context.Context, id uint) error {
+	return r.t.delete(id)
+}
+
+func (r *memorySchedules) Update(ctx context.Context, schedule *Schedule) error {
+	return r.t.replace(schedule.ID, schedule)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// RetryPolicy describes how a failed run should be retried.
+// A zero field means "not set" : it is inherited from the command, then from DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaximumAttempts    int32         `json:"maximum_attempts"`
+	InitialInterval    time.Duration `json:"initial_interval"`
+	BackoffCoefficient float64       `json:"backoff_coefficient"`
+	MaximumInterval    time.Duration `json:"maximum_interval"`
+	NonRetryableErrors []string      `gorm:"serializer:json" json:"non_retryable_errors"`
+}
+
+// RetryColumns are the columns of a RetryPolicy embedded in a Command or a Job.
+var RetryColumns = []string{"retry_maximum_attempts", "retry_initial_interval", "retry_backoff_coefficient", "retry_maximum_interval", "retry_non_retryable_errors"}
+
+// DefaultRetryPolicy is used for every field left unset on both the command and the job.
+// Unlike Temporal's default policy, the number of attempts is bounded.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaximumAttempts:    3,
+		InitialInterval:    time.Second,
+		BackoffCoefficient: 2.0,
+		MaximumInterval:    time.Minute,
+	}
+}
+
+// Merge returns a copy of p where every unset field is taken from fallback.
+func (p RetryPolicy) Merge(fallback RetryPolicy) RetryPolicy {
+	if p.MaximumAttempts == 0 {
+		p.MaximumAttempts = fallback.MaximumAttempts
+	}
+	if p.InitialInterval == 0 {
+		p.InitialInterval = fallback.InitialInterval
+	}
+	if p.BackoffCoefficient == 0 {
+		p.BackoffCoefficient = fallback.BackoffCoefficient
+	}
+	if p.MaximumInterval == 0 {
+		p.MaximumInterval = fallback.MaximumInterval
+	}
+	if len(p.NonRetryableErrors) == 0 {
+		p.NonRetryableErrors = fallback.NonRetryableErrors
+	}
+	return p
+}
+
+// Backoff returns the time to wait before the attempt following the given one.
+func (p RetryPolicy) Backoff(attempt int32) time.Duration {
+	interval := float64(p.InitialInterval)
+	for i := int32(1); i < attempt; i++ {
+		interval *= p.BackoffCoefficient
+		if p.MaximumInterval > 0 && interval >= float64(p.MaximumInterval) {
+			return p.MaximumInterval
+		}
+	}
+	return time.Duration(interval)
+}
+
+// Retryable reports whether a failure of the given class, at the given attempt, leaves room for another attempt.
+func (p RetryPolicy) Retryable(attempt int32, errorClass string) bool {
+	if p.MaximumAttempts > 0 && attempt >= p.MaximumAttempts {
+		return false
+	}
+	for _, class := range p.NonRetryableErrors {
+		if class == errorClass {
+			return false
+		}
+	}
+	return true
+}
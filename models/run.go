@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RunStatus string
+
+const (
+	RunPending   RunStatus = "pending"
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+	RunCancelled RunStatus = "cancelled"
+	RunSkipped   RunStatus = "skipped" // a concurrency limit with LimitSkip was reached
+
+	// Only used by workflow runs
+	RunAwaitingApproval RunStatus = "awaiting_approval"
+	RunRejected         RunStatus = "rejected"
+)
+
+// Run is one execution of a job. Every attempt made by the retry policy is kept in Attempts.
+type Run struct {
+	gorm.Model
+	JobID         int       `gorm:"not null;index"`
+	Job           *Job      `gorm:"foreignKey:JobID"`
+	WorkflowRunID *int      `gorm:"index"`
+	WorkflowID    string    `gorm:"index"`
+	TemporalRunID string    // execution of the workflow, the workflow ID is reused along with an idempotency key
+	Queue         string    `gorm:"index"` // task queue of the worker, or the queue of the local runner
+	Status        RunStatus `gorm:"not null"`
+	Priority      int       `gorm:"not null;default:0;index"`
+	WaitingFor    string    `gorm:"index"` // scope of the concurrency limit the run is waiting for, empty otherwise
+	// IdempotencyKey is given by the trigger. DedupKey holds it while the run is within the idempotency window,
+	// the unique index is what prevents two runs with the same key to be started at once
+	IdempotencyKey string  `gorm:"index"`
+	DedupKey       *string `gorm:"uniqueIndex" json:"-"`
+	Error          string  `gorm:"type:text"`
+	FinishedAt     *time.Time
+	Attempts       []RunAttempt `gorm:"foreignKey:RunID"`
+}
+
+// Finished reports whether the run reached a final status.
+func (r *Run) Finished() bool {
+	return r.Status.Final()
+}
+
+// finalStatuses are the statuses after which no more change can happen.
+var finalStatuses = []RunStatus{RunSucceeded, RunFailed, RunCancelled, RunRejected, RunSkipped}
+
+// Final reports whether no more change can happen after this status.
+func (s RunStatus) Final() bool {
+	return slices.Contains(finalStatuses, s)
+}
+
+// LastAttempt returns the most recent attempt of the run, nil when none has been made.
+func (r *Run) LastAttempt() *RunAttempt {
+	var last *RunAttempt
+	for i := range r.Attempts {
+		if last == nil || r.Attempts[i].Attempt > last.Attempt {
+			last = &r.Attempts[i]
+		}
+	}
+	return last
+}
+
+// RunAttempt is a single try of a run, with the output of the process.
+type RunAttempt struct {
+	gorm.Model
+	RunID      int       `gorm:"not null;uniqueIndex:uid_run_attempt"`
+	Attempt    int32     `gorm:"not null;uniqueIndex:uid_run_attempt"`
+	Status     RunStatus `gorm:"not null"`
+	ExitCode   int
+	Stdout     string `gorm:"type:text"`
+	Stderr     string `gorm:"type:text"`
+	Error      string `gorm:"type:text"`
+	FinishedAt *time.Time
+	// Argv, WorkingDir and Env are the process started by the attempt, the job may have changed since
+	Argv       []string `gorm:"serializer:json"`
+	WorkingDir string
+	Env        map[string]string `gorm:"serializer:json"`
+	// BinarySHA256 is the hash of the binary of the executable on the host of the attempt, empty when it couldn't be read
+	BinarySHA256 string
+}
+
+func NewRun(job *Job) *Run {
+	return &Run{
+		JobID:  int(job.ID),
+		Job:    job,
+		Status: RunPending,
+	}
+}
+
+func NewRunAttempt(runID int, attempt int32) *RunAttempt {
+	return &RunAttempt{
+		RunID:   runID,
+		Attempt: attempt,
+		Status:  RunRunning,
+	}
+}
+
+// ClaimRun moves the pending run of the queue with the highest priority, the oldest first, to running and returns it.
+// Only one caller can claim a run, even across processes sharing the database. It returns nil when the queue is empty.
+// A pending run waiting for a concurrency limit, or holding its leases, belongs to its runner : it is only claimed
+// once it hasn't tried again for staleAfter and its leases have expired.
+func ClaimRun(ctx context.Context, db *gorm.DB, queue string, staleAfter time.Duration) (*Run, error) {
+	claimable := func(db *gorm.DB) *gorm.DB {
+		now := time.Now()
+		return db.Where("queue = ? AND status = ?", queue, RunPending).
+			Where("waiting_for = ? OR updated_at < ?", "", now.Add(-staleAfter)).
+			Where("NOT EXISTS (SELECT 1 FROM run_leases WHERE run_leases.run_id = runs.id AND run_leases.expires_at > ?)", now)
+	}
+
+	for {
+		var run Run
+		err := db.WithContext(ctx).
+			Scopes(claimable).
+			Order("priority DESC, id").
+			Limit(1).
+			Find(&run).Error
+		if err != nil {
+			return nil, err
+		}
+		if run.ID == 0 {
+			return nil, nil
+		}
+
+		res := db.WithContext(ctx).Model(&Run{}).
+			Scopes(claimable).
+			Where("id = ?", run.ID).
+			Update("status", RunRunning)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		// Claimed by someone else in the meantime, try the next one
+		if res.RowsAffected == 0 {
+			continue
+		}
+
+		run.Status = RunRunning
+		return &run, nil
+	}
+}
+
+type gormRuns struct {
+	db *gorm.DB
+}
+
+func (r *gormRuns) Get(ctx context.Context, id uint) (*Run, error) {
+	var run Run
+
+	err := r.db.WithContext(ctx).
+		Preload("Job").
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB { return db.Order("attempt") }).
+		First(&run, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find run %d: %w", id, err)
+	}
+
+	return &run, nil
+}
+
+func (r *gormRuns) ListByJob(ctx context.Context, jobID uint) ([]Run, error) {
+	var runs []Run
+
+	err := r.db.WithContext(ctx).
+		Preload("Attempts", func(db *gorm.DB) *gorm.DB { return db.Order("attempt") }).
+		Where("job_id = ?", jobID).
+		Order("id desc").
+		Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// Create saves the run. Its job must already exist.
+func (r *gormRuns) Create(ctx context.Context, run *Run) error {
+	if run.DedupKey == nil {
+		return r.db.WithContext(ctx).Omit("Job").Create(run).Error
+	}
+
+	res := r.db.WithContext(ctx).Omit("Job").Clauses(clause.OnConflict{DoNothing: true}).Create(run)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w : %s", ErrIdempotencyKeyUsed, *run.DedupKey)
+	}
+	return nil
+}
+
+func (r *gormRuns) GetByIdempotencyKey(ctx context.Context, key string) (*Run, error) {
+	var run Run
+	if err := r.db.WithContext(ctx).Preload("Job").Where("dedup_key = ?", key).First(&run).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find run with key %s: %w", key, err)
+	}
+	return &run, nil
+}
+
+func (r *gormRuns) ReleaseIdempotencyKey(ctx context.Context, key string, before time.Time) error {
+	return r.db.WithContext(ctx).Model(&Run{}).
+		Where("dedup_key = ? AND created_at < ?", key, before).
+		Update("dedup_key", nil).Error
+}
+
+// QueuePosition returns the position of the run among the runs dispatched before it, 1 being the next one, 0 when it isn't queued.
+// A run waiting for a concurrency limit is ranked among the runs waiting for the same scope, a pending run among the pending runs of its queue.
+// Waiters which haven't tried again for staleAfter are ignored, their runner is gone.
+func QueuePosition(ctx context.Context, db *gorm.DB, run *Run, staleAfter time.Duration) (int, error) {
+	query := db.WithContext(ctx).Model(&Run{}).
+		Where("id <> ? AND (priority > ? OR (priority = ? AND id < ?))", run.ID, run.Priority, run.Priority, run.ID)
+
+	switch {
+	case run.Finished():
+		return 0, nil
+	case run.WaitingFor != "":
+		query = query.Where("waiting_for = ? AND updated_at > ? AND status NOT IN ?", run.WaitingFor, time.Now().Add(-staleAfter), finalStatuses)
+	case run.Status == RunPending:
+		query = query.Where("queue = ? AND status = ? AND waiting_for = ?", run.Queue, RunPending, "")
+	default:
+		return 0, nil
+	}
+
+	var ahead int64
+	if err := query.Count(&ahead).Error; err != nil {
+		return 0, fmt.Errorf("failed to compute the queue position of run %d: %w", run.ID, err)
+	}
+	return int(ahead) + 1, nil
+}
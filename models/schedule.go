@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schedule starts a run of its job every time its cron expression is due.
+// Spec is a standard cron expression (minute, hour, day of month, month, day of week)
+// or a descriptor such as @hourly or @every 10m.
+type Schedule struct {
+	gorm.Model
+	Name      string    `gorm:"unique;not null"`
+	JobID     int       `gorm:"not null;index"`
+	Job       *Job      `gorm:"foreignKey:JobID"`
+	Spec      string    `gorm:"not null"`
+	Enabled   bool      `gorm:"not null"`
+	NextRunAt time.Time `gorm:"index"`
+	LastRunAt *time.Time
+	LastRunID *int
+	LastError string `gorm:"type:text"`
+	// Priority of the runs started by the schedule, the one of the job when nil
+	Priority *int
+}
+
+type ScheduleRaw struct {
+	Name     string `json:"name" binding:"required"`
+	Job      string `json:"job" binding:"required"`
+	Spec     string `json:"spec" binding:"required"`
+	Enabled  *bool  `json:"enabled"`
+	Priority *int   `json:"priority"`
+}
+
+func NewSchedule(name string, job *Job, spec string, next time.Time) *Schedule {
+	return &Schedule{
+		Name:      name,
+		JobID:     int(job.ID),
+		Job:       job,
+		Spec:      spec,
+		Enabled:   true,
+		NextRunAt: next,
+	}
+}
+
+type gormSchedules struct {
+	db *gorm.DB
+}
+
+func (r *gormSchedules) GetByName(ctx context.Context, name string) (*Schedule, error) {
+	var schedule Schedule
+	if err := r.db.WithContext(ctx).Preload("Job").Where("name = ?", name).First(&schedule).Error; err != nil {
+		return nil, fmt.Errorf("couldn't find schedule %s: %w", name, err)
+	}
+	return &schedule, nil
+}
+
+func (r *gormSchedules) List(ctx context.Context) ([]Schedule, error) {
+	var schedules []Schedule
+	if err := r.db.WithContext(ctx).Preload("Job").Order("name").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *gormSchedules) Due(ctx context.Context, now time.Time) ([]Schedule, error) {
+	var schedules []Schedule
+
+	err := r.db.WithContext(ctx).
+		Preload("Job").
+		Where("enabled = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at").
+		Find(&schedules).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+func (r *gormSchedules) Create(ctx context.Context, schedule *Schedule) error {
+	return r.db.WithContext(ctx).Omit("Job").Create(schedule).Error
+}
+
+func (r *gormSchedules) SetEnabled(ctx context.Context, id uint, enabled bool, nextRunAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&Schedule{}).Where("id = ?", id).
+		Updates(map[string]any{"enabled": enabled, "next_run_at": nextRunAt}).Error
+}
+
+func (r *gormSchedules) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Unscoped().Delete(&Schedule{}, id).Error
+}
+
+func (r *gormSchedules) Update(ctx context.Context, schedule *Schedule) error {
+	return updateColumns(r.db.WithContext(ctx), schedule)
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Worker is the advertisement of a running worker : the task queue it polls and what its host is able to run.
+// Workers are saved in the database so any process of OTO can route jobs to them.
+type Worker struct {
+	gorm.Model
+	WorkerID      string `gorm:"unique;not null"`
+	TaskQueue     string `gorm:"not null;index"`
+	Host          string
+	Executables   []string          `gorm:"serializer:json"`
+	VerifiedPaths map[string]string `gorm:"serializer:json"`
+	Root          bool              `gorm:"not null"`
+	Labels        map[string]string `gorm:"serializer:json"`
+	Status        string            `gorm:"not null"`
+	Error         string            `gorm:"type:text"`
+	HeartbeatAt   time.Time         `gorm:"index"`
+}
+
+// Satisfies reports whether the worker can run the job : its executable has been found on the host,
+// root is available when the command requires it and every label wanted by the job matches.
+// A nil job is satisfied by any worker.
+func (w *Worker) Satisfies(job *Job) bool {
+	if job == nil {
+		return true
+	}
+
+	if job.Command != nil {
+		if _, ok := w.VerifiedPaths[job.Command.Executable.Tag]; !ok {
+			return false
+		}
+		if job.Command.RequiresRoot && !w.Root {
+			return false
+		}
+	}
+
+	for key, value := range job.WorkerLabels {
+		if w.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchLiveWorkers returns the workers with the given status that sent a heartbeat since the given time.
+func FetchLiveWorkers(ctx context.Context, db *gorm.DB, status string, since time.Time) ([]Worker, error) {
+	var workers []Worker
+
+	err := db.WithContext(ctx).
+		Where("status = ? AND heartbeat_at > ?", status, since).
+		Order("id").
+		Find(&workers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return workers, nil
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type NodeKind string
+
+const (
+	NodeJob      NodeKind = "job"
+	NodeApproval NodeKind = "approval"
+)
+
+type ApprovalStatus string
+
+const (
+	ApprovalPending  ApprovalStatus = "pending"
+	ApprovalApproved ApprovalStatus = "approved"
+	ApprovalRejected ApprovalStatus = "rejected"
+	ApprovalExpired  ApprovalStatus = "expired"
+)
+
+// Workflow is an ordered list of nodes : jobs to run, or approvals to wait for.
+type Workflow struct {
+	gorm.Model
+	Name        string         `gorm:"unique;not null"`
+	Description string         `gorm:"type:text"`
+	Nodes       []WorkflowNode `gorm:"foreignKey:WorkflowID"`
+}
+
+// WorkflowNode is a step of a workflow.
+// A job node runs its job. An approval node pauses the workflow until one of the approvers decides.
+// When Timeout is set, an approval without decision is rejected automatically.
+type WorkflowNode struct {
+	gorm.Model
+	WorkflowID int      `gorm:"not null;uniqueIndex:uid_workflow_node"`
+	Position   int      `gorm:"not null;uniqueIndex:uid_workflow_node"`
+	Kind       NodeKind `gorm:"not null"`
+	JobID      *int
+	Job        *Job     `gorm:"foreignKey:JobID"`
+	Approvers  []string `gorm:"serializer:json"`
+	Timeout    time.Duration
+	Priority   *int // of the run of a job node, the one of the job when nil
+}
+
+type WorkflowNodeRaw struct {
+	Kind      NodeKind      `json:"kind"`
+	Job       string        `json:"job"`
+	Approvers []string      `json:"approvers"`
+	Timeout   time.Duration `json:"timeout"`
+	Priority  *int          `json:"priority"`
+}
+
+// WorkflowRun is one execution of a workflow. Job nodes create their own Run, linked by WorkflowRunID.
+type WorkflowRun struct {
+	gorm.Model
+	DefinitionID int       `gorm:"not null;index"`
+	Definition   *Workflow `gorm:"foreignKey:DefinitionID"`
+	WorkflowID   string    `gorm:"index"`
+	Status       RunStatus `gorm:"not null"`
+	Error        string    `gorm:"type:text"`
+	FinishedAt   *time.Time
+	Runs         []Run      `gorm:"foreignKey:WorkflowRunID"`
+	Approvals    []Approval `gorm:"foreignKey:WorkflowRunID"`
+}
+
+// Approval is the sign-off requested by an approval node, with the decision once taken.
+type Approval struct {
+	gorm.Model
+	WorkflowRunID int            `gorm:"not null;index"`
+	NodeID        int            `gorm:"not null"`
+	Approvers     []string       `gorm:"serializer:json"`
+	Status        ApprovalStatus `gorm:"not null"`
+	DecidedBy     string
+	Comment       string `gorm:"type:text"`
+	DecidedAt     *time.Time
+	ExpiresAt     *time.Time
+}
+
+func NewWorkflow(name, description string, nodes []WorkflowNode) *Workflow {
+	return &Workflow{
+		Name:        name,
+		Description: description,
+		Nodes:       nodes,
+	}
+}
+
+func NewJobNode(position int, job *Job, priority *int) *WorkflowNode {
+	jobID := int(job.ID)
+	return &WorkflowNode{
+		Position: position,
+		Kind:     NodeJob,
+		JobID:    &jobID,
+		Job:      job,
+		Priority: priority,
+	}
+}
+
+func NewApprovalNode(position int, approvers []string, timeout time.Duration) *WorkflowNode {
+	return &WorkflowNode{
+		Position:  position,
+		Kind:      NodeApproval,
+		Approvers: approvers,
+		Timeout:   timeout,
+	}
+}
+
+func NewWorkflowRun(wf *Workflow) *WorkflowRun {
+	return &WorkflowRun{
+		DefinitionID: int(wf.ID),
+		Definition:   wf,
+		Status:       RunPending,
+	}
+}
+
+func NewApproval(workflowRunID int, node *WorkflowNode) *Approval {
+	approval := &Approval{
+		WorkflowRunID: workflowRunID,
+		NodeID:        int(node.ID),
+		Approvers:     node.Approvers,
+		Status:        ApprovalPending,
+	}
+	if node.Timeout > 0 {
+		expiresAt := time.Now().Add(node.Timeout)
+		approval.ExpiresAt = &expiresAt
+	}
+	return approval
+}
+
+// CanDecide reports whether user is allowed to decide. Anyone can when no approver is set.
+func (a *Approval) CanDecide(user string) bool {
+	if len(a.Approvers) == 0 {
+		return true
+	}
+	for _, approver := range a.Approvers {
+		if approver == user {
+			return true
+		}
+	}
+	return false
+}
+
+type gormWorkflows struct {
+	db *gorm.DB
+}
+
+func (r *gormWorkflows) GetByName(ctx context.Context, name string) (*Workflow, error) {
+	var wf Workflow
+
+	err := r.db.WithContext(ctx).
+		Preload("Nodes", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Preload("Nodes.Job").
+		Where("name = ?", name).
+		First(&wf).Error
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find workflow %s: %w", name, err)
+	}
+
+	return &wf, nil
+}
+
+func (r *gormWorkflows) List(ctx context.Context) ([]Workflow, error) {
+	var workflows []Workflow
+	if err := r.db.WithContext(ctx).Order("name").Find(&workflows).Error; err != nil {
+		return nil, err
+	}
+	return workflows, nil
+}
+
+// Create saves the workflow with its nodes. The jobs of the nodes must already exist.
+func (r *gormWorkflows) Create(ctx context.Context, wf *Workflow) error {
+	return r.db.WithContext(ctx).Omit("Nodes.Job").Create(wf).Error
+}
+
+// Update saves the workflow and replaces its nodes. The jobs of the nodes must already exist.
+func (r *gormWorkflows) Update(ctx context.Context, wf *Workflow) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := updateColumns(tx, wf); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("workflow_id = ?", wf.ID).Delete(&WorkflowNode{}).Error; err != nil {
+			return err
+		}
+		for n := range wf.Nodes {
+			wf.Nodes[n].WorkflowID = int(wf.ID)
+		}
+		if len(wf.Nodes) == 0 {
+			return nil
+		}
+		return tx.Omit("Job").Create(&wf.Nodes).Error
+	})
+}
+
+// Delete removes the workflow. Its nodes are kept with its runs, which refer to them.
+func (r *gormWorkflows) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var active int64
+		if err := tx.Model(&WorkflowRun{}).Where("definition_id = ? AND status NOT IN ?", id, finalStatuses).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return fmt.Errorf("%w : workflow %d has %d", ErrActiveRuns, id, active)
+		}
+		return tombstone(tx, &Workflow{}, id, "name")
+	})
+}
+
+type gormWorkflowRuns struct {
+	db *gorm.DB
+}
+
+func (r *gormWorkflowRuns) Get(ctx context.Context, id uint) (*WorkflowRun, error) {
+	var run WorkflowRun
+
+	err := r.db.WithContext(ctx).
+		Preload("Definition").
+		Preload("Runs").
+		Preload("Approvals").
+		First(&run, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find workflow run %d: %w", id, err)
+	}
+
+	return &run, nil
+}
+
+func (r *gormWorkflowRuns) Create(ctx context.Context, run *WorkflowRun) error {
+	return r.db.WithContext(ctx).Omit("Definition").Create(run).Error
+}
+
+// FetchPendingApproval returns the approval a workflow run is waiting for.
+func FetchPendingApproval(ctx context.Context, db *gorm.DB, workflowRunID uint) (*Approval, error) {
+	var approval Approval
+
+	err := db.WithContext(ctx).
+		Where("workflow_run_id = ? AND status = ?", workflowRunID, ApprovalPending).
+		First(&approval).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &approval, nil
+}
@@ -0,0 +1,8 @@
+// Package packs holds the tool packs embedded in OTO. Each directory is a pack : pack.json describes it,
+// and catalog.json holds the parameters of its executable in the format of ImportParameters.
+package packs
+
+import "embed"
+
+//go:embed */pack.json */catalog.json
+var FS embed.FS
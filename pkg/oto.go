@@ -1,42 +1,63 @@
 package oto
 
 import (
+	"errors"
 	"fmt"
 	"time"
-	"bytes"
 	"context"
-	"os/exec"
+	"slices"
 
 	"github.com/Bl4omArchie/fme"
 	"github.com/Bl4omArchie/simple"
 	"go.temporal.io/sdk/client"
-	"go.temporal.io/sdk/worker"
 	"gorm.io/gorm"
 
 	"github.com/Bl4omArchie/oto/models"
 )
 
 type Instance struct {
+	Config         *Config
 	Database       *gorm.DB
-	ParamsSchema   map[string]fme.Schema
+	Repos          *models.Repositories
 	TemporalClient client.Client
-	Workers map[string]WorkerItem
-}
+	Events         *EventBus
+	Workers        *WorkerManager
+	Runner         Runner
+	Scheduler      *Scheduler
 
-type WorkerItem struct {
-	WorkerID string
-	Worker worker.Worker
-	OutputError chan(error)
+	schedulerStarted bool
+	schemas          *schemaRegistry
 }
 
+// Storage backends, selected by OTO_DATABASE.
+const (
+	DatabasePostgres = "postgres"
+	DatabaseSqlite   = "sqlite"
+)
+
 type Config struct {
-	PostgresDb        string `env:"POSTGRES_DB,required"`
-	PostgresUser      string `env:"POSTGRES_USER,required"`
-	PostgresPassword  string `env:"POSTGRES_PASSWORD,required"`
-	PostgresPort      string `env:"POSTGRES_PORT,required"`
-	PostgresSeed      string `env:"POSTGRES_SEED,required"`
-	TemporalHost      string `env:"TEMPORAL_HOST,required"`
-	TemporalNamespace string `env:"TEMPORAL_NAMESPACE,required"`
+	// Standalone runs OTO as a single process : SQLite storage and the local runner, whatever OTO_DATABASE and OTO_RUNNER are
+	Standalone        bool   `env:"OTO_STANDALONE"`
+	Database          string `env:"OTO_DATABASE" envDefault:"postgres"`
+	SqlitePath        string `env:"OTO_SQLITE_PATH" envDefault:"oto.db"`
+	PostgresHost      string `env:"POSTGRES_HOST" envDefault:"localhost"`
+	PostgresDb        string `env:"POSTGRES_DB"`
+	PostgresUser      string `env:"POSTGRES_USER"`
+	PostgresPassword  string `env:"POSTGRES_PASSWORD"`
+	PostgresPort      string `env:"POSTGRES_PORT" envDefault:"5432"`
+	PostgresSeed      string `env:"POSTGRES_SEED"`
+	Runner            string `env:"OTO_RUNNER" envDefault:"temporal"`
+	LocalConcurrency  int    `env:"OTO_LOCAL_CONCURRENCY" envDefault:"4"`
+	TemporalHost      string `env:"TEMPORAL_HOST" envDefault:"localhost:7233"`
+	TemporalNamespace string `env:"TEMPORAL_NAMESPACE" envDefault:"default"`
+	TemporalTaskQueue string `env:"TEMPORAL_TASK_QUEUE" envDefault:"oto-tasks"`
+	KillGracePeriod   time.Duration `env:"OTO_KILL_GRACE_PERIOD" envDefault:"10s"`
+	WorkerStopTimeout time.Duration `env:"OTO_WORKER_STOP_TIMEOUT" envDefault:"30s"`
+	// MaxRuns limits the runs in progress across every worker, 0 means no limit
+	MaxRuns int    `env:"OTO_MAX_RUNS" envDefault:"0"`
+	OnLimit string `env:"OTO_ON_LIMIT" envDefault:"queue"`
+	// IdempotencyWindow is how long a run trigger can be repeated with the same idempotency key
+	IdempotencyWindow time.Duration `env:"OTO_IDEMPOTENCY_WINDOW" envDefault:"24h"`
 }
 
 func NewInstanceOto(envPath string) (*Instance, error) {
@@ -45,268 +66,653 @@ func NewInstanceOto(envPath string) (*Instance, error) {
 		return nil, err
 	}
 
-	db, err := simple.OpenDatabase(simple.GetPostgres("localhost", cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDb, cfg.PostgresPort))
-	if err != nil {
-		return nil, err
+	if cfg.Standalone {
+		cfg.Database = DatabaseSqlite
+		cfg.Runner = RunnerLocal
+	}
+	if err := cfg.globalLimit().Validate(); err != nil {
+		return nil, fmt.Errorf("invalid OTO_MAX_RUNS or OTO_ON_LIMIT: %w", err)
 	}
 
-	client, err := client.Dial(client.Options{
-		HostPort:  cfg.TemporalHost,
-		Namespace: cfg.TemporalNamespace,
-	})
+	db, err := openDatabase(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't get the temporal client. %v", err)
+		return nil, err
+	}
+	// AutoMigrate is the only supported way to create and update the schema, before any runner reads it
+	if err := migrateModels(db); err != nil {
+		closeDatabase(db)
+		return nil, fmt.Errorf("failed to migrate the database: %w", err)
 	}
 
 	instance := &Instance{
-		Database:       db,
-		ParamsSchema:   make(map[string]fme.Schema, 0),
-		TemporalClient: client,
+		Config:       cfg,
+		Database:     db,
+		Repos:        models.NewGormRepositories(db),
+		Events:       NewEventBus(),
+		schemas:      newSchemaRegistry(),
+	}
+
+	switch cfg.Runner {
+	case RunnerLocal:
+		// No Temporal server is needed, jobs are run by this process
+		instance.Runner = NewLocalRunner(db, instance.Repos, instance.killGracePeriod(), cfg.LocalConcurrency, cfg.globalLimit(), cfg.IdempotencyWindow)
+	case RunnerTemporal:
+		client, err := client.Dial(client.Options{
+			HostPort:  cfg.TemporalHost,
+			Namespace: cfg.TemporalNamespace,
+		})
+		if err != nil {
+			closeDatabase(db)
+			return nil, fmt.Errorf("couldn't get the temporal client. %v", err)
+		}
+
+		acts := &Activities{DB: db, Repos: instance.Repos, Events: instance.Events, TaskQueue: instance.taskQueue(), KillGracePeriod: instance.killGracePeriod(), Concurrency: cfg.globalLimit()}
+		instance.TemporalClient = client
+		instance.Workers = NewWorkerManager(client, acts, cfg.TemporalTaskQueue, cfg.WorkerStopTimeout)
+		instance.Runner = NewTemporalRunner(client, db, instance.Repos, instance.taskQueue(), cfg.IdempotencyWindow)
+	default:
+		closeDatabase(db)
+		return nil, fmt.Errorf("unknown runner %q, use %q or %q", cfg.Runner, RunnerTemporal, RunnerLocal)
 	}
 
-	// tmp : automigrate with gorm until we deploy atlas completly
-	instance.Database.AutoMigrate(&models.Executable{}, &models.Parameter{}, &models.Command{}, &models.Job{}, &models.FlagValue{})
+	if err := instance.schemas.load(context.Background(), instance.Repos); err != nil {
+		// The runner closes the Temporal client
+		instance.Runner.Close()
+		closeDatabase(db)
+		return nil, err
+	}
+	instance.Scheduler = NewScheduler(db, instance.Repos.Schedules, instance.Runner)
 	return instance, nil
 }
 
+// globalLimit is the concurrency limit counting every run.
+func (cfg *Config) globalLimit() models.ConcurrencyLimit {
+	return models.ConcurrencyLimit{MaxRuns: cfg.MaxRuns, OnLimit: models.LimitBehavior(cfg.OnLimit)}
+}
+
+func openDatabase(cfg *Config) (*gorm.DB, error) {
+	switch cfg.Database {
+	case DatabaseSqlite:
+		// SQLite allows a single writer, concurrent runs must wait for the lock instead of failing
+		return simple.OpenDatabase(simple.GetSqlite(cfg.SqlitePath + "?_busy_timeout=5000&_journal_mode=WAL"))
+	case DatabasePostgres:
+		if cfg.PostgresDb == "" || cfg.PostgresUser == "" {
+			return nil, fmt.Errorf("POSTGRES_DB and POSTGRES_USER are required with the postgres database, or set OTO_STANDALONE=true")
+		}
+		return simple.OpenDatabase(simple.GetPostgres(cfg.PostgresHost, cfg.PostgresUser, cfg.PostgresPassword, cfg.PostgresDb, cfg.PostgresPort))
+	default:
+		return nil, fmt.Errorf("unknown database %q, use %q or %q", cfg.Database, DatabasePostgres, DatabaseSqlite)
+	}
+}
+
+// closeDatabase closes the connections of an instance which couldn't be built.
+func closeDatabase(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+func migrateModels(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Executable{}, &models.Parameter{}, &models.Command{}, &models.Job{}, &models.FlagValue{}, &models.Run{}, &models.RunAttempt{}, &models.Workflow{}, &models.WorkflowNode{}, &models.WorkflowRun{}, &models.Approval{}, &models.Worker{}, &models.Schedule{}, &models.RunLease{})
+}
+
 // === Add data ===
 
 func (i *Instance) AddExecutable(name, version, executablePath, description string) error {
 	exec := models.NewExecutable(name, version, executablePath, description)
-	if err := i.Database.Save(exec).Error; err != nil {
+	if err := i.Repos.Executables.Create(context.Background(), exec); err != nil {
 		return fmt.Errorf("failed to save Executable: %w", err)
 	}
 
 	return nil
 }
 
-func (i *Instance) AddParameter(ctx context.Context, execTag, flag, description string, requiresRoot, requiresValue bool, valueType models.ValueType, Require, InterfersWith []string, s *fme.Schema) error {
+// AddParameter saves a parameter of the executable. It is refused when its relations make the schema of the executable invalid.
+func (i *Instance) AddParameter(ctx context.Context, execTag, flag, description string, requiresRoot, requiresValue bool, valueType models.ValueType, Require, InterfersWith []string) error {
+	return i.AddParameterRaw(ctx, models.NewParameterRaw(flag, description, execTag, requiresRoot, requiresValue, valueType, Require, InterfersWith))
+}
+
+// AddParameterRaw saves a parameter given in the catalog format, with its groups, cardinality and value rules.
+func (i *Instance) AddParameterRaw(ctx context.Context, raw *models.ParameterRaw) error {
 	// Retrieve executable
-	exec, err := models.FetchExecutable(ctx, i.Database, "tag", execTag)
+	exec, err := i.Repos.Executables.GetByTag(ctx, raw.ExecutableTag)
 	if err != nil {
 		return err
 	}
 
-	// Retrieve dependencies
-	RequireToSave, err := models.FetchFlagParameters(ctx, i.Database, "flag", Require)
+	// Retrieve dependencies among the parameters of the executable. Flags are never nil : a nil filter would match every parameter
+	RequireToSave, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID, Flags: append([]string{}, raw.RequireIDs...)})
 	if err != nil {
 		return err
 	}
-	InterfersWithToSave, err := models.FetchFlagParameters(ctx, i.Database, "flag", InterfersWith)
+	InterfersWithToSave, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID, Flags: append([]string{}, raw.InterferIDs...)})
 	if err != nil {
 		return err
 	}
 
-	// Verify dependencies correctness
-	for _, depends := range RequireToSave {
-		if ok, err := s.Require(flag, depends.Flag); ok == false {
-			return fmt.Errorf("incorrect `requirement` dependency : %v", err)
-		}
-	}
-	for _, Interfer := range InterfersWithToSave {
-		if ok, err := s.Interfer(flag, Interfer.Flag); ok == false {
-			return fmt.Errorf("incorrect `interference` dependency : %v", err)
+	// Verify dependencies correctness, the schema is updated once the parameter is saved
+	param := parameterFromRaw(raw, exec, RequireToSave, InterfersWithToSave)
+	return i.schemas.addParameter(ctx, i.Repos, exec, param, func() error {
+		if err := i.Repos.Parameters.Create(ctx, param); err != nil {
+			return fmt.Errorf("failed to parameter : %w", err)
 		}
-	}
+		return nil
+	})
+}
 
-	param := models.NewParameter(flag, description, exec, requiresRoot, requiresValue, valueType, InterfersWithToSave, RequireToSave)
-	if err := i.Database.Save(param).Error; err != nil {
-		return fmt.Errorf("failed to parameter : %w", err)
-	}
-	return nil
+// parameterFromRaw returns the parameter of the catalog entry, with its resolved relations.
+func parameterFromRaw(raw *models.ParameterRaw, exec *models.Executable, require, interfer []models.Parameter) *models.Parameter {
+	param := models.NewParameter(raw.Flag, raw.Description, exec, raw.RequiresRoot, raw.RequiresValue, raw.ValueType, require, interfer)
+	param.Repeatable = raw.Repeatable
+	param.OneOf = raw.OneOf
+	param.AtLeastOne = raw.AtLeastOne
+	param.ValueRules = raw.ValueRules
+	return param
 }
 
-func (i *Instance) AddCommand(ctx context.Context, execID, cmdName, description string, flags []string, s *fme.Schema) error {
-	exec, err := models.FetchExecutable(ctx, i.Database, "tag", execID)
+// AddCommand saves a command of the executable. A CombinationError is returned when its flags don't match the schema.
+func (i *Instance) AddCommand(ctx context.Context, execTag, cmdName, description string, flags []string) error {
+	exec, err := i.Repos.Executables.GetByTag(ctx, execTag)
 	if err != nil {
 		return err
 	}
 
-	flagsToSave, err := models.FetchFlagParameters(ctx, i.Database, "flag", flags)
+	// FME : check if the given flags are valid before ingestion
+	d, err := i.ValidateFlags(ctx, exec.Tag, flags)
 	if err != nil {
 		return err
 	}
+	if !d.Valid {
+		return &CombinationError{Diagnostic: d}
+	}
 
-	// FME : check if the given flags are valid before ingestion
-	_, err = s.ValidateCombination(flags)
+	flagsToSave, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID, Flags: uniqueFlags(flags)})
 	if err != nil {
 		return err
 	}
 
 	cmd := models.NewCommand(cmdName, description, exec, flagsToSave)
-	if err := i.Database.Save(cmd).Error; err != nil {
+	if err := i.Repos.Commands.Create(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to save command: %w", err)
 	}
 	return nil
 }
 
-func (i *Instance) AddJob(ctx context.Context, cmdName, jobName string, flagValues map[string]string) error {
-	cmd, err := models.FetchCommand(ctx, i.Database, "name", cmdName)
+// uniqueFlags returns the flags of a command without repetition : a repeatable flag is saved once, its values are given by the jobs.
+// It is never nil, a nil filter would match every parameter.
+func uniqueFlags(flags []string) []string {
+	unique := []string{}
+	for _, flag := range flags {
+		if !slices.Contains(unique, flag) {
+			unique = append(unique, flag)
+		}
+	}
+	return unique
+}
+
+// JobFlagValue is a value given to a flag of a job. A repeatable flag has one entry per value.
+type JobFlagValue struct {
+	Flag  string `json:"flag"`
+	Value string `json:"value"`
+}
+
+// AddJob saves a job of the command with its values, in the given order. A repeatable flag may be given several values.
+func (i *Instance) AddJob(ctx context.Context, cmdName, jobName string, flagValues []JobFlagValue) error {
+	cmd, err := i.Repos.Commands.GetByName(ctx, cmdName)
 	if err != nil {
 		return err
 	}
 
+	// Values are given to the flags of the command, which belong to its executable
 	var flagValuesToSave []*models.FlagValue
-	for flag, value := range flagValues {
-		param, err := simple.GetRowBy[models.Parameter](ctx, i.Database, "flag", flag)
-		if err != nil {
-			return fmt.Errorf("in your flag values, you indicated a parameter %s that doesn't belong to your command %s. %v", flag, cmd.Name, err)
+	for _, fv := range flagValues {
+		n := slices.IndexFunc(cmd.Parameters, func(p models.Parameter) bool { return p.Flag == fv.Flag })
+		if n < 0 {
+			return &CombinationError{Diagnostic: diagnoseJobFlags(cmd, flagValues)}
 		}
-		flagValuesToSave = append(flagValuesToSave, models.NewFlagValue(param, value))
+		flagValuesToSave = append(flagValuesToSave, models.NewFlagValue(&cmd.Parameters[n], fv.Value))
+	}
+
+	// Values rules, e.g. -pkeyopt rsa_keygen_bits only with -algorithm RSA
+	if d := DiagnoseValues(cmd.Executable.Tag, flagValuesToSave); !d.Valid {
+		return &CombinationError{Diagnostic: d}
 	}
 
 	job := models.NewJob(jobName, cmd, flagValuesToSave)
-	if err := i.Database.Save(job).Error; err != nil {
+	if err := i.Repos.Jobs.Create(ctx, job); err != nil {
 		return fmt.Errorf("failed to save job command: %w", err)
 	}
 	return nil
 }
 
-func (i *Instance) ImportParameters(ctx context.Context, filename string, s *fme.Schema) error {
-	params, err := simple.LoadFile[models.ParameterRaw](filename, -1, true)
+// ImportParameters saves the parameters of a catalog file, whatever their order : the parameters are created first,
+// then their relations are linked. The whole catalog is checked before anything is saved, and every problem is reported
+// in an ErrInvalidCatalog. The parameters are saved in a single transaction.
+func (i *Instance) ImportParameters(ctx context.Context, filename string) error {
+	catalog, err := LoadCatalog(filename)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range params {
-		err := i.AddParameter(ctx, p.ExecutableTag, p.Flag, p.Description, p.RequiresRoot, p.RequiresValue, p.ValueType, p.RequireIDs, p.InterferIDs, s)
+	// Entries by executable, in the order of the file
+	var tags []string
+	byTag := make(map[string][]models.ParameterRaw)
+	for _, raw := range catalog {
+		if _, ok := byTag[raw.ExecutableTag]; !ok {
+			tags = append(tags, raw.ExecutableTag)
+		}
+		byTag[raw.ExecutableTag] = append(byTag[raw.ExecutableTag], raw)
+	}
+
+	i.schemas.changes.Lock()
+	defer i.schemas.changes.Unlock()
+
+	var problems []error
+	execs := make(map[string]*models.Executable)
+	schemas := make(map[string]*fme.Schema)
+	for _, tag := range tags {
+		exec, err := i.Repos.Executables.GetByTag(ctx, tag)
 		if err != nil {
-			return fmt.Errorf("failed to add parameter %s: %w", p.Flag, err)
+			problems = append(problems, fmt.Errorf("executable %s: %w", tag, err))
+			continue
 		}
+		existing, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+		if err != nil {
+			return err
+		}
+		s, errs := checkCatalog(exec, existing, byTag[tag])
+		for _, err := range errs {
+			problems = append(problems, fmt.Errorf("executable %s: %w", tag, err))
+		}
+		execs[tag], schemas[tag] = exec, s
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidCatalog, filename, errors.Join(problems...))
+	}
+
+	err = i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		for _, tag := range tags {
+			if err := saveCatalog(ctx, tx, execs[tag], byTag[tag]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		i.schemas.publish(tag, schemas[tag])
 	}
 	return nil
 }
 
-// Tmp function for demo only. This will be erased when Temporal we'll be fully integrated.
-func (i *Instance) RunJobDemo(ctx context.Context, jobName string) (*JobOutput, error) {
-	job, err := models.FetchJob(ctx, i.Database, "name", jobName)
+// == FME ===
+
+// Schema returns the FME schema of the executable, kept in sync with its parameters. It mustn't be modified.
+func (i *Instance) Schema(ctx context.Context, execTag string) (*fme.Schema, error) {
+	exec, err := i.Repos.Executables.GetByTag(ctx, execTag)
 	if err != nil {
 		return nil, err
 	}
+	return i.schemas.get(ctx, i.Repos, exec)
+}
 
-	var header string = job.Command.Executable.Path
-	var args []string
 
-	if job.Command.RequiresRoot {
-		args = append(args, job.Command.Executable.Path)
-		header = "sudo"
-	}
+// === Temporal ===
 
-	for _, fv := range job.FlagValues {
-		// If the argument doesn't have value, we just add the flag to avoid a whitespace
-		if fv.Value == "" {
-			args = append(args, fv.Parameter.Flag)
-		} else {
-			args = append(args, fv.Parameter.Flag, fv.Value)
-		}
+// AddWorker creates a new worker with the given ID. The worker will then be runned concurrently.
+func (i *Instance) AddWorker(workerID string, opts WorkerOptions) error {
+	if i.Workers == nil {
+		return ErrTemporalRequired
 	}
+	return i.Workers.Start(workerID, opts)
+}
 
-	fmt.Println(header, args)
+// StopWorker stops the worker. Wait for in-going tasks to finish, and then the worker will stop.
+func (i *Instance) StopWorker(workerID string) error {
+	if i.Workers == nil {
+		return ErrTemporalRequired
+	}
+	return i.Workers.Stop(workerID)
+}
 
-	var stdout, stderr bytes.Buffer
-	cmd := exec.CommandContext(ctx, header, args...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// ListWorkers returns the status of every worker, with the error that stopped it if any.
+func (i *Instance) ListWorkers() []WorkerInfo {
+	if i.Workers == nil {
+		return []WorkerInfo{}
+	}
+	return i.Workers.List()
+}
 
-	err = cmd.Run()
-	return &JobOutput{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
-	}, err
+// Close stops the scheduler and drains every worker, then closes the runner.
+func (i *Instance) Close() {
+	if i.schedulerStarted {
+		i.Scheduler.Stop()
+	}
+	if i.Workers != nil {
+		i.Workers.Shutdown()
+	}
+	i.Runner.Close()
 }
 
+// RunJob runs the job and blocks until it is done. Use StartJobRun to avoid blocking.
+func (i *Instance) RunJob(ctx context.Context, jobName string) (*JobOutput, error) {
+	run, err := i.StartJobRun(ctx, jobName, RunOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return i.WaitRun(ctx, run.ID, 0)
+}
 
-// == FME ===
-func (i *Instance) AddExecutableSchema(ctx context.Context, execTag string) (*fme.Schema, error) {
-	s := fme.NewSchema()
+// StartJobRun starts a run of the job with the runner and returns it immediately, with its run ID and workflow ID.
+func (i *Instance) StartJobRun(ctx context.Context, jobName string, opts RunOptions) (*models.Run, error) {
+	return i.Runner.Start(ctx, jobName, opts)
+}
 
-	exec, err := models.FetchExecutable(ctx, i.Database, "tag", execTag)
+// GetRunState returns the current state of a run, for polling, with its position in the queue while it isn't dispatched.
+func (i *Instance) GetRunState(ctx context.Context, runID uint) (*RunState, error) {
+	run, err := i.Repos.Runs.Get(ctx, runID)
 	if err != nil {
 		return nil, err
 	}
 
-	params, err := models.FetchParameters(ctx, i.Database, "id", exec.ID)
+	state := NewRunState(run)
+	state.QueuePosition, err = models.QueuePosition(ctx, i.Database, run, waiterStaleAfter)
 	if err != nil {
 		return nil, err
 	}
+	return state, nil
+}
 
-	for _, param := range params {
-		for _, dependency := range param.Require {
-			s.Require(param.Flag, dependency.Flag)
-		}
+// WaitRun waits for the end of a run and returns its output.
+// With a timeout greater than zero, ErrWaitTimeout is returned if the run isn't done in time, the run itself goes on.
+func (i *Instance) WaitRun(ctx context.Context, runID uint, timeout time.Duration) (*JobOutput, error) {
+	return i.Runner.Wait(ctx, runID, timeout)
+}
+
+// === Workflows ===
 
-		for _, Interfer := range param.Interfer {
-			s.Interfer(param.Flag, Interfer.Flag)
+// AddWorkflow saves a workflow made of the given nodes, run in the given order.
+func (i *Instance) AddWorkflow(ctx context.Context, name, description string, nodes []models.WorkflowNodeRaw) error {
+	nodesToSave, err := workflowNodes(ctx, i.Repos, nodes)
+	if err != nil {
+		return err
+	}
+
+	wf := models.NewWorkflow(name, description, nodesToSave)
+	if err := i.Repos.Workflows.Create(ctx, wf); err != nil {
+		return fmt.Errorf("failed to save workflow: %w", err)
+	}
+	return nil
+}
+
+// workflowNodes returns the nodes of a workflow, positioned in the given order.
+func workflowNodes(ctx context.Context, repos *models.Repositories, nodes []models.WorkflowNodeRaw) ([]models.WorkflowNode, error) {
+	var nodesToSave []models.WorkflowNode
+	for position, raw := range nodes {
+		switch raw.Kind {
+		case models.NodeJob:
+			job, err := repos.Jobs.GetByName(ctx, raw.Job)
+			if err != nil {
+				return nil, fmt.Errorf("node %d: couldn't find job %s. %w", position, raw.Job, err)
+			}
+			nodesToSave = append(nodesToSave, *models.NewJobNode(position, job, raw.Priority))
+		case models.NodeApproval:
+			nodesToSave = append(nodesToSave, *models.NewApprovalNode(position, raw.Approvers, raw.Timeout))
+		default:
+			return nil, fmt.Errorf("node %d: unknown node kind %q", position, raw.Kind)
 		}
 	}
+	return nodesToSave, nil
+}
+
+// StartWorkflowRun starts a run of the workflow and returns without waiting for it.
+func (i *Instance) StartWorkflowRun(ctx context.Context, name string) (*models.WorkflowRun, error) {
+	if i.TemporalClient == nil {
+		return nil, ErrTemporalRequired
+	}
+
+	wf, err := i.Repos.Workflows.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 
-	err = s.ValidateSchema()
+	// Job nodes are routed on their own, the workflow itself can be run by any worker
+	taskQueue, err := selectTaskQueue(ctx, i.Database, nil, i.taskQueue())
 	if err != nil {
 		return nil, err
 	}
-	i.ParamsSchema[execTag] = *s
-	return s, nil
+
+	run := models.NewWorkflowRun(wf)
+	run.WorkflowID = fmt.Sprintf("workflow-%s-%d", name, time.Now().UnixNano())
+	if err := i.Repos.WorkflowRuns.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to save workflow run: %w", err)
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        run.WorkflowID,
+		TaskQueue: taskQueue,
+	}
+
+	input := RunWorkflowInput{WorkflowRunID: int(run.ID), Nodes: wf.Nodes}
+	if _, err := i.TemporalClient.ExecuteWorkflow(ctx, workflowOptions, WorkflowRunWorkflow, input); err != nil {
+		return nil, err
+	}
+	return run, nil
 }
 
+// GetWorkflowRun returns a workflow run with the runs of its jobs and its approvals.
+func (i *Instance) GetWorkflowRun(ctx context.Context, workflowRunID uint) (*models.WorkflowRun, error) {
+	return i.Repos.WorkflowRuns.Get(ctx, workflowRunID)
+}
 
-// === Temporal ===
-func (i *Instance) NewWorkerItem(workerID string) WorkerItem {
-	return WorkerItem{
-		WorkerID: workerID,
-		Worker: worker.New(i.TemporalClient, workerID, worker.Options{}),
-		OutputError: make(chan error),
+// ApproveRun approves the pending approval of a workflow run, which resumes.
+func (i *Instance) ApproveRun(ctx context.Context, workflowRunID uint, by, comment string) error {
+	return i.decideApproval(ctx, workflowRunID, true, by, comment)
+}
+
+// RejectRun rejects the pending approval of a workflow run, which stops.
+func (i *Instance) RejectRun(ctx context.Context, workflowRunID uint, by, comment string) error {
+	return i.decideApproval(ctx, workflowRunID, false, by, comment)
+}
+
+func (i *Instance) decideApproval(ctx context.Context, workflowRunID uint, approved bool, by, comment string) error {
+	if i.TemporalClient == nil {
+		return ErrTemporalRequired
+	}
+	if by == "" {
+		return fmt.Errorf("the author of the decision is required")
+	}
+
+	run, err := i.Repos.WorkflowRuns.Get(ctx, workflowRunID)
+	if err != nil {
+		return err
+	}
+
+	approval, err := models.FetchPendingApproval(ctx, i.Database, run.ID)
+	if err != nil {
+		return fmt.Errorf("workflow run %d isn't waiting for an approval. %w", run.ID, err)
+	}
+	if !approval.CanDecide(by) {
+		return fmt.Errorf("%s isn't an approver of workflow run %d", by, run.ID)
+	}
+
+	decision := ApprovalDecision{ApprovalID: approval.ID, Approved: approved, By: by, Comment: comment}
+	if err := i.TemporalClient.SignalWorkflow(ctx, run.WorkflowID, "", ApprovalSignal, decision); err != nil {
+		return fmt.Errorf("failed to send the decision to workflow run %d: %w", run.ID, err)
 	}
+	return nil
+}
+
+// === Runs ===
+
+// CancelRun asks the runner to cancel the run. The process is stopped with SIGTERM, then SIGKILL after the grace period.
+// The output produced so far is kept and the run is marked as cancelled once the process is stopped.
+func (i *Instance) CancelRun(ctx context.Context, runID uint) error {
+	return i.Runner.Cancel(ctx, runID)
 }
 
-// Create a new worker with the given ID. The worker will then be runned concurrently.
-func (i *Instance) AddWorker(workerID string) error {
-	if _, ok := i.Workers[workerID]; ok {
-		return fmt.Errorf("worker %s already.", workerID)
+func (i *Instance) taskQueue() string {
+	if i.Config == nil || i.Config.TemporalTaskQueue == "" {
+		return DefaultTaskQueue
 	}
+	return i.Config.TemporalTaskQueue
+}
 
-	w := i.NewWorkerItem(workerID)
-	i.Workers[workerID] = w
+func (i *Instance) killGracePeriod() time.Duration {
+	if i.Config == nil || i.Config.KillGracePeriod <= 0 {
+		return DefaultKillGracePeriod
+	}
+	return i.Config.KillGracePeriod
+}
 
-	acts := &Activities{DB: i.Database}
+// SetCommandRetryPolicy changes the retry policy inherited by every job of the command.
+func (i *Instance) SetCommandRetryPolicy(ctx context.Context, cmdName string, policy models.RetryPolicy) error {
+	cmd, err := i.Repos.Commands.GetByName(ctx, cmdName)
+	if err != nil {
+		return err
+	}
 
-	w.Worker.RegisterWorkflow(WorkflowRunJob)
-	w.Worker.RegisterActivity(acts.RunJob)
+	if err := i.Repos.Commands.SaveRetryPolicy(ctx, cmd.ID, policy); err != nil {
+		return fmt.Errorf("failed to save retry policy: %w", err)
+	}
+	return nil
+}
 
-	go func() {
-		if err := w.Worker.Run(worker.InterruptCh()); err != nil {
-			w.OutputError <- err
-		}
-	}()
+// SetJobRetryPolicy changes the retry policy of a job. Unset fields are inherited from its command.
+func (i *Instance) SetJobRetryPolicy(ctx context.Context, jobName string, policy models.RetryPolicy) error {
+	job, err := i.Repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return err
+	}
 
+	if err := i.Repos.Jobs.SaveRetryPolicy(ctx, job.ID, policy); err != nil {
+		return fmt.Errorf("failed to save retry policy: %w", err)
+	}
 	return nil
 }
 
-// Stop the worker. Wait for in-going tasks to finish, and then the worker will stop.
-func (i *Instance) StopWorker(workerID string) error {
-	w, ok := i.Workers[workerID]
-	if !ok {
-		return fmt.Errorf("couldn't find workerID. First create the worker.")
+// SetExecutableConcurrency limits the runs in progress at once of every job using the executable.
+func (i *Instance) SetExecutableConcurrency(ctx context.Context, execTag string, limit models.ConcurrencyLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	exec, err := i.Repos.Executables.GetByTag(ctx, execTag)
+	if err != nil {
+		return err
 	}
 
-	w.Worker.Stop()
+	if err := i.Repos.Executables.SaveConcurrency(ctx, exec.ID, limit); err != nil {
+		return fmt.Errorf("failed to save concurrency limit: %w", err)
+	}
 	return nil
 }
 
-func (i *Instance) RunJobWorkflow(ctx context.Context, jobName string) (*JobOutput, error) {
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        fmt.Sprintf("job-%s-%d", jobName, time.Now().UnixNano()),
-		TaskQueue: "oto-tasks",
+// SetCommandConcurrency limits the runs in progress at once of every job of the command.
+func (i *Instance) SetCommandConcurrency(ctx context.Context, cmdName string, limit models.ConcurrencyLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	cmd, err := i.Repos.Commands.GetByName(ctx, cmdName)
+	if err != nil {
+		return err
 	}
 
-	handle, err := i.TemporalClient.ExecuteWorkflow(ctx, workflowOptions, WorkflowRunJob, jobName)
+	if err := i.Repos.Commands.SaveConcurrency(ctx, cmd.ID, limit); err != nil {
+		return fmt.Errorf("failed to save concurrency limit: %w", err)
+	}
+	return nil
+}
+
+// SetJobConcurrency limits the runs of a job in progress at once. The limits of its command and executable still apply.
+func (i *Instance) SetJobConcurrency(ctx context.Context, jobName string, limit models.ConcurrencyLimit) error {
+	if err := limit.Validate(); err != nil {
+		return err
+	}
+	job, err := i.Repos.Jobs.GetByName(ctx, jobName)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var result JobOutput
-	if err := handle.Get(ctx, &result); err != nil {
+	if err := i.Repos.Jobs.SaveConcurrency(ctx, job.ID, limit); err != nil {
+		return fmt.Errorf("failed to save concurrency limit: %w", err)
+	}
+	return nil
+}
+
+// GetRun returns a run with every attempt made for it.
+func (i *Instance) GetRun(ctx context.Context, runID uint) (*models.Run, error) {
+	return i.Repos.Runs.Get(ctx, runID)
+}
+
+// GetJobRuns returns the run history of a job, most recent first.
+func (i *Instance) GetJobRuns(ctx context.Context, jobName string) ([]models.Run, error) {
+	job, err := i.Repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
 		return nil, err
 	}
+	return i.Repos.Runs.ListByJob(ctx, job.ID)
+}
+
+// === Schedules ===
+
+// StartScheduler triggers the due schedules in the background until the instance is closed.
+func (i *Instance) StartScheduler() {
+	if i.schedulerStarted {
+		return
+	}
+	i.schedulerStarted = true
+	go i.Scheduler.Run()
+}
 
-	return &result, nil
+// AddSchedule saves a schedule running the job each time spec is due. See models.Schedule for the syntax of spec.
+func (i *Instance) AddSchedule(ctx context.Context, name, jobName, spec string, priority *int) error {
+	job, err := i.Repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return err
+	}
+
+	next, err := ParseSchedule(spec, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	schedule := models.NewSchedule(name, job, spec, next)
+	schedule.Priority = priority
+	if err := i.Repos.Schedules.Create(ctx, schedule); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+// GetSchedules returns every schedule, sorted by name.
+func (i *Instance) GetSchedules(ctx context.Context) ([]models.Schedule, error) {
+	return i.Repos.Schedules.List(ctx)
+}
+
+// SetScheduleEnabled pauses or resumes a schedule. A resumed schedule is next due after now, missed runs aren't caught up.
+func (i *Instance) SetScheduleEnabled(ctx context.Context, name string, enabled bool) error {
+	schedule, err := i.Repos.Schedules.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	next := schedule.NextRunAt
+	if enabled && !schedule.Enabled {
+		next, err = ParseSchedule(schedule.Spec, time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q: %w", schedule.Spec, err)
+		}
+	}
+	return i.Repos.Schedules.SetEnabled(ctx, schedule.ID, enabled, next)
+}
+
+// DeleteSchedule removes a schedule. Its past runs are kept.
+func (i *Instance) DeleteSchedule(ctx context.Context, name string) error {
+	schedule, err := i.Repos.Schedules.GetByName(ctx, name)
+	if err != nil {
+		return err
+	}
+	return i.Repos.Schedules.Delete(ctx, schedule.ID)
 }
@@ -1,50 +1,214 @@
 package oto
 
 import (
-	"bytes"
 	"context"
-	"os/exec"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/Bl4omArchie/oto/models"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 	"gorm.io/gorm"
 )
 
 type Activities struct {
-	DB *gorm.DB
+	DB              *gorm.DB
+	Repos           *models.Repositories
+	Events          *EventBus
+	TaskQueue       string
+	KillGracePeriod time.Duration
+	// Concurrency is the global limit, counting the runs of every worker
+	Concurrency models.ConcurrencyLimit
 }
 
+// heartbeatInterval must stay below the HeartbeatTimeout of the activity options.
+const heartbeatInterval = 5 * time.Second
+
 type (
 	JobOutput struct {
 		Stdout string
 		Stderr string
 	}
+
+	// RunJobInput is given to WorkflowRunJob and to the RunJob activity.
+	RunJobInput struct {
+		RunID       int
+		JobName     string
+		TaskQueue   string
+		RetryPolicy models.RetryPolicy
+		Priority    int
+	}
+
+	// RunWorkflowInput is given to WorkflowRunWorkflow.
+	RunWorkflowInput struct {
+		WorkflowRunID int
+		Nodes         []models.WorkflowNode
+	}
+
+	// ApprovalDecision is the payload of the ApprovalSignal.
+	ApprovalDecision struct {
+		ApprovalID uint
+		Approved   bool
+		Expired    bool
+		By         string
+		Comment    string
+	}
 )
 
-func (a *Activities) RunJob(ctx context.Context, jobName string) (*JobOutput, error) {
-	job, err := models.FetchJob(ctx, a.DB, "name", jobName)
+func (a *Activities) RunJob(ctx context.Context, input RunJobInput) (*JobOutput, error) {
+	job, err := a.Repos.Jobs.GetByName(ctx, input.JobName)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("couldn't find job %s", input.JobName), ErrorJobNotFound, err)
+	}
+
+	// A newer run may have revoked the slot of this one during the backoff
+	number := activity.GetInfo(ctx).Attempt
+	if revoked, err := renewLeases(ctx, a.DB, input.RunID, leaseTTL); err != nil {
+		return nil, err
+	} else if revoked {
+		return nil, temporal.NewCanceledError()
+	}
+
+	// Each attempt of the retry policy is recorded in the run history
+	attempt, err := startAttempt(ctx, a.DB, input.RunID, number, job)
 	if err != nil {
 		return nil, err
 	}
 
-	header := job.Command.Executable.Path
-	if job.Command.RequiresRoot {
-		header = "sudo " + header
+	// Heartbeats are how Temporal delivers the cancellation of the run to the activity
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopHeartbeat := a.heartbeat(ctx)
+	stopLeases := holdLeases(runCtx, a.DB, input.RunID, cancel)
+	output, err := executeJob(runCtx, job, a.KillGracePeriod)
+	stopLeases()
+	stopHeartbeat()
+
+	finishAttempt(runCtx, a.DB, attempt, output, err)
+	if runCtx.Err() != nil {
+		return output, temporal.NewCanceledError()
 	}
+	if err != nil {
+		// The slots are kept until the next attempt
+		if _, err := renewLeases(ctx, a.DB, input.RunID, leaseTTL+input.RetryPolicy.Backoff(number)); err != nil {
+			activity.GetLogger(ctx).Warn("failed to keep the leases of the run", "error", err)
+		}
+		return output, classifyRunError(err)
+	}
+	return output, nil
+}
 
-	var args []string
-	for _, fv := range job.FlagValues {
-		args = append(args, fv.Parameter.Flag, fv.Value)
+// AcquireLeases waits for a slot in every concurrency limit of the job, before the first attempt of the run.
+func (a *Activities) AcquireLeases(ctx context.Context, input RunJobInput) error {
+	job, err := a.Repos.Jobs.GetByName(ctx, input.JobName)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("couldn't find job %s", input.JobName), ErrorJobNotFound, err)
 	}
 
-	var stdout, stderr bytes.Buffer
+	err = acquireLeases(ctx, a.DB, input.RunID, input.Priority, job.ConcurrencyLimits(a.Concurrency), func() { activity.RecordHeartbeat(ctx) })
+	switch {
+	case errors.Is(err, ErrRunSkipped):
+		return temporal.NewNonRetryableApplicationError(err.Error(), ErrorRunSkipped, err)
+	case ctx.Err() != nil:
+		return temporal.NewCanceledError()
+	}
+	return err
+}
+
+// FinishRun stores the final status of a run once every attempt is done.
+func (a *Activities) FinishRun(ctx context.Context, runID int, status models.RunStatus, errMsg string) error {
+	return finishRun(ctx, a.DB, runID, status, errMsg)
+}
 
-	cmd := exec.CommandContext(ctx, header, args...)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// CreateJobRun saves a new run for a job node of a workflow run. A nil priority keeps the one of the job.
+func (a *Activities) CreateJobRun(ctx context.Context, workflowRunID int, jobName, workflowID string, priority *int) (*RunJobInput, error) {
+	job, err := a.Repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return nil, err
+	}
+	return createJobRun(ctx, a.DB, a.Repos.Runs, job, job.RunPriority(priority), "", workflowID, &workflowRunID, a.TaskQueue)
+}
+
+// RequestApproval saves a pending approval for the node and notifies its approvers.
+func (a *Activities) RequestApproval(ctx context.Context, workflowRunID int, node models.WorkflowNode) (*models.Approval, error) {
+	approval := models.NewApproval(workflowRunID, &node)
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(approval).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WorkflowRun{}).Where("id = ?", workflowRunID).Update("status", models.RunAwaitingApproval).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save approval: %w", err)
+	}
+
+	if a.Events != nil {
+		a.Events.Publish(EventApprovalRequested, approval)
+	}
+	return approval, nil
+}
+
+// DecideApproval records who decided and what, then resumes the workflow run.
+func (a *Activities) DecideApproval(ctx context.Context, decision ApprovalDecision) error {
+	status := models.ApprovalRejected
+	switch {
+	case decision.Expired:
+		status = models.ApprovalExpired
+	case decision.Approved:
+		status = models.ApprovalApproved
+	}
+
+	var approval models.Approval
+	err := a.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&approval, decision.ApprovalID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		approval.Status = status
+		approval.DecidedBy = decision.By
+		approval.Comment = decision.Comment
+		approval.DecidedAt = &now
+		if err := tx.Save(&approval).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.WorkflowRun{}).Where("id = ?", approval.WorkflowRunID).Update("status", models.RunRunning).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save approval decision: %w", err)
+	}
+
+	if a.Events != nil {
+		a.Events.Publish(EventApprovalDecided, &approval)
+	}
+	return nil
+}
+
+// FinishWorkflowRun stores the final status of a workflow run.
+func (a *Activities) FinishWorkflowRun(ctx context.Context, workflowRunID int, status models.RunStatus, errMsg string) error {
+	now := time.Now()
+	return a.DB.WithContext(ctx).Model(&models.WorkflowRun{}).Where("id = ?", workflowRunID).Updates(map[string]any{
+		"status":      status,
+		"error":       errMsg,
+		"finished_at": &now,
+	}).Error
+}
 
-	err = cmd.Run()
-	return &JobOutput{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
-	}, err
+// heartbeat records a heartbeat regularly until the returned function is called.
+func (a *Activities) heartbeat(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				activity.RecordHeartbeat(ctx)
+			}
+		}
+	}()
+	return func() { close(done) }
 }
@@ -0,0 +1,906 @@
+package oto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// BundleVersion is the version of the bundle format written by ExportBundle. ImportBundle refuses the other versions.
+const BundleVersion = 1
+
+// ErrInvalidBundle is returned when a bundle can't be imported, with every problem found in it.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// Bundle is the whole configuration of OTO : executables with their parameters, commands, jobs, workflows and schedules.
+// Records refer to each other by their natural keys : the tag of an executable, the flag of a parameter, the name of the others.
+type Bundle struct {
+	Version     int                  `json:"version"`
+	Executables []BundleExecutable   `json:"executables"`
+	Commands    []BundleCommand      `json:"commands"`
+	Jobs        []BundleJob          `json:"jobs"`
+	Workflows   []BundleWorkflow     `json:"workflows"`
+	Schedules   []models.ScheduleRaw `json:"schedules"`
+}
+
+// BundleExecutable is an executable with its whole catalog, in the format of ImportParameters.
+// The executable_tag of the parameters is ignored.
+type BundleExecutable struct {
+	Name        string                  `json:"name"`
+	Version     string                  `json:"version"`
+	Path        string                  `json:"path"`
+	Description string                  `json:"description"`
+	Concurrency models.ConcurrencyLimit `json:"concurrency"`
+	Parameters  []models.ParameterRaw   `json:"parameters"`
+}
+
+// Tag returns the tag of the executable, its key in a bundle.
+func (e *BundleExecutable) Tag() string {
+	return models.GetTag(e.Name, e.Version)
+}
+
+type BundleCommand struct {
+	Name         string                  `json:"name"`
+	Description  string                  `json:"description"`
+	Executable   string                  `json:"executable"`
+	RequiresRoot bool                    `json:"requires_root"`
+	Flags        []string                `json:"flags"`
+	Retry        models.RetryPolicy      `json:"retry"`
+	Concurrency  models.ConcurrencyLimit `json:"concurrency"`
+}
+
+type BundleJob struct {
+	Name         string                  `json:"name"`
+	Command      string                  `json:"command"`
+	FlagValues   []JobFlagValue          `json:"flag_values"`
+	Retry        models.RetryPolicy      `json:"retry"`
+	Concurrency  models.ConcurrencyLimit `json:"concurrency"`
+	Priority     int                     `json:"priority"`
+	WorkerLabels map[string]string       `json:"worker_labels"`
+	WorkingDir   string                  `json:"working_dir,omitempty"`
+	Env          map[string]string       `json:"env,omitempty"`
+}
+
+type BundleWorkflow struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Nodes       []models.WorkflowNodeRaw `json:"nodes"`
+}
+
+// Actions of the changes of a plan.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Kinds of the records of a bundle.
+const (
+	KindExecutable = "executable"
+	KindParameter  = "parameter"
+	KindCommand    = "command"
+	KindJob        = "job"
+	KindWorkflow   = "workflow"
+	KindSchedule   = "schedule"
+)
+
+// Change is a record created, updated or deleted by an import. Fields are the updated fields, in the bundle format.
+type Change struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	// Executable is the tag of the executable of a parameter
+	Executable string        `json:"executable,omitempty"`
+	Fields     []FieldChange `json:"fields,omitempty"`
+}
+
+// Plan lists the changes of an import, in the order they are applied.
+type Plan struct {
+	DryRun  bool     `json:"dry_run"`
+	Changes []Change `json:"changes"`
+}
+
+// String returns the plan with a line per change, + for a creation, ~ for an update and - for a deletion.
+func (p *Plan) String() string {
+	symbols := map[string]string{ActionCreate: "+", ActionUpdate: "~", ActionDelete: "-"}
+
+	var b strings.Builder
+	for _, c := range p.Changes {
+		name := c.Name
+		if c.Executable != "" {
+			name = fmt.Sprintf("%s (%s)", c.Name, c.Executable)
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", symbols[c.Action], c.Kind, name)
+		for _, f := range c.Fields {
+			fmt.Fprintf(&b, "    %s: %s -> %s\n", f.Field, f.From, f.To)
+		}
+	}
+	if len(p.Changes) == 0 {
+		b.WriteString("no changes\n")
+	}
+	return b.String()
+}
+
+// action returns the action planned for the record, empty when it doesn't change.
+func (p *Plan) action(kind, executable, name string) string {
+	for _, c := range p.Changes {
+		if c.Kind == kind && c.Executable == executable && c.Name == name {
+			return c.Action
+		}
+	}
+	return ""
+}
+
+// LoadBundle reads a bundle file. Unknown fields are refused, they are likely typos.
+func LoadBundle(filename string) (*Bundle, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var b Bundle
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&b); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidBundle, filename, err)
+	}
+	return &b, nil
+}
+
+// ExportBundle returns the whole configuration, which ImportBundle restores.
+func (i *Instance) ExportBundle(ctx context.Context) (*Bundle, error) {
+	b := &Bundle{Version: BundleVersion, Executables: []BundleExecutable{}, Commands: []BundleCommand{},
+		Jobs: []BundleJob{}, Workflows: []BundleWorkflow{}, Schedules: []models.ScheduleRaw{}}
+
+	execs, err := i.Repos.Executables.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for n := range execs {
+		exec := &execs[n]
+		catalog, err := catalogOf(ctx, i.Repos, exec)
+		if err != nil {
+			return nil, err
+		}
+		b.Executables = append(b.Executables, BundleExecutable{Name: exec.Name, Version: exec.Version, Path: exec.Path,
+			Description: exec.Description, Concurrency: exec.Concurrency, Parameters: catalog})
+	}
+
+	cmds, err := i.Repos.Commands.Find(ctx, models.CommandFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		b.Commands = append(b.Commands, BundleCommand{Name: cmd.Name, Description: cmd.Description, Executable: cmd.Executable.Tag,
+			RequiresRoot: cmd.RequiresRoot, Flags: paramFlags(cmd.Parameters), Retry: cmd.Retry, Concurrency: cmd.Concurrency})
+	}
+
+	jobs, err := i.Repos.Jobs.Find(ctx, models.JobFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		values := []JobFlagValue{}
+		for _, fv := range job.FlagValues {
+			values = append(values, JobFlagValue{Flag: fv.Parameter.Flag, Value: fv.Value})
+		}
+		b.Jobs = append(b.Jobs, BundleJob{Name: job.Name, Command: job.Command.Name, FlagValues: values, Retry: job.Retry,
+			Concurrency: job.Concurrency, Priority: job.Priority, WorkerLabels: job.WorkerLabels, WorkingDir: job.WorkingDir, Env: job.Env})
+	}
+
+	workflows, err := i.Repos.Workflows.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, listed := range workflows {
+		// List doesn't load the nodes
+		wf, err := i.Repos.Workflows.GetByName(ctx, listed.Name)
+		if err != nil {
+			return nil, err
+		}
+		nodes := []models.WorkflowNodeRaw{}
+		for _, node := range wf.Nodes {
+			raw := models.WorkflowNodeRaw{Kind: node.Kind, Approvers: node.Approvers, Timeout: node.Timeout, Priority: node.Priority}
+			if node.Job != nil {
+				raw.Job = node.Job.Name
+			}
+			nodes = append(nodes, raw)
+		}
+		b.Workflows = append(b.Workflows, BundleWorkflow{Name: wf.Name, Description: wf.Description, Nodes: nodes})
+	}
+
+	schedules, err := i.Repos.Schedules.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, schedule := range schedules {
+		if schedule.Job == nil {
+			return nil, fmt.Errorf("schedule %s: job %d not found", schedule.Name, schedule.JobID)
+		}
+		b.Schedules = append(b.Schedules, models.ScheduleRaw{Name: schedule.Name, Job: schedule.Job.Name, Spec: schedule.Spec,
+			Enabled: &schedule.Enabled, Priority: schedule.Priority})
+	}
+	return b, nil
+}
+
+// ImportBundle creates or updates the records of the bundle, matched by tag or name, in a single transaction.
+// The parameters of a bundled executable are replaced by its catalog : its flags missing from the catalog are deleted.
+// The other records missing from the bundle are kept. A schedule without enabled keeps its state.
+//
+// The bundle is checked as a whole before anything is saved, along with the commands and jobs depending on a bundled
+// executable, and ErrInvalidBundle lists every problem. With dryRun, the plan is returned without saving it.
+func (i *Instance) ImportBundle(ctx context.Context, b *Bundle, dryRun bool) (*Plan, error) {
+	return i.importBundle(ctx, b, dryRun, false)
+}
+
+// importBundle imports the bundle. With prune, the records missing from the bundle are deleted as well,
+// so the bundle is checked on its own.
+func (i *Instance) importBundle(ctx context.Context, b *Bundle, dryRun, prune bool) (*Plan, error) {
+	if b.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: version %d, expected %d", ErrInvalidBundle, b.Version, BundleVersion)
+	}
+
+	// No parameter is added meanwhile, the schemas of the bundled executables are replaced once the import is saved
+	i.schemas.changes.Lock()
+	defer i.schemas.changes.Unlock()
+
+	current, err := i.ExportBundle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kept := current
+	if prune {
+		kept = &Bundle{}
+	}
+	schemas, err := checkBundle(kept, b)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := planBundle(current, b)
+	if prune {
+		plan.Changes = append(plan.Changes, planPrune(current, b)...)
+	}
+	plan.DryRun = dryRun
+	if dryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+
+	err = i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		return applyBundle(ctx, tx, b, plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for tag, s := range schemas {
+		i.schemas.publish(tag, s)
+	}
+	for _, c := range plan.Changes {
+		if c.Kind == KindExecutable && c.Action == ActionDelete {
+			i.schemas.drop(c.Name)
+		}
+	}
+	return plan, nil
+}
+
+// resolvedCatalog is the catalog of an executable once resolved, or the reason it can't be.
+type resolvedCatalog struct {
+	params []models.Parameter
+	byFlag map[string]*models.Parameter
+	schema *fme.Schema
+	err    error
+}
+
+// checkBundle validates the bundle against the current configuration updated by the bundle : the catalogs of the executables,
+// the flags of the commands, the values of the jobs, the jobs of the workflows and schedules. The commands of a bundled executable
+// and the jobs of a checked command are checked too. It returns the schema of each bundled executable.
+func checkBundle(current, b *Bundle) (map[string]*fme.Schema, error) {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	distinct := func(kind string) func(key string) bool {
+		seen := make(map[string]bool)
+		return func(key string) bool {
+			if seen[key] {
+				fail("%s %s is given twice", kind, key)
+				return false
+			}
+			seen[key] = true
+			return true
+		}
+	}
+
+	// The configuration once imported, and the keys of the records to check, in order
+	execs := make(map[string]*BundleExecutable)
+	for n := range current.Executables {
+		execs[current.Executables[n].Tag()] = &current.Executables[n]
+	}
+	var bundledExecs []string
+	first := distinct(KindExecutable)
+	for n := range b.Executables {
+		if tag := b.Executables[n].Tag(); first(tag) {
+			execs[tag] = &b.Executables[n]
+			bundledExecs = append(bundledExecs, tag)
+		}
+	}
+
+	cmds := make(map[string]*BundleCommand)
+	for n := range current.Commands {
+		cmds[current.Commands[n].Name] = &current.Commands[n]
+	}
+	var checkedCmds []string
+	first = distinct(KindCommand)
+	for n := range b.Commands {
+		if name := b.Commands[n].Name; first(name) {
+			cmds[name] = &b.Commands[n]
+			checkedCmds = append(checkedCmds, name)
+		}
+	}
+	for _, c := range current.Commands {
+		if slices.Contains(bundledExecs, c.Executable) && !slices.Contains(checkedCmds, c.Name) {
+			checkedCmds = append(checkedCmds, c.Name)
+		}
+	}
+
+	jobs := make(map[string]*BundleJob)
+	for n := range current.Jobs {
+		jobs[current.Jobs[n].Name] = &current.Jobs[n]
+	}
+	var checkedJobs []string
+	first = distinct(KindJob)
+	for n := range b.Jobs {
+		if name := b.Jobs[n].Name; first(name) {
+			jobs[name] = &b.Jobs[n]
+			checkedJobs = append(checkedJobs, name)
+		}
+	}
+	for _, j := range current.Jobs {
+		if slices.Contains(checkedCmds, j.Command) && !slices.Contains(checkedJobs, j.Name) {
+			checkedJobs = append(checkedJobs, j.Name)
+		}
+	}
+
+	// Catalogs are resolved on first use, an executable only referred to by a checked command included
+	catalogs := make(map[string]*resolvedCatalog)
+	catalog := func(tag string) *resolvedCatalog {
+		if r, ok := catalogs[tag]; ok {
+			return r
+		}
+		e := execs[tag]
+		r := &resolvedCatalog{byFlag: make(map[string]*models.Parameter)}
+		r.params, r.schema, r.err = resolveCatalog(&models.Executable{Tag: tag, Name: e.Name, Version: e.Version}, e.Parameters)
+		for n := range r.params {
+			r.byFlag[r.params[n].Flag] = &r.params[n]
+		}
+		catalogs[tag] = r
+		return r
+	}
+
+	schemas := make(map[string]*fme.Schema)
+	for _, tag := range bundledExecs {
+		if r := catalog(tag); r.err != nil {
+			fail("executable %s: %w", tag, r.err)
+		} else {
+			schemas[tag] = r.schema
+		}
+	}
+
+	for _, name := range checkedCmds {
+		c := cmds[name]
+		if _, ok := execs[c.Executable]; !ok {
+			fail("command %s: executable %s doesn't exist", name, c.Executable)
+			continue
+		}
+		r := catalog(c.Executable)
+		if r.err != nil {
+			fail("command %s: the catalog of %s is invalid", name, c.Executable)
+			continue
+		}
+		if d := DiagnoseCombination(r.schema, c.Executable, r.params, c.Flags); !d.Valid {
+			fail("command %s: %s", name, strings.Join(d.Suggestions, ", "))
+		}
+	}
+
+	for _, name := range checkedJobs {
+		j := jobs[name]
+		for _, key := range slices.Sorted(maps.Keys(j.Env)) {
+			if !validEnvName(key) {
+				fail("job %s: %q isn't a valid environment variable name", name, key)
+			}
+		}
+		c, ok := cmds[j.Command]
+		if !ok {
+			fail("job %s: command %s doesn't exist", name, j.Command)
+			continue
+		}
+		if _, ok := execs[c.Executable]; !ok || catalog(c.Executable).err != nil {
+			// reported with the command
+			continue
+		}
+		r := catalog(c.Executable)
+
+		var values []*models.FlagValue
+		for _, fv := range j.FlagValues {
+			param, ok := r.byFlag[fv.Flag]
+			if !slices.Contains(c.Flags, fv.Flag) {
+				fail("job %s: %s isn't a flag of command %s", name, fv.Flag, j.Command)
+				continue
+			} else if !ok {
+				continue
+			}
+			values = append(values, models.NewFlagValue(param, fv.Value))
+		}
+		if d := DiagnoseValues(c.Executable, values); !d.Valid {
+			fail("job %s: %s", name, strings.Join(d.Suggestions, ", "))
+		}
+	}
+
+	first = distinct(KindWorkflow)
+	for _, w := range b.Workflows {
+		if !first(w.Name) {
+			continue
+		}
+		for position, node := range w.Nodes {
+			switch node.Kind {
+			case models.NodeJob:
+				if _, ok := jobs[node.Job]; !ok {
+					fail("workflow %s: node %d: job %s doesn't exist", w.Name, position, node.Job)
+				}
+			case models.NodeApproval:
+			default:
+				fail("workflow %s: node %d: unknown node kind %q", w.Name, position, node.Kind)
+			}
+		}
+	}
+
+	first = distinct(KindSchedule)
+	for _, s := range b.Schedules {
+		if !first(s.Name) {
+			continue
+		}
+		if _, ok := jobs[s.Job]; !ok {
+			fail("schedule %s: job %s doesn't exist", s.Name, s.Job)
+		}
+		if _, err := ParseSchedule(s.Spec, time.Now()); err != nil {
+			fail("schedule %s: invalid schedule %q: %w", s.Name, s.Spec, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w:\n%w", ErrInvalidBundle, errors.Join(errs...))
+	}
+	return schemas, nil
+}
+
+// planBundle compares the bundle with the current configuration. The parameters missing from the catalog of a bundled
+// executable are deleted last, once no command uses them.
+func planBundle(current, b *Bundle) *Plan {
+	plan := &Plan{Changes: []Change{}}
+	var deletes []Change
+	compare := func(kind, name string, from, to any, found bool) {
+		if !found {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: kind, Name: name})
+		} else if fields := recordChanges(from, to); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: kind, Name: name, Fields: fields})
+		}
+	}
+
+	execs := make(map[string]BundleExecutable)
+	for _, e := range current.Executables {
+		execs[e.Tag()] = e
+	}
+	for _, e := range b.Executables {
+		tag := e.Tag()
+		cur, found := execs[tag]
+		from, to := cur, e
+		from.Parameters, to.Parameters = nil, nil
+		compare(KindExecutable, tag, from, to, found)
+
+		params := make(map[string]models.ParameterRaw)
+		for _, raw := range cur.Parameters {
+			params[raw.Flag] = raw
+		}
+		for _, raw := range e.Parameters {
+			old, ok := params[raw.Flag]
+			switch {
+			case !ok:
+				plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: KindParameter, Name: raw.Flag, Executable: tag})
+			case len(fieldChanges(old, raw)) > 0:
+				plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: KindParameter, Name: raw.Flag, Executable: tag, Fields: fieldChanges(old, raw)})
+			}
+			delete(params, raw.Flag)
+		}
+		for _, raw := range cur.Parameters {
+			if _, removed := params[raw.Flag]; removed {
+				deletes = append(deletes, Change{Action: ActionDelete, Kind: KindParameter, Name: raw.Flag, Executable: tag})
+			}
+		}
+	}
+
+	// The order of the flags of a command and of the values of a job isn't kept by the database
+	cmds := make(map[string]BundleCommand)
+	for _, c := range current.Commands {
+		cmds[c.Name] = c
+	}
+	for _, c := range b.Commands {
+		cur, found := cmds[c.Name]
+		cur.Flags, c.Flags = slices.Sorted(slices.Values(cur.Flags)), slices.Sorted(slices.Values(c.Flags))
+		compare(KindCommand, c.Name, cur, c, found)
+	}
+
+	jobs := make(map[string]BundleJob)
+	for _, j := range current.Jobs {
+		jobs[j.Name] = j
+	}
+	byFlag := func(a, b JobFlagValue) int {
+		return strings.Compare(a.Flag+"\x00"+a.Value, b.Flag+"\x00"+b.Value)
+	}
+	for _, j := range b.Jobs {
+		cur, found := jobs[j.Name]
+		cur.FlagValues, j.FlagValues = slices.Clone(cur.FlagValues), slices.Clone(j.FlagValues)
+		slices.SortFunc(cur.FlagValues, byFlag)
+		slices.SortFunc(j.FlagValues, byFlag)
+		compare(KindJob, j.Name, cur, j, found)
+	}
+
+	workflows := make(map[string]BundleWorkflow)
+	for _, w := range current.Workflows {
+		workflows[w.Name] = w
+	}
+	for _, w := range b.Workflows {
+		cur, found := workflows[w.Name]
+		compare(KindWorkflow, w.Name, cur, w, found)
+	}
+
+	schedules := make(map[string]models.ScheduleRaw)
+	for _, s := range current.Schedules {
+		schedules[s.Name] = s
+	}
+	for _, s := range b.Schedules {
+		cur, found := schedules[s.Name]
+		if s.Enabled == nil {
+			s.Enabled = cur.Enabled
+		}
+		compare(KindSchedule, s.Name, cur, s, found)
+	}
+
+	plan.Changes = append(plan.Changes, deletes...)
+	return plan
+}
+
+// recordChanges compares two records of a bundle field by field, in the JSON format. An empty list or map is the same as null.
+func recordChanges(from, to any) []FieldChange {
+	a, b := jsonFields(from), jsonFields(to)
+	keys := slices.Sorted(maps.Keys(a))
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []FieldChange
+	for _, key := range keys {
+		if a[key] != b[key] {
+			changes = append(changes, FieldChange{Field: key, From: a[key], To: b[key]})
+		}
+	}
+	return changes
+}
+
+// jsonFields returns the non empty fields of a record in the JSON format. Strings are given without quotes.
+func jsonFields(record any) map[string]string {
+	data, _ := json.Marshal(record)
+	var decoded map[string]any
+	_ = json.Unmarshal(data, &decoded)
+
+	fields := make(map[string]string)
+	for key, value := range decoded {
+		switch value := pruneEmpty(value).(type) {
+		case nil:
+		case string:
+			fields[key] = value
+		default:
+			encoded, _ := json.Marshal(value)
+			fields[key] = string(encoded)
+		}
+	}
+	return fields
+}
+
+// pruneEmpty removes the empty lists and maps of a decoded JSON value, nil when it is empty itself.
+func pruneEmpty(value any) any {
+	switch value := value.(type) {
+	case []any:
+		if len(value) == 0 {
+			return nil
+		}
+		for n := range value {
+			value[n] = pruneEmpty(value[n])
+		}
+	case map[string]any:
+		for key, field := range value {
+			if field = pruneEmpty(field); field == nil {
+				delete(value, key)
+			} else {
+				value[key] = field
+			}
+		}
+		if len(value) == 0 {
+			return nil
+		}
+	}
+	return value
+}
+
+// applyBundle saves the changes of the plan : executables with their parameters first, then commands, jobs, workflows,
+// schedules, and the deletions last, in the order of the plan.
+func applyBundle(ctx context.Context, tx *models.Repositories, b *Bundle, plan *Plan) error {
+	for n := range b.Executables {
+		e := &b.Executables[n]
+		tag := e.Tag()
+
+		var exec *models.Executable
+		var err error
+		switch plan.action(KindExecutable, "", tag) {
+		case ActionCreate:
+			exec = models.NewExecutable(e.Name, e.Version, e.Path, e.Description)
+			exec.Concurrency = e.Concurrency
+			err = tx.Executables.Create(ctx, exec)
+		case ActionUpdate:
+			if exec, err = tx.Executables.GetByTag(ctx, tag); err == nil {
+				exec.Path, exec.Description, exec.Concurrency = e.Path, e.Description, e.Concurrency
+				err = tx.Executables.Update(ctx, exec)
+			}
+		default:
+			exec, err = tx.Executables.GetByTag(ctx, tag)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save executable %s: %w", tag, err)
+		}
+		if err := applyCatalog(ctx, tx, exec, e.Parameters, plan); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range b.Commands {
+		action := plan.action(KindCommand, "", c.Name)
+		if action == "" {
+			continue
+		}
+		exec, err := tx.Executables.GetByTag(ctx, c.Executable)
+		if err != nil {
+			return err
+		}
+		params, err := tx.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID, Flags: uniqueFlags(c.Flags)})
+		if err != nil {
+			return fmt.Errorf("command %s: %w", c.Name, err)
+		}
+
+		cmd := models.NewCommand(c.Name, c.Description, exec, params)
+		cmd.RequiresRoot, cmd.Retry, cmd.Concurrency = c.RequiresRoot, c.Retry, c.Concurrency
+		var saved *models.Command
+		if action == ActionCreate {
+			err = tx.Commands.Create(ctx, cmd)
+		} else if saved, err = tx.Commands.GetByName(ctx, c.Name); err == nil {
+			cmd.Model = saved.Model
+			err = tx.Commands.Update(ctx, cmd)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save command %s: %w", c.Name, err)
+		}
+	}
+
+	for _, j := range b.Jobs {
+		action := plan.action(KindJob, "", j.Name)
+		if action == "" {
+			continue
+		}
+		cmd, err := tx.Commands.GetByName(ctx, j.Command)
+		if err != nil {
+			return err
+		}
+		var values []*models.FlagValue
+		for _, fv := range j.FlagValues {
+			n := slices.IndexFunc(cmd.Parameters, func(p models.Parameter) bool { return p.Flag == fv.Flag })
+			if n < 0 {
+				return fmt.Errorf("job %s: %s isn't a flag of command %s", j.Name, fv.Flag, cmd.Name)
+			}
+			values = append(values, models.NewFlagValue(&cmd.Parameters[n], fv.Value))
+		}
+
+		job := models.NewJob(j.Name, cmd, nil)
+		job.Retry, job.Concurrency, job.Priority, job.WorkerLabels = j.Retry, j.Concurrency, j.Priority, j.WorkerLabels
+		job.WorkingDir, job.Env = j.WorkingDir, j.Env
+		var saved *models.Job
+		if action == ActionCreate {
+			err = tx.Jobs.Create(ctx, job)
+		} else if saved, err = tx.Jobs.GetByName(ctx, j.Name); err == nil {
+			job.Model = saved.Model
+			err = tx.Jobs.Update(ctx, job)
+		}
+		if err == nil {
+			err = tx.Jobs.SaveFlagValues(ctx, job.ID, values)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save job %s: %w", j.Name, err)
+		}
+	}
+
+	for _, w := range b.Workflows {
+		action := plan.action(KindWorkflow, "", w.Name)
+		if action == "" {
+			continue
+		}
+		nodes, err := workflowNodes(ctx, tx, w.Nodes)
+		if err != nil {
+			return fmt.Errorf("workflow %s: %w", w.Name, err)
+		}
+
+		wf := models.NewWorkflow(w.Name, w.Description, nodes)
+		var saved *models.Workflow
+		if action == ActionCreate {
+			err = tx.Workflows.Create(ctx, wf)
+		} else if saved, err = tx.Workflows.GetByName(ctx, w.Name); err == nil {
+			wf.Model = saved.Model
+			err = tx.Workflows.Update(ctx, wf)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save workflow %s: %w", w.Name, err)
+		}
+	}
+
+	for _, s := range b.Schedules {
+		if err := applySchedule(ctx, tx, s, plan.action(KindSchedule, "", s.Name)); err != nil {
+			return fmt.Errorf("failed to save schedule %s: %w", s.Name, err)
+		}
+	}
+
+	for _, c := range plan.Changes {
+		if c.Action != ActionDelete {
+			continue
+		}
+		if err := deleteRecord(ctx, tx, c); err != nil {
+			return fmt.Errorf("failed to delete %s %s: %w", c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteRecord deletes the record of a change. The records depending on it must be deleted first.
+func deleteRecord(ctx context.Context, tx *models.Repositories, c Change) error {
+	switch c.Kind {
+	case KindParameter:
+		exec, err := tx.Executables.GetByTag(ctx, c.Executable)
+		if err != nil {
+			return err
+		}
+		param, err := tx.Parameters.GetByFlag(ctx, exec.ID, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Parameters.Delete(ctx, param.ID)
+	case KindSchedule:
+		schedule, err := tx.Schedules.GetByName(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Schedules.Delete(ctx, schedule.ID)
+	case KindWorkflow:
+		wf, err := tx.Workflows.GetByName(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Workflows.Delete(ctx, wf.ID)
+	case KindJob:
+		job, err := tx.Jobs.GetByName(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Jobs.Delete(ctx, job.ID)
+	case KindCommand:
+		cmd, err := tx.Commands.GetByName(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Commands.Delete(ctx, cmd.ID)
+	case KindExecutable:
+		exec, err := tx.Executables.GetByTag(ctx, c.Name)
+		if err != nil {
+			return err
+		}
+		return tx.Executables.Delete(ctx, exec.ID)
+	}
+	return fmt.Errorf("unknown kind %q", c.Kind)
+}
+
+// applyCatalog creates and updates the parameters of the catalog, in its order : the flags a parameter refers to are saved before it.
+func applyCatalog(ctx context.Context, tx *models.Repositories, exec *models.Executable, catalog []models.ParameterRaw, plan *Plan) error {
+	params, err := tx.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return err
+	}
+	saved := make(map[string]*models.Parameter)
+	for n := range params {
+		saved[params[n].Flag] = &params[n]
+	}
+	refs := func(flags []string) []models.Parameter {
+		out := []models.Parameter{}
+		for _, flag := range flags {
+			ref := *saved[flag]
+			ref.Require, ref.Interfer = nil, nil
+			out = append(out, ref)
+		}
+		return out
+	}
+
+	for n := range catalog {
+		raw := &catalog[n]
+		action := plan.action(KindParameter, exec.Tag, raw.Flag)
+		if action == "" {
+			continue
+		}
+
+		param := parameterFromRaw(raw, exec, refs(raw.RequireIDs), refs(raw.InterferIDs))
+		if action == ActionCreate {
+			err = tx.Parameters.Create(ctx, param)
+		} else {
+			param.Model = saved[raw.Flag].Model
+			err = tx.Parameters.Update(ctx, param)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save parameter %s of %s: %w", raw.Flag, exec.Tag, err)
+		}
+		saved[raw.Flag] = param
+	}
+	return nil
+}
+
+// applySchedule creates or updates the schedule. An updated schedule keeps its next run unless its spec changes or it is resumed.
+func applySchedule(ctx context.Context, tx *models.Repositories, s models.ScheduleRaw, action string) error {
+	if action == "" {
+		return nil
+	}
+	job, err := tx.Jobs.GetByName(ctx, s.Job)
+	if err != nil {
+		return err
+	}
+	next, err := ParseSchedule(s.Spec, time.Now())
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q: %w", s.Spec, err)
+	}
+
+	schedule := models.NewSchedule(s.Name, job, s.Spec, next)
+	schedule.Priority = s.Priority
+	if s.Enabled != nil {
+		schedule.Enabled = *s.Enabled
+	}
+	if action == ActionCreate {
+		return tx.Schedules.Create(ctx, schedule)
+	}
+
+	cur, err := tx.Schedules.GetByName(ctx, s.Name)
+	if err != nil {
+		return err
+	}
+	schedule.Model, schedule.LastRunAt, schedule.LastRunID, schedule.LastError = cur.Model, cur.LastRunAt, cur.LastRunID, cur.LastError
+	if s.Enabled == nil {
+		schedule.Enabled = cur.Enabled
+	}
+	if cur.Spec == s.Spec && cur.Enabled {
+		schedule.NextRunAt = cur.NextRunAt
+	}
+	return tx.Schedules.Update(ctx, schedule)
+}
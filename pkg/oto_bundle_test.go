@@ -0,0 +1,189 @@
+package oto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bl4omArchie/simple"
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, raw := range []models.ParameterRaw{
+		{Flag: "-sL", Description: "list scan"},
+		{Flag: "-sT", Description: "connect scan", RequireIDs: []string{"-sL"}},
+		{Flag: "-sK", Description: "unused scan"},
+		{Flag: "-p", Description: "ports", RequiresValue: true, ValueType: models.Port},
+	} {
+		raw.ExecutableTag = "nmap - 7.98"
+		if err := instance.AddParameterRaw(ctx, &raw); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := instance.AddCommand(ctx, "nmap - 7.98", "ports", "", []string{"-sT", "-p"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddJob(ctx, "ports", "ports-80", []JobFlagValue{{Flag: "-p", Value: "80"}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddWorkflow(ctx, "nightly", "", []models.WorkflowNodeRaw{{Kind: models.NodeApproval, Approvers: []string{"alice"}}, {Kind: models.NodeJob, Job: "ports-80"}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddSchedule(ctx, "every-night", "ports-80", "0 3 * * *", nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	exported, err := instance.ExportBundle(ctx)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// The bundle restores the same configuration in an empty instance, and importing it again changes nothing
+	restored := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	plan, err := restored.ImportBundle(ctx, exported, false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(plan.Changes) != 9 || !strings.HasPrefix(plan.String(), "+ executable nmap - 7.98\n") {
+		t.Fatalf("unexpected plan\n%s", plan)
+	}
+	if again, _ := restored.ExportBundle(ctx); mustJSON(t, again) != mustJSON(t, exported) {
+		t.Fatalf("expected the same bundle, got\n%s\ninstead of\n%s", mustJSON(t, again), mustJSON(t, exported))
+	}
+	if plan, err := restored.ImportBundle(ctx, exported, false); err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("expected no changes, got\n%s: %v", plan, err)
+	}
+
+	// A dry run plans the changes without saving them, deletions last
+	changed, _ := restored.ExportBundle(ctx)
+	changed.Executables[0].Parameters = []models.ParameterRaw{
+		{Flag: "-sL", Description: "list scan"},
+		{Flag: "-sT", Description: "connect scan", RequireIDs: []string{"-sL"}},
+		{Flag: "-p", Description: "port ranges", RequiresValue: true, ValueType: models.Port},
+	}
+	changed.Jobs = append(changed.Jobs, BundleJob{Name: "ports-443", Command: "ports", FlagValues: []JobFlagValue{{Flag: "-p", Value: "443"}}})
+	plan, err = restored.ImportBundle(ctx, changed, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	want := []string{"~ parameter -p (nmap - 7.98)", "    description: ports -> port ranges", "+ job ports-443", "- parameter -sK (nmap - 7.98)", ""}
+	if plan.String() != strings.Join(want, "\n") {
+		t.Fatalf("unexpected plan\n%s", plan)
+	}
+	if _, err := restored.Repos.Jobs.GetByName(ctx, "ports-443"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the dry run not to save the job")
+	}
+
+	if _, err := restored.ImportBundle(ctx, changed, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	nmap, _ := restored.Repos.Executables.GetByTag(ctx, "nmap - 7.98")
+	if _, err := restored.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-sK"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected -sK to be deleted, got %v", err)
+	}
+	if job, err := restored.Repos.Jobs.GetByName(ctx, "ports-443"); err != nil || len(job.FlagValues) != 1 || job.FlagValues[0].Value != "443" {
+		t.Fatalf("unexpected job %+v: %v", job, err)
+	}
+
+	// Every problem is reported, and nothing is saved
+	broken, _ := restored.ExportBundle(ctx)
+	broken.Executables[0].Parameters = broken.Executables[0].Parameters[:1]
+	broken.Jobs = append(broken.Jobs, BundleJob{Name: "rate", Command: "ports", FlagValues: []JobFlagValue{{Flag: "--rate", Value: "1000"}}})
+	broken.Schedules = append(broken.Schedules, models.ScheduleRaw{Name: "never", Job: "rate", Spec: "not a spec"})
+	_, err = restored.ImportBundle(ctx, broken, false)
+	if !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidBundle, got %v", err)
+	}
+	for _, problem := range []string{"command ports:", "job rate: --rate isn't a flag of command ports", "schedule never: invalid schedule"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("expected %q in %v", problem, err)
+		}
+	}
+	if _, err := restored.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-p"); err != nil {
+		t.Fatalf("expected the invalid bundle not to be saved: %v", err)
+	}
+}
+
+func TestBundleFailedUpdateSavesNothing(t *testing.T) {
+	instance, db := newSqliteInstance(t)
+	ctx := context.Background()
+
+	b := &Bundle{Version: BundleVersion,
+		Executables: []BundleExecutable{{Name: "echo", Version: "9.1", Path: "/bin/echo",
+			Parameters: []models.ParameterRaw{{Flag: "--message", RequiresValue: true, ValueType: models.String}}}},
+		Commands: []BundleCommand{{Name: "say", Executable: "echo - 9.1", Flags: []string{"--message"}}},
+		Jobs:     []BundleJob{{Name: "say-it", Command: "say", FlagValues: []JobFlagValue{{Flag: "--message", Value: "hello"}}}},
+	}
+	if _, err := instance.ImportBundle(ctx, b, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	refused := errors.New("update refused")
+	db.Callback().Update().Before("gorm:update").Register("test:refuse_priority", func(tx *gorm.DB) {
+		if job, ok := tx.Statement.Dest.(*models.Job); ok && job.Priority == 5 {
+			tx.AddError(refused)
+		}
+	})
+	b.Jobs[0].Priority = 5
+	b.Jobs = append([]BundleJob{{Name: "say-hi", Command: "say", FlagValues: []JobFlagValue{{Flag: "--message", Value: "hi"}}}}, b.Jobs...)
+	if _, err := instance.ImportBundle(ctx, b, false); !errors.Is(err, refused) {
+		t.Fatalf("expected the failed update to be returned, got %v", err)
+	}
+	if _, err := instance.Repos.Jobs.GetByName(ctx, "say-hi"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the import to be rolled back, got %v", err)
+	}
+}
+
+func TestBundleEmptyFlagValue(t *testing.T) {
+	instance, _ := newSqliteInstance(t)
+	ctx := context.Background()
+
+	// The empty value is a value of its own, not any value of the flag
+	b := &Bundle{Version: BundleVersion,
+		Executables: []BundleExecutable{{Name: "echo", Version: "9.1", Path: "/bin/echo",
+			Parameters: []models.ParameterRaw{{Flag: "--message", RequiresValue: true, ValueType: models.String}}}},
+		Commands: []BundleCommand{{Name: "say", Executable: "echo - 9.1", Flags: []string{"--message"}}},
+		Jobs: []BundleJob{
+			{Name: "say-hello", Command: "say", FlagValues: []JobFlagValue{{Flag: "--message", Value: "hello"}}},
+			{Name: "say-nothing", Command: "say", FlagValues: []JobFlagValue{{Flag: "--message", Value: ""}}},
+		},
+	}
+	if _, err := instance.ImportBundle(ctx, b, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if job, err := instance.Repos.Jobs.GetByName(ctx, "say-nothing"); err != nil || len(job.FlagValues) != 1 || job.FlagValues[0].Value != "" {
+		t.Fatalf("expected an empty value, got %+v: %v", job, err)
+	}
+}
+
+// newSqliteInstance returns an instance saving its records in a new sqlite database.
+func newSqliteInstance(t *testing.T) (*Instance, *gorm.DB) {
+	db, err := simple.OpenDatabase(simple.GetSqlite(filepath.Join(t.TempDir(), "oto.db")))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := migrateModels(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return &Instance{Database: db, Repos: models.NewGormRepositories(db), schemas: newSchemaRegistry()}, db
+}
+
+func mustJSON(t *testing.T, v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return string(data)
+}
@@ -0,0 +1,116 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// ErrNoCapableWorker is returned when no live worker can run a job.
+var ErrNoCapableWorker = errors.New("no capable worker registered")
+
+// Workers send a heartbeat at this interval, and are ignored by the routing after three missed heartbeats.
+const (
+	WorkerHeartbeatInterval = 30 * time.Second
+	workerStaleAfter        = 3 * WorkerHeartbeatInterval
+)
+
+// Capabilities are advertised by a worker to receive the jobs it is able to run.
+type Capabilities struct {
+	Executables []string          `json:"executables"` // tags of the executables installed on the host
+	Root        bool              `json:"root"`        // whether commands requiring root can be run
+	Labels      map[string]string `json:"labels"`
+}
+
+// verifyCapabilities checks the capabilities against the host : the path of each executable must exist
+// and root must be available. Executables that couldn't be verified are returned with the reason.
+func verifyCapabilities(ctx context.Context, executables models.ExecutableRepository, caps Capabilities) (*models.Worker, map[string]string) {
+	record := &models.Worker{
+		VerifiedPaths: make(map[string]string),
+		Labels:        caps.Labels,
+		Root:          caps.Root && rootAvailable(),
+	}
+	record.Host, _ = os.Hostname()
+
+	unverified := make(map[string]string)
+	if caps.Root && !record.Root {
+		unverified["root"] = "not running as root and sudo requires a password"
+	}
+
+	for _, tag := range caps.Executables {
+		exe, err := executables.GetByTag(ctx, tag)
+		if err != nil {
+			unverified[tag] = fmt.Sprintf("unknown executable: %v", err)
+			continue
+		}
+		path, err := exec.LookPath(exe.Path)
+		if err != nil {
+			unverified[tag] = fmt.Sprintf("not found on host: %v", err)
+			continue
+		}
+		record.Executables = append(record.Executables, tag)
+		record.VerifiedPaths[tag] = path
+	}
+	return record, unverified
+}
+
+// rootAvailable reports whether the process is root or can use sudo without a password.
+func rootAvailable() bool {
+	if os.Geteuid() == 0 {
+		return true
+	}
+	return exec.Command("sudo", "-n", "true").Run() == nil
+}
+
+// selectTaskQueue returns the task queue of a live worker able to run the job.
+// With a nil job, the queue of any live worker is returned, preferring the given default queue.
+func selectTaskQueue(ctx context.Context, db *gorm.DB, job *models.Job, defaultQueue string) (string, error) {
+	workers, err := models.FetchLiveWorkers(ctx, db, string(WorkerRunning), time.Now().Add(-workerStaleAfter))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch workers: %w", err)
+	}
+
+	var queues []string
+	for _, w := range workers {
+		if w.Satisfies(job) {
+			queues = append(queues, w.TaskQueue)
+		}
+	}
+
+	if len(queues) == 0 {
+		if job == nil {
+			return "", ErrNoCapableWorker
+		}
+		return "", fmt.Errorf("%w for job %s: %s", ErrNoCapableWorker, job.Name, describeRequirements(job))
+	}
+
+	sort.Strings(queues)
+	for _, queue := range queues {
+		if queue == defaultQueue {
+			return queue, nil
+		}
+	}
+	return queues[0], nil
+}
+
+func describeRequirements(job *models.Job) string {
+	desc := "any worker"
+	if job.Command != nil {
+		desc = fmt.Sprintf("executable %s", job.Command.Executable.Tag)
+		if job.Command.RequiresRoot {
+			desc += " with root"
+		}
+	}
+	if len(job.WorkerLabels) > 0 {
+		desc += fmt.Sprintf(" and labels %v", job.WorkerLabels)
+	}
+	return desc
+}
@@ -0,0 +1,43 @@
+package oto
+
+import (
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestWorkerSatisfiesJob(t *testing.T) {
+	masscan := &models.Executable{Tag: "masscan - 1.3.9"}
+	job := &models.Job{
+		Name:         "scan-prod",
+		Command:      &models.Command{Executable: *masscan, RequiresRoot: true},
+		WorkerLabels: map[string]string{"zone": "prod"},
+	}
+
+	worker := models.Worker{
+		VerifiedPaths: map[string]string{masscan.Tag: "/usr/bin/masscan"},
+		Root:          true,
+		Labels:        map[string]string{"zone": "prod"},
+	}
+	if !worker.Satisfies(job) {
+		t.Fatalf("worker should satisfy the job")
+	}
+
+	noRoot := worker
+	noRoot.Root = false
+	if noRoot.Satisfies(job) {
+		t.Fatalf("a worker without root shouldn't satisfy a job requiring root")
+	}
+
+	noExec := worker
+	noExec.VerifiedPaths = map[string]string{"nmap - 7.98": "/usr/bin/nmap"}
+	if noExec.Satisfies(job) {
+		t.Fatalf("a worker without the executable shouldn't satisfy the job")
+	}
+
+	otherZone := worker
+	otherZone.Labels = map[string]string{"zone": "lab"}
+	if otherZone.Satisfies(job) {
+		t.Fatalf("a worker with other labels shouldn't satisfy the job")
+	}
+}
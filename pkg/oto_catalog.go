@@ -0,0 +1,535 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Bl4omArchie/fme"
+	"github.com/Bl4omArchie/simple"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// ErrInvalidCatalog is returned when the parameters of a catalog don't form a valid schema.
+var ErrInvalidCatalog = errors.New("invalid catalog")
+
+// CatalogDiff compares the parameters of an executable with the catalog of a new version,
+// and tells which commands and jobs of the executable wouldn't match it.
+type CatalogDiff struct {
+	From    string            `json:"from"`
+	To      string            `json:"to"`
+	Added   []string          `json:"added"`
+	Removed []string          `json:"removed"`
+	Changed []ParameterChange `json:"changed"`
+	// BrokenCommands are the commands whose flags don't form a valid combination of the new catalog.
+	BrokenCommands []BrokenCommand `json:"broken_commands"`
+	// BrokenJobs are the jobs of a broken command, or whose values break the new catalog.
+	BrokenJobs []BrokenJob `json:"broken_jobs"`
+}
+
+// ParameterChange lists the fields of a flag which differ in the new catalog.
+type ParameterChange struct {
+	Flag   string        `json:"flag"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a field of a parameter, in the catalog format, with its previous and new value.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+type BrokenCommand struct {
+	Command string   `json:"command"`
+	Reasons []string `json:"reasons"`
+}
+
+type BrokenJob struct {
+	Job     string   `json:"job"`
+	Command string   `json:"command"`
+	Reasons []string `json:"reasons"`
+}
+
+// Migration is the result of MigrateExecutable : the new executable, the diff of its catalog,
+// the commands moved to it with their jobs, and the ones left on the previous version.
+type Migration struct {
+	Executable string       `json:"executable"`
+	Diff       *CatalogDiff `json:"diff"`
+	Commands   []string     `json:"commands"`
+	Jobs       []string     `json:"jobs"`
+	Skipped    []string     `json:"skipped"`
+}
+
+// LoadCatalog reads a catalog file of parameters, in the format of ImportParameters.
+func LoadCatalog(filename string) ([]models.ParameterRaw, error) {
+	return simple.LoadFile[models.ParameterRaw](filename, -1, true)
+}
+
+// CloneExecutable creates the version of the executable with the same parameters. An empty path keeps the path of the executable.
+func (i *Instance) CloneExecutable(ctx context.Context, fromTag, version, path string) (*models.Executable, error) {
+	i.schemas.changes.Lock()
+	defer i.schemas.changes.Unlock()
+
+	var exec *models.Executable
+	var s *fme.Schema
+	err := i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		from, err := tx.Executables.GetByTag(ctx, fromTag)
+		if err != nil {
+			return err
+		}
+		catalog, err := catalogOf(ctx, tx, from)
+		if err != nil {
+			return err
+		}
+		if _, s, err = resolveCatalog(from, catalog); err != nil {
+			return err
+		}
+		exec, _, err = createVersion(ctx, tx, from, version, path, catalog)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.schemas.publish(exec.Tag, s)
+	return exec, nil
+}
+
+// DiffCatalog compares the parameters of the executable with the catalog of its given version.
+// The executable_tag of the catalog entries is ignored.
+func (i *Instance) DiffCatalog(ctx context.Context, fromTag, version string, catalog []models.ParameterRaw) (*CatalogDiff, error) {
+	from, err := i.Repos.Executables.GetByTag(ctx, fromTag)
+	if err != nil {
+		return nil, err
+	}
+	params, s, err := resolveCatalog(from, catalog)
+	if err != nil {
+		return nil, err
+	}
+	return diffCatalog(ctx, i.Repos, from, models.GetTag(from.Name, version), params, s)
+}
+
+// MigrateExecutable creates the given version of the executable with the catalog, or with the same parameters when
+// the catalog is nil, and moves to it every command whose flags and jobs match the new catalog. The other ones
+// stay on the previous version and are reported by the diff. Everything is saved in a single transaction.
+func (i *Instance) MigrateExecutable(ctx context.Context, fromTag, version, path string, catalog []models.ParameterRaw) (*Migration, error) {
+	// The parameters, the commands and their jobs are read in the transaction, no parameter changes meanwhile
+	i.schemas.changes.Lock()
+	defer i.schemas.changes.Unlock()
+
+	m := &Migration{Commands: []string{}, Jobs: []string{}, Skipped: []string{}}
+	var s *fme.Schema
+	err := i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		from, err := tx.Executables.GetByTag(ctx, fromTag)
+		if err != nil {
+			return err
+		}
+		if catalog == nil {
+			if catalog, err = catalogOf(ctx, tx, from); err != nil {
+				return err
+			}
+		}
+		var params []models.Parameter
+		if params, s, err = resolveCatalog(from, catalog); err != nil {
+			return err
+		}
+		if m.Diff, err = diffCatalog(ctx, tx, from, models.GetTag(from.Name, version), params, s); err != nil {
+			return err
+		}
+
+		broken := make(map[string]bool)
+		for _, b := range m.Diff.BrokenCommands {
+			broken[b.Command] = true
+		}
+		for _, b := range m.Diff.BrokenJobs {
+			broken[b.Command] = true
+		}
+
+		cmds, err := tx.Commands.Find(ctx, models.CommandFilter{ExecutableID: from.ID})
+		if err != nil {
+			return err
+		}
+
+		exec, saved, err := createVersion(ctx, tx, from, version, path, catalog)
+		if err != nil {
+			return err
+		}
+		m.Executable = exec.Tag
+
+		for _, cmd := range cmds {
+			if broken[cmd.Name] {
+				m.Skipped = append(m.Skipped, cmd.Name)
+				continue
+			}
+
+			var cmdParams []models.Parameter
+			for _, p := range cmd.Parameters {
+				param, ok := saved[p.Flag]
+				if !ok {
+					return fmt.Errorf("failed to migrate command %s: flag %s isn't in %s", cmd.Name, p.Flag, exec.Tag)
+				}
+				cmdParams = append(cmdParams, *param)
+			}
+			if err := tx.Commands.Rebind(ctx, cmd.ID, exec, cmdParams); err != nil {
+				return fmt.Errorf("failed to migrate command %s: %w", cmd.Name, err)
+			}
+			m.Commands = append(m.Commands, cmd.Name)
+
+			jobs, err := tx.Jobs.Find(ctx, models.JobFilter{CommandID: cmd.ID})
+			if err != nil {
+				return err
+			}
+			for _, job := range jobs {
+				var values []*models.FlagValue
+				for _, fv := range job.FlagValues {
+					param, ok := saved[fv.Parameter.Flag]
+					if !ok {
+						return fmt.Errorf("failed to migrate job %s: flag %s isn't in %s", job.Name, fv.Parameter.Flag, exec.Tag)
+					}
+					values = append(values, models.NewFlagValue(param, fv.Value))
+				}
+				if err := tx.Jobs.SaveFlagValues(ctx, job.ID, values); err != nil {
+					return fmt.Errorf("failed to migrate job %s: %w", job.Name, err)
+				}
+				m.Jobs = append(m.Jobs, job.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.schemas.publish(m.Executable, s)
+	return m, nil
+}
+
+// catalogOf returns the parameters of the executable in the catalog format, each one after the flags it refers to.
+func catalogOf(ctx context.Context, repos *models.Repositories, exec *models.Executable) ([]models.ParameterRaw, error) {
+	params, err := repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make([]models.ParameterRaw, 0, len(params))
+	for _, p := range params {
+		catalog = append(catalog, p.Raw(exec.Tag))
+	}
+	return orderCatalog(catalog), nil
+}
+
+// orderCatalog moves each parameter after the flags it refers to, so that an exported catalog reads from the
+// independent flags on. Within a cycle of interferences, one parameter comes before a flag it refers to.
+func orderCatalog(catalog []models.ParameterRaw) []models.ParameterRaw {
+	byFlag := make(map[string]models.ParameterRaw)
+	for _, raw := range catalog {
+		byFlag[raw.Flag] = raw
+	}
+
+	ordered := make([]models.ParameterRaw, 0, len(catalog))
+	visited := make(map[string]bool)
+	var visit func(raw models.ParameterRaw)
+	visit = func(raw models.ParameterRaw) {
+		if visited[raw.Flag] {
+			return
+		}
+		visited[raw.Flag] = true
+		for _, flag := range slices.Concat(raw.RequireIDs, raw.InterferIDs) {
+			if related, ok := byFlag[flag]; ok {
+				visit(related)
+			}
+		}
+		ordered = append(ordered, raw)
+	}
+	for _, raw := range catalog {
+		visit(raw)
+	}
+	return ordered
+}
+
+// resolveCatalog returns the parameters of a catalog and their schema, whatever the order of the catalog,
+// as ImportParameters accepts it. Every problem is reported in an ErrInvalidCatalog.
+func resolveCatalog(exec *models.Executable, catalog []models.ParameterRaw) ([]models.Parameter, *fme.Schema, error) {
+	s, problems := checkCatalog(exec, nil, catalog)
+	if len(problems) > 0 {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidCatalog, errors.Join(problems...))
+	}
+
+	relations := func(flags []string) []models.Parameter {
+		related := []models.Parameter{}
+		for _, flag := range flags {
+			related = append(related, models.Parameter{Flag: flag})
+		}
+		return related
+	}
+	params := make([]models.Parameter, 0, len(catalog))
+	for n := range catalog {
+		params = append(params, *parameterFromRaw(&catalog[n], exec, relations(catalog[n].RequireIDs), relations(catalog[n].InterferIDs)))
+	}
+	return params, s, nil
+}
+
+// checkCatalog checks the new parameters of the executable against its existing ones, whatever their order in the catalog,
+// and returns the schema of the executable once they are saved. Every problem is returned.
+func checkCatalog(exec *models.Executable, existing []models.Parameter, catalog []models.ParameterRaw) (*fme.Schema, []error) {
+	var problems []error
+	declared := make(map[string]bool)
+	for _, p := range existing {
+		declared[p.Flag] = true
+	}
+	for n, raw := range catalog {
+		switch {
+		case declared[raw.Flag] && slices.ContainsFunc(catalog[:n], func(r models.ParameterRaw) bool { return r.Flag == raw.Flag }):
+			problems = append(problems, fmt.Errorf("%s is declared twice", raw.Flag))
+		case declared[raw.Flag]:
+			problems = append(problems, fmt.Errorf("%s already exists", raw.Flag))
+		}
+		declared[raw.Flag] = true
+	}
+
+	params := slices.Clone(existing)
+	for n := range catalog {
+		raw := &catalog[n]
+		relations := func(flags []string) []models.Parameter {
+			related := []models.Parameter{}
+			for _, flag := range flags {
+				if !declared[flag] {
+					problems = append(problems, fmt.Errorf("%s refers to %s, which isn't a parameter of the executable", raw.Flag, flag))
+					continue
+				}
+				related = append(related, models.Parameter{Flag: flag})
+			}
+			return related
+		}
+		params = append(params, *parameterFromRaw(raw, exec, relations(raw.RequireIDs), relations(raw.InterferIDs)))
+	}
+
+	for _, cycle := range requireCycles(params) {
+		problems = append(problems, fmt.Errorf("requirement cycle %s", strings.Join(cycle, " -> ")))
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	s, err := buildSchema(params)
+	if err != nil {
+		return nil, []error{err}
+	}
+	return s, nil
+}
+
+// requireCycles returns the cycles of requirements among the parameters, each one from a flag back to itself.
+func requireCycles(params []models.Parameter) [][]string {
+	requires := make(map[string][]string)
+	for _, p := range params {
+		for _, r := range p.Require {
+			requires[p.Flag] = append(requires[p.Flag], r.Flag)
+		}
+	}
+
+	var cycles [][]string
+	done := make(map[string]bool)
+	var path []string
+	var visit func(flag string)
+	visit = func(flag string) {
+		if n := slices.Index(path, flag); n >= 0 {
+			cycles = append(cycles, append(slices.Clone(path[n:]), flag))
+			return
+		}
+		if done[flag] {
+			return
+		}
+		path = append(path, flag)
+		for _, required := range requires[flag] {
+			visit(required)
+		}
+		path = path[:len(path)-1]
+		done[flag] = true
+	}
+	for _, p := range params {
+		visit(p.Flag)
+	}
+	return cycles
+}
+
+// saveCatalog saves the new parameters of the executable in two passes : the parameters are created,
+// then their requirements and interferences are linked, so that a parameter may refer to a later one.
+func saveCatalog(ctx context.Context, tx *models.Repositories, exec *models.Executable, catalog []models.ParameterRaw) error {
+	params, err := tx.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return err
+	}
+	saved := make(map[string]*models.Parameter)
+	for n := range params {
+		saved[params[n].Flag] = &params[n]
+	}
+
+	for n := range catalog {
+		param := parameterFromRaw(&catalog[n], exec, nil, nil)
+		if err := tx.Parameters.Create(ctx, param); err != nil {
+			return fmt.Errorf("failed to save parameter %s of %s: %w", param.Flag, exec.Tag, err)
+		}
+		saved[param.Flag] = param
+	}
+
+	refs := func(flags []string) []models.Parameter {
+		out := []models.Parameter{}
+		for _, flag := range flags {
+			ref := *saved[flag]
+			ref.Require, ref.Interfer = nil, nil
+			out = append(out, ref)
+		}
+		return out
+	}
+	for _, raw := range catalog {
+		if len(raw.RequireIDs) == 0 && len(raw.InterferIDs) == 0 {
+			continue
+		}
+		param := saved[raw.Flag]
+		param.Require, param.Interfer = refs(raw.RequireIDs), refs(raw.InterferIDs)
+		if err := tx.Parameters.Update(ctx, param); err != nil {
+			return fmt.Errorf("failed to link parameter %s of %s: %w", raw.Flag, exec.Tag, err)
+		}
+	}
+	return nil
+}
+
+// createVersion saves the version of the executable with the parameters of the catalog, returned by flag.
+func createVersion(ctx context.Context, repos *models.Repositories, from *models.Executable, version, path string, catalog []models.ParameterRaw) (*models.Executable, map[string]*models.Parameter, error) {
+	if path == "" {
+		path = from.Path
+	}
+	exec := models.NewExecutable(from.Name, version, path, from.Description)
+	exec.Concurrency = from.Concurrency
+	if err := repos.Executables.Create(ctx, exec); err != nil {
+		return nil, nil, fmt.Errorf("failed to save Executable: %w", err)
+	}
+	if err := saveCatalog(ctx, repos, exec, catalog); err != nil {
+		return nil, nil, err
+	}
+
+	params, err := repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	saved := make(map[string]*models.Parameter)
+	for n := range params {
+		saved[params[n].Flag] = &params[n]
+	}
+	return exec, saved, nil
+}
+
+func diffCatalog(ctx context.Context, repos *models.Repositories, from *models.Executable, to string, params []models.Parameter, s *fme.Schema) (*CatalogDiff, error) {
+	old, err := repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: from.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &CatalogDiff{From: from.Tag, To: to, Added: []string{}, Removed: []string{}, Changed: []ParameterChange{},
+		BrokenCommands: []BrokenCommand{}, BrokenJobs: []BrokenJob{}}
+	current := make(map[string]*models.Parameter)
+	for n := range params {
+		current[params[n].Flag] = &params[n]
+	}
+	previous := make(map[string]bool)
+	for _, p := range old {
+		previous[p.Flag] = true
+		next, ok := current[p.Flag]
+		if !ok {
+			d.Removed = append(d.Removed, p.Flag)
+			continue
+		}
+		if fields := fieldChanges(p.Raw(""), next.Raw("")); len(fields) > 0 {
+			d.Changed = append(d.Changed, ParameterChange{Flag: p.Flag, Fields: fields})
+		}
+	}
+	for _, p := range params {
+		if !previous[p.Flag] {
+			d.Added = append(d.Added, p.Flag)
+		}
+	}
+
+	cmds, err := repos.Commands.Find(ctx, models.CommandFilter{ExecutableID: from.ID})
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		diagnostic := DiagnoseCombination(s, to, params, paramFlags(cmd.Parameters))
+		if !diagnostic.Valid {
+			d.BrokenCommands = append(d.BrokenCommands, BrokenCommand{Command: cmd.Name, Reasons: diagnostic.Suggestions})
+		}
+
+		jobs, err := repos.Jobs.Find(ctx, models.JobFilter{CommandID: cmd.ID})
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range jobs {
+			if !diagnostic.Valid {
+				d.BrokenJobs = append(d.BrokenJobs, BrokenJob{Job: job.Name, Command: cmd.Name, Reasons: []string{fmt.Sprintf("its command %s breaks", cmd.Name)}})
+				continue
+			}
+			if reasons := jobBreaks(&job, current, to); len(reasons) > 0 {
+				d.BrokenJobs = append(d.BrokenJobs, BrokenJob{Job: job.Name, Command: cmd.Name, Reasons: reasons})
+			}
+		}
+	}
+	return d, nil
+}
+
+// jobBreaks tells why the values of the job don't match the parameters of the new catalog, by flag.
+func jobBreaks(job *models.Job, params map[string]*models.Parameter, execTag string) []string {
+	var reasons []string
+	var values []*models.FlagValue
+	for _, fv := range job.FlagValues {
+		p, ok := params[fv.Parameter.Flag]
+		switch {
+		case !ok:
+			reasons = append(reasons, fmt.Sprintf("%s isn't a parameter of %s", fv.Parameter.Flag, execTag))
+			continue
+		case fv.Value != "" && !p.RequiresValue:
+			reasons = append(reasons, fmt.Sprintf("%s no longer takes a value", p.Flag))
+		}
+		values = append(values, models.NewFlagValue(p, fv.Value))
+	}
+	if d := DiagnoseValues(execTag, values); !d.Valid {
+		reasons = append(reasons, d.Suggestions...)
+	}
+	return reasons
+}
+
+// fieldChanges compares two parameters in the catalog format.
+func fieldChanges(a, b models.ParameterRaw) []FieldChange {
+	var changes []FieldChange
+	compare := func(field, from, to string) {
+		if from != to {
+			changes = append(changes, FieldChange{Field: field, From: from, To: to})
+		}
+	}
+	set := func(flags []string) string {
+		sorted := slices.Clone(flags)
+		slices.Sort(sorted)
+		return strings.Join(sorted, ", ")
+	}
+	rules := func(rules []models.ValueRule) string {
+		var out []string
+		for _, r := range rules {
+			out = append(out, fmt.Sprintf("%s* -> %s %s", r.When, r.Flag, r.Value))
+		}
+		return set(out)
+	}
+
+	compare("description", a.Description, b.Description)
+	compare("requires_root", strconv.FormatBool(a.RequiresRoot), strconv.FormatBool(b.RequiresRoot))
+	compare("requires_value", strconv.FormatBool(a.RequiresValue), strconv.FormatBool(b.RequiresValue))
+	compare("value_type", string(a.ValueType), string(b.ValueType))
+	compare("require_ids", set(a.RequireIDs), set(b.RequireIDs))
+	compare("interfer_ids", set(a.InterferIDs), set(b.InterferIDs))
+	compare("repeatable", strconv.FormatBool(a.Repeatable), strconv.FormatBool(b.Repeatable))
+	compare("one_of", set(a.OneOf), set(b.OneOf))
+	compare("at_least_one", set(a.AtLeastOne), set(b.AtLeastOne))
+	compare("value_rules", rules(a.ValueRules), rules(b.ValueRules))
+	return changes
+}
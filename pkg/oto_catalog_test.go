@@ -0,0 +1,189 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestMigrateExecutable(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, raw := range []models.ParameterRaw{
+		{Flag: "-sL", Description: "list scan"},
+		{Flag: "-sT", Description: "connect scan", RequireIDs: []string{"-sL"}},
+		{Flag: "-sK", Description: "removed scan"},
+		{Flag: "-p", Description: "ports", RequiresValue: true, ValueType: models.Port},
+	} {
+		raw.ExecutableTag = "nmap - 7.98"
+		if err := instance.AddParameterRaw(ctx, &raw); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	for name, flags := range map[string][]string{"connect": {"-sT", "-sL"}, "stealth": {"-sK"}, "ports": {"-sT", "-p"}} {
+		if err := instance.AddCommand(ctx, "nmap - 7.98", name, "", flags); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := instance.AddJob(ctx, "ports", "ports-80", []JobFlagValue{{Flag: "-p", Value: "80"}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddJob(ctx, "stealth", "stealth-scan", nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	catalog := []models.ParameterRaw{
+		{Flag: "-sL", Description: "list scan"},
+		{Flag: "-sT", Description: "connect scan", RequireIDs: []string{"-sL"}},
+		{Flag: "-p", Description: "port ranges", RequiresValue: true, ValueType: models.Port},
+		{Flag: "-sU", Description: "UDP scan"},
+	}
+	diff, err := instance.DiffCatalog(ctx, "nmap - 7.98", "7.99", catalog)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !slices.Equal(diff.Added, []string{"-sU"}) || !slices.Equal(diff.Removed, []string{"-sK"}) {
+		t.Fatalf("unexpected added %v and removed %v", diff.Added, diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Flag != "-p" || diff.Changed[0].Fields[0] != (FieldChange{Field: "description", From: "ports", To: "port ranges"}) {
+		t.Fatalf("unexpected changes %+v", diff.Changed)
+	}
+	if len(diff.BrokenCommands) != 1 || diff.BrokenCommands[0].Command != "stealth" || len(diff.BrokenJobs) != 1 || diff.BrokenJobs[0].Job != "stealth-scan" {
+		t.Fatalf("unexpected broken commands %+v and jobs %+v", diff.BrokenCommands, diff.BrokenJobs)
+	}
+
+	m, err := instance.MigrateExecutable(ctx, "nmap - 7.98", "7.99", "", catalog)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	slices.Sort(m.Commands)
+	if m.Executable != "nmap - 7.99" || !slices.Equal(m.Commands, []string{"connect", "ports"}) || !slices.Equal(m.Skipped, []string{"stealth"}) {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+
+	exec, err := instance.Repos.Executables.GetByTag(ctx, "nmap - 7.99")
+	if err != nil || exec.Path != "/usr/bin/nmap" {
+		t.Fatalf("unexpected new version %+v: %v", exec, err)
+	}
+	cmd, err := instance.Repos.Commands.GetByName(ctx, "ports")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, p := range cmd.Parameters {
+		if uint(p.ExecutableID) != exec.ID {
+			t.Fatalf("parameter %s of the migrated command belongs to executable %d", p.Flag, p.ExecutableID)
+		}
+	}
+	job, err := instance.Repos.Jobs.GetByName(ctx, "ports-80")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(job.FlagValues) != 1 || uint(job.FlagValues[0].Parameter.ExecutableID) != exec.ID || job.FlagValues[0].Value != "80" {
+		t.Fatalf("unexpected values of the migrated job %+v", job.FlagValues)
+	}
+	if d, err := instance.ValidateFlags(ctx, "nmap - 7.99", []string{"-sU", "-sT"}); err != nil || !d.Valid {
+		t.Fatalf("expected the schema of the new version, got %+v: %v", d, err)
+	}
+
+	// A clone has the same parameters and relations
+	clone, err := instance.CloneExecutable(ctx, "nmap - 7.99", "7.100", "/opt/nmap")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if diff, err := instance.DiffCatalog(ctx, "nmap - 7.99", "7.100", mustCatalog(t, instance, clone)); err != nil ||
+		len(diff.Added)+len(diff.Removed)+len(diff.Changed)+len(diff.BrokenCommands) != 0 {
+		t.Fatalf("expected an identical catalog, got %+v: %v", diff, err)
+	}
+
+	catalog = append(catalog, models.ParameterRaw{Flag: "-sS", RequireIDs: []string{"-sY"}})
+	if _, err := instance.DiffCatalog(ctx, "nmap - 7.99", "8.0", catalog); !errors.Is(err, ErrInvalidCatalog) {
+		t.Fatalf("expected ErrInvalidCatalog, got %v", err)
+	}
+}
+
+func TestImportParametersInAnyOrder(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	write := func(catalog string) string {
+		filename := filepath.Join(t.TempDir(), "catalog.json")
+		if err := os.WriteFile(filename, []byte(catalog), 0o600); err != nil {
+			t.Fatalf("%v", err)
+		}
+		return filename
+	}
+
+	// -sT refers to -sL and -sU, which are declared after it
+	err := instance.ImportParameters(ctx, write(`[
+		{"flag": "-sT", "description": "connect scan", "executable_tag": "nmap - 7.98", "require_ids": ["-sL"], "interfer_ids": ["-sU"]},
+		{"flag": "-sU", "description": "UDP scan", "executable_tag": "nmap - 7.98", "interfer_ids": ["-sT"]},
+		{"flag": "-sL", "description": "list scan", "executable_tag": "nmap - 7.98"}
+	]`))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	nmap, _ := instance.Repos.Executables.GetByTag(ctx, "nmap - 7.98")
+	if p, err := instance.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-sT"); err != nil || len(p.Require) != 1 || p.Require[0].Flag != "-sL" || len(p.Interfer) != 1 {
+		t.Fatalf("unexpected relations of -sT %+v: %v", p, err)
+	}
+	if d, err := instance.ValidateFlags(ctx, "nmap - 7.98", []string{"-sT", "-sU"}); err != nil || d.Valid {
+		t.Fatalf("expected -sT and -sU to interfer, got %+v: %v", d, err)
+	}
+
+	// Every problem is reported, and nothing is saved
+	err = instance.ImportParameters(ctx, write(`[
+		{"flag": "-A", "executable_tag": "nmap - 7.98", "require_ids": ["-O"]},
+		{"flag": "-O", "executable_tag": "nmap - 7.98", "require_ids": ["-A"]},
+		{"flag": "-sL", "executable_tag": "nmap - 7.98"},
+		{"flag": "-p", "executable_tag": "nmap - 7.98", "require_ids": ["-sY"]},
+		{"flag": "-x", "executable_tag": "curl - 8.0"}
+	]`))
+	if !errors.Is(err, ErrInvalidCatalog) {
+		t.Fatalf("expected ErrInvalidCatalog, got %v", err)
+	}
+	for _, problem := range []string{"requirement cycle -A -> -O -> -A", "-sL already exists", "-p refers to -sY", "executable curl - 8.0"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Fatalf("expected %q in %v", problem, err)
+		}
+	}
+	if _, err := instance.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-A"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the invalid catalog not to be saved, got %v", err)
+	}
+
+	// The catalog of a new version is accepted in any order as well
+	next := []models.ParameterRaw{
+		{Flag: "-sT", RequireIDs: []string{"-sL"}, InterferIDs: []string{"-sU"}},
+		{Flag: "-sU", InterferIDs: []string{"-sT"}},
+		{Flag: "-sL"},
+	}
+	if _, err := instance.DiffCatalog(ctx, "nmap - 7.98", "7.99", next); err != nil {
+		t.Fatalf("%v", err)
+	}
+	m, err := instance.MigrateExecutable(ctx, "nmap - 7.98", "7.99", "", next)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if d, err := instance.ValidateFlags(ctx, m.Executable, []string{"-sT", "-sU"}); err != nil || d.Valid {
+		t.Fatalf("expected -sT and -sU to interfer in the new version, got %+v: %v", d, err)
+	}
+}
+
+func mustCatalog(t *testing.T, instance *Instance, exec *models.Executable) []models.ParameterRaw {
+	catalog, err := catalogOf(context.Background(), instance.Repos, exec)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	return catalog
+}
@@ -0,0 +1,363 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+var (
+	// ErrInvalidCommandLine is returned when a command line can't be tokenized, or holds shell syntax other than words.
+	ErrInvalidCommandLine = errors.New("invalid command line")
+	// ErrUnknownTokens is returned when a command line with tokens matching no parameter is imported.
+	ErrUnknownTokens = errors.New("unknown tokens in the command line")
+)
+
+// CommandLineOptions names the records of an imported command line. Empty names are proposed by ImportCommandLine.
+type CommandLineOptions struct {
+	// Executable is the tag of the executable, when the program of the command line matches several versions
+	Executable string `json:"executable"`
+	Command    string `json:"command"`
+	Job        string `json:"job"`
+	// Create saves the command and the job, otherwise they are only proposed
+	Create bool `json:"create"`
+}
+
+// CommandLineImport is a command line matched to the parameters of an executable : the command and the job
+// which run it, the tokens matching no parameter, and the diagnostic of the flags.
+type CommandLineImport struct {
+	Executable string `json:"executable"`
+	Command    string `json:"command"`
+	// CommandExists is set when a command of the executable already has the same flags, the job reuses it
+	CommandExists bool           `json:"command_exists"`
+	Job           string         `json:"job"`
+	RequiresRoot  bool           `json:"requires_root"`
+	Flags         []string       `json:"flags"`
+	FlagValues    []JobFlagValue `json:"flag_values"`
+	Unknown       []string       `json:"unknown"`
+	Diagnostic    *Diagnostic    `json:"diagnostic"`
+	Created       bool           `json:"created"`
+}
+
+// ImportCommandLine matches a shell command line, e.g. `openssl genpkey -algorithm RSA -out key.pem`, to a command and a job.
+// The program is matched to an executable by path, then by name, and a leading sudo makes the command require root.
+// Each token is a parameter of the executable, or the value of the parameter before it, and --flag=value is split.
+// The other tokens, variable assignments and options of sudo included, are reported as unknown. Proposed names get
+// a numeric suffix when taken. With Create, the command and the job are saved, unless a token is unknown
+// or the flags are refused by the schema, which returns a CombinationError.
+func (i *Instance) ImportCommandLine(ctx context.Context, line string, opts CommandLineOptions) (*CommandLineImport, error) {
+	tokens, err := SplitCommandLine(line)
+	if err != nil {
+		return nil, err
+	}
+
+	imported := &CommandLineImport{Flags: []string{}, FlagValues: []JobFlagValue{}, Unknown: []string{}}
+	tokens = skipPrefix(tokens, imported)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: no program", ErrInvalidCommandLine)
+	}
+
+	exec, err := i.matchExecutable(ctx, tokens[0], opts.Executable)
+	if err != nil {
+		return nil, err
+	}
+	imported.Executable = exec.Tag
+	s, params, err := i.flagSchema(ctx, exec.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens are mapped onto the parameters, a parameter taking a value consumes the next token
+	byFlag := make(map[string]*models.Parameter)
+	for n := range params {
+		byFlag[params[n].Flag] = &params[n]
+	}
+	var values []*models.FlagValue
+	for n := 1; n < len(tokens); n++ {
+		token := tokens[n]
+		param, ok := byFlag[token]
+		value := ""
+		if flag, v, found := strings.Cut(token, "="); !ok && found && byFlag[flag] != nil && byFlag[flag].RequiresValue {
+			param, ok, value = byFlag[flag], true, v
+		} else if ok && param.RequiresValue {
+			if n+1 == len(tokens) {
+				imported.Unknown = append(imported.Unknown, token)
+				continue
+			}
+			n++
+			value = tokens[n]
+		}
+		if !ok {
+			imported.Unknown = append(imported.Unknown, token)
+			continue
+		}
+		imported.Flags = append(imported.Flags, param.Flag)
+		imported.FlagValues = append(imported.FlagValues, JobFlagValue{Flag: param.Flag, Value: value})
+		values = append(values, models.NewFlagValue(param, value))
+	}
+
+	// Missing requirements are reported but added by FME, the values are checked once the flags are valid
+	d := DiagnoseCombination(s, exec.Tag, params, imported.Flags)
+	if v := DiagnoseValues(exec.Tag, values); d.Valid && !v.Valid {
+		d.Valid, d.Values, d.Suggestions = false, v.Values, append(d.Suggestions, v.Suggestions...)
+	}
+	imported.Diagnostic = d
+
+	cmd, err := i.matchCommand(ctx, exec, imported, opts.Command)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Job == "" {
+		imported.Job, err = freeName(imported.Command+"-job", func(name string) error {
+			_, err := i.Repos.Jobs.GetByName(ctx, name)
+			return err
+		})
+	} else if _, err = i.Repos.Jobs.GetByName(ctx, opts.Job); err == nil {
+		err = fmt.Errorf("%w: job %s already exists", ErrInvalidCommandLine, opts.Job)
+	} else if errors.Is(err, models.ErrNotFound) {
+		imported.Job, err = opts.Job, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.Create {
+		return imported, nil
+	}
+	if len(imported.Unknown) > 0 {
+		return imported, fmt.Errorf("%w: %s", ErrUnknownTokens, strings.Join(imported.Unknown, " "))
+	}
+	if !imported.Diagnostic.Valid {
+		return imported, &CombinationError{Diagnostic: imported.Diagnostic}
+	}
+
+	err = i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		if cmd == nil {
+			flags, err := tx.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID, Flags: uniqueFlags(imported.Flags)})
+			if err != nil {
+				return err
+			}
+			cmd = models.NewCommand(imported.Command, "", exec, flags)
+			cmd.RequiresRoot = imported.RequiresRoot
+			if err := tx.Commands.Create(ctx, cmd); err != nil {
+				return fmt.Errorf("failed to save command: %w", err)
+			}
+		}
+
+		job := models.NewJob(imported.Job, cmd, nil)
+		if err := tx.Jobs.Create(ctx, job); err != nil {
+			return fmt.Errorf("failed to save job: %w", err)
+		}
+		var jobValues []*models.FlagValue
+		for _, fv := range imported.FlagValues {
+			n := slices.IndexFunc(cmd.Parameters, func(p models.Parameter) bool { return p.Flag == fv.Flag })
+			jobValues = append(jobValues, models.NewFlagValue(&cmd.Parameters[n], fv.Value))
+		}
+		return tx.Jobs.SaveFlagValues(ctx, job.ID, jobValues)
+	})
+	if err != nil {
+		return imported, err
+	}
+	imported.Created = true
+	return imported, nil
+}
+
+// matchExecutable returns the executable of the program : the one with the given tag, else the one with the same path,
+// else the only one with the name of the program.
+func (i *Instance) matchExecutable(ctx context.Context, program, tag string) (*models.Executable, error) {
+	if tag != "" {
+		return i.Repos.Executables.GetByTag(ctx, tag)
+	}
+	execs, err := i.Repos.Executables.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for n := range execs {
+		if execs[n].Path == program {
+			return &execs[n], nil
+		}
+	}
+
+	var named []*models.Executable
+	for n := range execs {
+		if execs[n].Name == filepath.Base(program) {
+			named = append(named, &execs[n])
+		}
+	}
+	switch len(named) {
+	case 0:
+		return nil, fmt.Errorf("couldn't find an executable for %s: %w", program, models.ErrNotFound)
+	case 1:
+		return named[0], nil
+	}
+	var tags []string
+	for _, exec := range named {
+		tags = append(tags, exec.Tag)
+	}
+	return nil, fmt.Errorf("%w: %s matches %s, give the tag of the executable", ErrInvalidCommandLine, program, strings.Join(tags, ", "))
+}
+
+// matchCommand names the command of the imported command line, and returns it when it already exists with the same flags.
+// Without a name, a command of the executable with the same flags is reused, else the name is made of the executable and its first flag.
+func (i *Instance) matchCommand(ctx context.Context, exec *models.Executable, imported *CommandLineImport, name string) (*models.Command, error) {
+	sameFlags := func(cmd *models.Command) bool {
+		flags := uniqueFlags(imported.Flags)
+		return cmd.RequiresRoot == imported.RequiresRoot && len(cmd.Parameters) == len(flags) &&
+			!slices.ContainsFunc(cmd.Parameters, func(p models.Parameter) bool { return !slices.Contains(flags, p.Flag) })
+	}
+
+	if name != "" {
+		imported.Command = name
+		cmd, err := i.Repos.Commands.GetByName(ctx, name)
+		switch {
+		case errors.Is(err, models.ErrNotFound):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		case uint(cmd.ExecutableID) != exec.ID || !sameFlags(cmd):
+			return nil, fmt.Errorf("%w: command %s already exists with other flags", ErrInvalidCommandLine, name)
+		}
+		imported.CommandExists = true
+		return cmd, nil
+	}
+
+	cmds, err := i.Repos.Commands.Find(ctx, models.CommandFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return nil, err
+	}
+	for n := range cmds {
+		if sameFlags(&cmds[n]) {
+			imported.Command, imported.CommandExists = cmds[n].Name, true
+			return &cmds[n], nil
+		}
+	}
+	name = exec.Name
+	if len(imported.Flags) > 0 {
+		name += "-" + strings.TrimLeft(imported.Flags[0], "-")
+	}
+	imported.Command, err = freeName(name, func(name string) error {
+		_, err := i.Repos.Commands.GetByName(ctx, name)
+		return err
+	})
+	return nil, err
+}
+
+// freeName returns the name, or the name followed by the first numeric suffix from 2 which is free.
+// lookup returns models.ErrNotFound when a name is free.
+func freeName(name string, lookup func(name string) error) (string, error) {
+	candidate := name
+	for n := 2; ; n++ {
+		err := lookup(candidate)
+		if errors.Is(err, models.ErrNotFound) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s-%d", name, n)
+	}
+}
+
+// sudoValueOptions are the options of sudo taking a value.
+var sudoValueOptions = []string{"-C", "-D", "-g", "-h", "-p", "-R", "-r", "-T", "-t", "-U", "-u"}
+
+// skipPrefix returns the tokens from the program on. A leading sudo makes the command require root, the variable
+// assignments and the options of sudo before the program aren't run by OTO and are reported as unknown.
+func skipPrefix(tokens []string, imported *CommandLineImport) []string {
+	for len(tokens) > 0 {
+		token := tokens[0]
+		name, _, assignment := strings.Cut(token, "=")
+		switch {
+		case assignment && validEnvName(name):
+			imported.Unknown = append(imported.Unknown, token)
+		case token == "sudo" && !imported.RequiresRoot:
+			imported.RequiresRoot = true
+		case token == "--" && imported.RequiresRoot:
+			// ends the options of sudo
+		case strings.HasPrefix(token, "-") && imported.RequiresRoot:
+			imported.Unknown = append(imported.Unknown, token)
+			if slices.Contains(sudoValueOptions, token) && len(tokens) > 1 {
+				tokens = tokens[1:]
+				imported.Unknown = append(imported.Unknown, tokens[0])
+			}
+		default:
+			return tokens
+		}
+		tokens = tokens[1:]
+	}
+	return tokens
+}
+
+// SplitCommandLine splits a command line into words the way a POSIX shell does : single quotes keep their content,
+// double quotes keep it except for the escapes of \, ", $ and `, and a backslash escapes the next character.
+// Operators, substitutions and redirections aren't run by OTO and are refused.
+func SplitCommandLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(line)
+	for n := 0; n < len(runes); n++ {
+		r := runes[n]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\'':
+			end := slices.Index(runes[n+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated single quote", ErrInvalidCommandLine)
+			}
+			word.WriteString(string(runes[n+1 : n+1+end]))
+			n += end + 1
+			inWord = true
+		case r == '"':
+			closed := false
+			for n++; n < len(runes); n++ {
+				if runes[n] == '"' {
+					closed = true
+					break
+				}
+				if runes[n] == '\\' && n+1 < len(runes) && strings.ContainsRune("\\\"$`\n", runes[n+1]) {
+					n++
+					if runes[n] == '\n' {
+						continue
+					}
+				} else if runes[n] == '$' || runes[n] == '`' {
+					return nil, fmt.Errorf("%w: substitutions aren't supported", ErrInvalidCommandLine)
+				}
+				word.WriteRune(runes[n])
+			}
+			if !closed {
+				return nil, fmt.Errorf("%w: unterminated double quote", ErrInvalidCommandLine)
+			}
+			inWord = true
+		case r == '\\':
+			if n+1 == len(runes) {
+				return nil, fmt.Errorf("%w: trailing backslash", ErrInvalidCommandLine)
+			}
+			n++
+			if runes[n] != '\n' {
+				word.WriteRune(runes[n])
+				inWord = true
+			}
+		case strings.ContainsRune("|&;<>()$`", r):
+			return nil, fmt.Errorf("%w: %q isn't supported, give a single command", ErrInvalidCommandLine, r)
+		case r == '#' && !inWord:
+			return words, nil
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
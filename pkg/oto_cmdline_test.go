@@ -0,0 +1,111 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	for line, want := range map[string][]string{
+		`openssl genpkey -out key.pem`:               {"openssl", "genpkey", "-out", "key.pem"},
+		`curl -H 'X-Token: a b' "say \"hi\" \$HOME"`: {"curl", "-H", "X-Token: a b", `say "hi" $HOME`},
+		`nmap -p 80\ 443 '' -sT # a comment`:         {"nmap", "-p", "80 443", "", "-sT"},
+		"nmap \\\n  -sT":                             {"nmap", "-sT"},
+	} {
+		if words, err := SplitCommandLine(line); err != nil || !slices.Equal(words, want) {
+			t.Fatalf("expected %q for %s, got %q: %v", want, line, words, err)
+		}
+	}
+	for _, line := range []string{`echo 'open`, `nmap -sT | tee out`, `nmap "$(cat targets)"`, `nmap > out`} {
+		if _, err := SplitCommandLine(line); !errors.Is(err, ErrInvalidCommandLine) {
+			t.Fatalf("expected ErrInvalidCommandLine for %s, got %v", line, err)
+		}
+	}
+}
+
+func TestImportCommandLine(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("openssl", "3.5.3", "/usr/bin/openssl", "cryptographic tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, raw := range []models.ParameterRaw{
+		{Flag: "genpkey", Description: "generate a private key"},
+		{Flag: "-algorithm", Description: "public key algorithm", RequiresValue: true, ValueType: models.String, RequireIDs: []string{"genpkey"}},
+		{Flag: "-pkeyopt", Description: "public key option", RequiresValue: true, ValueType: models.String, RequireIDs: []string{"-algorithm"}},
+		{Flag: "-out", Description: "output file", RequiresValue: true, ValueType: models.String},
+	} {
+		raw.ExecutableTag = "openssl - 3.5.3"
+		if err := instance.AddParameterRaw(ctx, &raw); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	// Without Create, the command and the job are only proposed
+	line := "openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out=key.pem"
+	imported, err := instance.ImportCommandLine(ctx, line, CommandLineOptions{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if imported.Executable != "openssl - 3.5.3" || imported.Command != "openssl-genpkey" || imported.Job != "openssl-genpkey-job" ||
+		imported.CommandExists || !imported.Diagnostic.Valid || len(imported.Unknown) != 0 {
+		t.Fatalf("unexpected import %+v", imported)
+	}
+	if want := []string{"genpkey", "-algorithm", "-pkeyopt", "-out"}; !slices.Equal(imported.Flags, want) || imported.FlagValues[3].Value != "key.pem" {
+		t.Fatalf("unexpected flags %v and values %+v", imported.Flags, imported.FlagValues)
+	}
+	if _, err := instance.Repos.Commands.GetByName(ctx, "openssl-genpkey"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the proposed command not to be saved, got %v", err)
+	}
+
+	if _, err := instance.ImportCommandLine(ctx, line, CommandLineOptions{Command: "GenRSA", Job: "GenRSA-2048", Create: true}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	job, err := instance.Repos.Jobs.GetByName(ctx, "GenRSA-2048")
+	if err != nil || job.Command.Name != "GenRSA" || len(job.FlagValues) != 4 || job.FlagValues[2].Value != "rsa_keygen_bits:2048" {
+		t.Fatalf("unexpected job %+v: %v", job, err)
+	}
+
+	// A command with the same flags is reused, by path as well
+	imported, err = instance.ImportCommandLine(ctx, "/usr/bin/openssl genpkey -algorithm EC -pkeyopt ec_paramgen_curve:P-256 -out ec.pem", CommandLineOptions{Job: "GenEC", Create: true})
+	if err != nil || imported.Command != "GenRSA" || !imported.CommandExists || !imported.Created {
+		t.Fatalf("expected the job to reuse GenRSA, got %+v: %v", imported, err)
+	}
+
+	// Unknown tokens are reported, and refused on creation
+	imported, err = instance.ImportCommandLine(ctx, "sudo openssl genpkey -algorithm RSA -aes256 -out", CommandLineOptions{Create: true})
+	if !errors.Is(err, ErrUnknownTokens) || !slices.Equal(imported.Unknown, []string{"-aes256", "-out"}) || !imported.RequiresRoot || imported.CommandExists {
+		t.Fatalf("expected -aes256 and -out without value to be unknown, got %+v: %v", imported, err)
+	}
+
+	// Proposed names are made unique, a taken name given for the job is refused
+	for _, want := range [][3]string{
+		{"openssl genpkey -algorithm RSA", "openssl-genpkey", "openssl-genpkey-job"},
+		{"openssl genpkey -algorithm EC", "openssl-genpkey", "openssl-genpkey-job-2"},
+		{"openssl genpkey -out k.pem", "openssl-genpkey-2", "openssl-genpkey-2-job"},
+	} {
+		imported, err = instance.ImportCommandLine(ctx, want[0], CommandLineOptions{Create: true})
+		if err != nil || imported.Command != want[1] || imported.Job != want[2] {
+			t.Fatalf("expected %s and %s for %s, got %+v: %v", want[1], want[2], want[0], imported, err)
+		}
+	}
+	if _, err := instance.ImportCommandLine(ctx, line, CommandLineOptions{Job: "GenEC"}); !errors.Is(err, ErrInvalidCommandLine) {
+		t.Fatalf("expected ErrInvalidCommandLine for a taken job name, got %v", err)
+	}
+
+	// Assignments and options of sudo before the program are unknown
+	imported, err = instance.ImportCommandLine(ctx, "OPENSSL_CONF=/etc/ssl.cnf sudo -u bob openssl genpkey", CommandLineOptions{})
+	if err != nil || !slices.Equal(imported.Unknown, []string{"OPENSSL_CONF=/etc/ssl.cnf", "-u", "bob"}) || !imported.RequiresRoot || imported.Executable != "openssl - 3.5.3" {
+		t.Fatalf("expected the words before openssl to be unknown, got %+v: %v", imported, err)
+	}
+
+	var invalid *CombinationError
+	if _, err := instance.ImportCommandLine(ctx, "openssl genpkey -out a.pem -out b.pem", CommandLineOptions{Create: true}); !errors.As(err, &invalid) || len(invalid.Diagnostic.Repeated) != 1 {
+		t.Fatalf("expected a CombinationError for -out given twice, got %v", err)
+	}
+}
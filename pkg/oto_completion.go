@@ -0,0 +1,121 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// Completion guides the building of a command from a partial selection of flags.
+type Completion struct {
+	Executable string   `json:"executable"`
+	Flags      []string `json:"flags"`
+	// Addable are the flags which can still be selected without conflict, with everything they require.
+	Addable []string `json:"addable"`
+	// Required are the flags the selection requires, transitively, but doesn't hold yet.
+	Required []MissingRequirement `json:"required"`
+	// Excluded are the flags ruled out by the selection.
+	Excluded []ExcludedFlag `json:"excluded"`
+	// Groups are the groups whose rule isn't met yet, a flag of each must be selected.
+	Groups []GroupViolation `json:"groups,omitempty"`
+	// Unknown are the selected flags which aren't parameters of the executable, they are ignored.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+// ExcludedFlag is a flag which can't be added to the selection, and why.
+type ExcludedFlag struct {
+	Flag   string `json:"flag"`
+	Reason string `json:"reason"`
+}
+
+// CompleteFlags returns the completion of a partial selection of flags of the executable.
+func (i *Instance) CompleteFlags(ctx context.Context, execTag string, flags []string) (*Completion, error) {
+	s, params, err := i.flagSchema(ctx, execTag)
+	if err != nil {
+		return nil, err
+	}
+	return CompleteCombination(s, execTag, params, flags), nil
+}
+
+// CompleteCombination sorts every parameter of the executable not selected yet : required by the selection,
+// excluded because the flag or one of its requirements interferes with the closure of the selection
+// or shares a one-of group with it, or addable.
+func CompleteCombination(s *fme.Schema, execTag string, params []models.Parameter, flags []string) *Completion {
+	c := &Completion{Executable: execTag, Flags: flags, Addable: []string{}, Required: []MissingRequirement{}, Excluded: []ExcludedFlag{}}
+
+	known := paramFlags(params)
+	var selected []string
+	for _, flag := range flags {
+		if slices.Contains(known, flag) {
+			selected = append(selected, flag)
+		} else {
+			c.Unknown = append(c.Unknown, flag)
+		}
+	}
+
+	g := newFlagGraph(s)
+	closure, parent := g.closure(selected)
+	groups := paramGroups(params)
+
+	for _, flag := range known {
+		if slices.Contains(selected, flag) {
+			continue
+		}
+		if slices.Contains(closure, flag) {
+			path := requirementChain(parent, flag)
+			c.Required = append(c.Required, MissingRequirement{Flag: flag, RequiredBy: path[len(path)-2], Path: path})
+			continue
+		}
+
+		if reason := g.exclusion(flag, closure, parent, groups); reason != "" {
+			c.Excluded = append(c.Excluded, ExcludedFlag{Flag: flag, Reason: reason})
+			continue
+		}
+		c.Addable = append(c.Addable, flag)
+	}
+
+	for _, v := range groupViolations(groups, closure) {
+		if len(v.Selected) == 0 {
+			c.Groups = append(c.Groups, v)
+		}
+	}
+	return c
+}
+
+// exclusion tells why the flag can't join the closure of the selection, empty when it can.
+func (g *flagGraph) exclusion(flag string, closure []string, parent map[string]string, groups []flagGroup) string {
+	added, addedParent := g.closure([]string{flag})
+	for _, a := range added {
+		for _, b := range closure {
+			if g.interfer(a, b) {
+				return fmt.Sprintf("%s interferes with %s",
+					strings.Join(requirementChain(addedParent, a), " -> "),
+					strings.Join(requirementChain(parent, b), " -> "))
+			}
+		}
+	}
+
+	for _, group := range groups {
+		if group.kind != models.GroupOneOf {
+			continue
+		}
+		for _, a := range added {
+			if !slices.Contains(group.members, a) {
+				continue
+			}
+			for _, b := range closure {
+				if b != a && slices.Contains(group.members, b) {
+					return fmt.Sprintf("%s and %s are in the one-of group %s",
+						strings.Join(requirementChain(addedParent, a), " -> "),
+						strings.Join(requirementChain(parent, b), " -> "), group.name)
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package oto
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestCompleteCombination(t *testing.T) {
+	s := fme.NewSchema()
+	s.Require("-sT", "-sL")
+	s.Require("-T", "-sT")
+	s.Interfer("-sK", "-sT")
+
+	var params []models.Parameter
+	for _, flag := range []string{"-sL", "-sT", "-sK", "-T", "-sU"} {
+		params = append(params, models.Parameter{Flag: flag})
+	}
+
+	c := CompleteCombination(s, "nmap - 7.98", params, []string{"-T"})
+	if !slices.Equal(c.Addable, []string{"-sU"}) {
+		t.Fatalf("expected -sU to be addable, got %v", c.Addable)
+	}
+	if len(c.Required) != 2 || c.Required[0].Flag != "-sL" || !slices.Equal(c.Required[0].Path, []string{"-T", "-sT", "-sL"}) || c.Required[1].Flag != "-sT" {
+		t.Fatalf("unexpected required flags %+v", c.Required)
+	}
+	if len(c.Excluded) != 1 || c.Excluded[0].Flag != "-sK" || c.Excluded[0].Reason != "-sK interferes with -T -> -sT" {
+		t.Fatalf("unexpected excluded flags %+v", c.Excluded)
+	}
+
+	// A flag is excluded when one of its requirements interferes with the selection
+	c = CompleteCombination(s, "nmap - 7.98", params, []string{"-sK"})
+	if !slices.Equal(c.Addable, []string{"-sL", "-sU"}) || len(c.Excluded) != 2 || c.Excluded[1].Reason != "-T -> -sT interferes with -sK" {
+		t.Fatalf("unexpected completion %+v", c)
+	}
+}
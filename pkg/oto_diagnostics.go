@@ -0,0 +1,377 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// Diagnostic explains what is wrong with a combination of flags of an executable, and how to fix it.
+// A combination is rejected when it has conflicts, unknown flags, non-repeatable flags given twice or unmet groups.
+// Missing requirements are added to the closure by FME, they are reported so that the command lists every flag it runs with.
+// The values of a job are rejected when a value rule isn't met.
+type Diagnostic struct {
+	Executable  string               `json:"executable"`
+	Flags       []string             `json:"flags"`
+	Valid       bool                 `json:"valid"`
+	Missing     []MissingRequirement `json:"missing,omitempty"`
+	Conflicts   []Conflict           `json:"conflicts,omitempty"`
+	Unknown     []string             `json:"unknown,omitempty"`
+	Repeated    []string             `json:"repeated,omitempty"`
+	Groups      []GroupViolation     `json:"groups,omitempty"`
+	Values      []ValueViolation     `json:"values,omitempty"`
+	Suggestions []string             `json:"suggestions,omitempty"`
+}
+
+// MissingRequirement is a flag required by the combination but not part of it.
+// Path is the chain of requirements from the selected flag to the missing one.
+type MissingRequirement struct {
+	Flag       string   `json:"flag"`
+	RequiredBy string   `json:"required_by"`
+	Path       []string `json:"path"`
+}
+
+// Conflict is a pair of interfering flags found together in the closure of the combination.
+// PathA and PathB are the chains of requirements bringing each flag from a selected one.
+type Conflict struct {
+	A     string   `json:"a"`
+	B     string   `json:"b"`
+	PathA []string `json:"path_a"`
+	PathB []string `json:"path_b"`
+}
+
+// GroupViolation is a group whose rule isn't met : exactly one selected flag for a one-of group, one at least for an at-least-one group.
+// Selected are the members found in the closure of the combination.
+type GroupViolation struct {
+	Group    string   `json:"group"`
+	Kind     string   `json:"kind"`
+	Members  []string `json:"members"`
+	Selected []string `json:"selected"`
+}
+
+// ValueViolation is a value rule of a flag not met by the values of a job.
+// Got holds the values given to the flag required by the rule, none when it isn't set.
+type ValueViolation struct {
+	Flag  string           `json:"flag"`
+	Value string           `json:"value"`
+	Rule  models.ValueRule `json:"rule"`
+	Got   []string         `json:"got"`
+}
+
+// CombinationError is returned when a command is saved with an invalid combination of flags.
+type CombinationError struct {
+	Diagnostic *Diagnostic
+}
+
+func (e *CombinationError) Error() string {
+	return fmt.Sprintf("invalid flag combination for %s : %s", e.Diagnostic.Executable, strings.Join(e.Diagnostic.Suggestions, ", "))
+}
+
+// Unwrap returns fme.ErrCombinationInterfer when the combination has conflicts, as fme.Schema.ValidateCombination does.
+func (e *CombinationError) Unwrap() error {
+	if len(e.Diagnostic.Conflicts) > 0 {
+		return fme.ErrCombinationInterfer
+	}
+	return nil
+}
+
+// ValidateFlags diagnoses a combination of flags against the schema of the executable.
+func (i *Instance) ValidateFlags(ctx context.Context, execTag string, flags []string) (*Diagnostic, error) {
+	s, params, err := i.flagSchema(ctx, execTag)
+	if err != nil {
+		return nil, err
+	}
+	return DiagnoseCombination(s, execTag, params, flags), nil
+}
+
+// flagSchema returns the schema of the executable and its parameters.
+func (i *Instance) flagSchema(ctx context.Context, execTag string) (*fme.Schema, []models.Parameter, error) {
+	exec, err := i.Repos.Executables.GetByTag(ctx, execTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	s, err := i.schemas.get(ctx, i.Repos, exec)
+	if err != nil {
+		return nil, nil, err
+	}
+	params, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, params, nil
+}
+
+// DiagnoseCombination checks the flags the way fme.Schema.ValidateCombination does, without adding unknown flags
+// to the schema : the closure of the selected flags over the requirements mustn't hold an interfering pair.
+// params are the parameters of the executable, a flag which isn't one of them is unknown.
+func DiagnoseCombination(s *fme.Schema, execTag string, params []models.Parameter, flags []string) *Diagnostic {
+	d := &Diagnostic{Executable: execTag, Flags: flags}
+
+	known := paramFlags(params)
+	var selected []string
+	for _, flag := range flags {
+		if slices.Contains(known, flag) {
+			selected = append(selected, flag)
+		} else {
+			d.Unknown = append(d.Unknown, flag)
+		}
+	}
+
+	g := newFlagGraph(s)
+	closure, parent := g.closure(selected)
+	chain := func(flag string) []string { return requirementChain(parent, flag) }
+
+	for _, flag := range closure {
+		if parent[flag] == "" {
+			continue
+		}
+		path := chain(flag)
+		d.Missing = append(d.Missing, MissingRequirement{Flag: flag, RequiredBy: path[len(path)-2], Path: path})
+		d.Suggestions = append(d.Suggestions, fmt.Sprintf("add %s, required by %s", flag, path[len(path)-2]))
+	}
+
+	for n, a := range closure {
+		for _, b := range closure[n+1:] {
+			if !g.interfer(a, b) {
+				continue
+			}
+			pathA, pathB := chain(a), chain(b)
+			d.Conflicts = append(d.Conflicts, Conflict{A: a, B: b, PathA: pathA, PathB: pathB})
+			if pathA[0] == pathB[0] {
+				d.Suggestions = append(d.Suggestions, fmt.Sprintf("remove %s, it requires both %s and %s which interfere", pathA[0], a, b))
+			} else {
+				d.Suggestions = append(d.Suggestions, fmt.Sprintf("remove %s or %s, %s interferes with %s", pathA[0], pathB[0], a, b))
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, flag := range selected {
+		counts[flag]++
+	}
+	for _, p := range params {
+		if counts[p.Flag] > 1 && !p.Repeatable {
+			d.Repeated = append(d.Repeated, p.Flag)
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("give %s once, it isn't repeatable", p.Flag))
+		}
+	}
+
+	d.Groups = groupViolations(paramGroups(params), closure)
+	for _, v := range d.Groups {
+		if len(v.Selected) > 1 {
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("keep only one of %s, group %s", strings.Join(v.Selected, ", "), v.Group))
+		} else {
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("add one of %s, group %s", strings.Join(v.Members, ", "), v.Group))
+		}
+	}
+
+	for _, flag := range d.Unknown {
+		if closest := closestFlag(flag, known); closest != "" {
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("replace %s with %s", flag, closest))
+		} else {
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("remove %s, it isn't a parameter of %s", flag, execTag))
+		}
+	}
+
+	d.Valid = len(d.Conflicts) == 0 && len(d.Unknown) == 0 && len(d.Repeated) == 0 && len(d.Groups) == 0
+	return d
+}
+
+// DiagnoseValues checks the values of a job : a flag given twice must be repeatable, and the value rules
+// of each flag must be met by the other values. The parameter of each value must be loaded.
+func DiagnoseValues(execTag string, values []*models.FlagValue) *Diagnostic {
+	d := &Diagnostic{Executable: execTag}
+
+	given := make(map[string][]string)
+	for _, fv := range values {
+		d.Flags = append(d.Flags, fv.Parameter.Flag)
+		given[fv.Parameter.Flag] = append(given[fv.Parameter.Flag], fv.Value)
+	}
+
+	for _, fv := range values {
+		flag := fv.Parameter.Flag
+		if len(given[flag]) > 1 && !fv.Parameter.Repeatable && !slices.Contains(d.Repeated, flag) {
+			d.Repeated = append(d.Repeated, flag)
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("give %s once, it isn't repeatable", flag))
+		}
+
+		for _, rule := range fv.Parameter.ValueRules {
+			if !rule.Applies(fv.Value) || slices.Contains(given[rule.Flag], rule.Value) {
+				continue
+			}
+			d.Values = append(d.Values, ValueViolation{Flag: flag, Value: fv.Value, Rule: rule, Got: given[rule.Flag]})
+			d.Suggestions = append(d.Suggestions, fmt.Sprintf("set %s to %s, required by %s %s", rule.Flag, rule.Value, flag, fv.Value))
+		}
+	}
+
+	d.Valid = len(d.Repeated) == 0 && len(d.Values) == 0
+	return d
+}
+
+// diagnoseJobFlags reports the flags given values by a job which aren't flags of its command.
+func diagnoseJobFlags(cmd *models.Command, values []JobFlagValue) *Diagnostic {
+	d := &Diagnostic{Executable: cmd.Executable.Tag}
+	known := paramFlags(cmd.Parameters)
+	for _, fv := range values {
+		d.Flags = append(d.Flags, fv.Flag)
+		if !slices.Contains(known, fv.Flag) && !slices.Contains(d.Unknown, fv.Flag) {
+			d.Unknown = append(d.Unknown, fv.Flag)
+		}
+	}
+	slices.Sort(d.Flags)
+	slices.Sort(d.Unknown)
+	for _, flag := range d.Unknown {
+		d.Suggestions = append(d.Suggestions, fmt.Sprintf("remove %s, it isn't a flag of command %s", flag, cmd.Name))
+	}
+	d.Valid = len(d.Unknown) == 0
+	return d
+}
+
+// flagGraph holds the relations of a schema, walked to explain a combination.
+type flagGraph struct {
+	requires      map[string][]string
+	interferences map[string]map[string]struct{}
+}
+
+func newFlagGraph(s *fme.Schema) *flagGraph {
+	g := &flagGraph{requires: make(map[string][]string), interferences: s.Interferences}
+	for _, e := range s.Graph.Edges() {
+		g.requires[e.From] = append(g.requires[e.From], e.To)
+	}
+	for _, to := range g.requires {
+		slices.Sort(to)
+	}
+	return g
+}
+
+// closure returns the flags and everything they require, breadth-first. parent maps a required flag
+// to the flag requiring it, the given flags have an empty parent.
+func (g *flagGraph) closure(flags []string) ([]string, map[string]string) {
+	parent := make(map[string]string)
+	var queue, closure []string
+	for _, flag := range flags {
+		if _, seen := parent[flag]; !seen {
+			parent[flag] = ""
+			queue = append(queue, flag)
+		}
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		closure = append(closure, current)
+		for _, next := range g.requires[current] {
+			if _, seen := parent[next]; !seen {
+				parent[next] = current
+				queue = append(queue, next)
+			}
+		}
+	}
+	return closure, parent
+}
+
+func (g *flagGraph) interfer(a, b string) bool {
+	_, ok := g.interferences[a][b]
+	return ok
+}
+
+// requirementChain returns the requirements leading to the flag, from the flag selected first.
+func requirementChain(parent map[string]string, flag string) []string {
+	path := []string{flag}
+	for p := parent[flag]; p != ""; p = parent[p] {
+		path = append([]string{p}, path...)
+	}
+	return path
+}
+
+// flagGroup is a group of flags declared by the one-of or at-least-one lists of the parameters.
+type flagGroup struct {
+	name    string
+	kind    string
+	members []string
+}
+
+// paramGroups returns the groups of the parameters, in the order they are declared.
+func paramGroups(params []models.Parameter) []flagGroup {
+	var groups []flagGroup
+	add := func(name, kind, flag string) {
+		for n := range groups {
+			if groups[n].name == name && groups[n].kind == kind {
+				groups[n].members = append(groups[n].members, flag)
+				return
+			}
+		}
+		groups = append(groups, flagGroup{name: name, kind: kind, members: []string{flag}})
+	}
+
+	for _, p := range params {
+		for _, name := range p.OneOf {
+			add(name, models.GroupOneOf, p.Flag)
+		}
+		for _, name := range p.AtLeastOne {
+			add(name, models.GroupAtLeastOne, p.Flag)
+		}
+	}
+	return groups
+}
+
+// groupViolations returns the groups whose rule isn't met by the closure of a combination.
+func groupViolations(groups []flagGroup, closure []string) []GroupViolation {
+	var violations []GroupViolation
+	for _, g := range groups {
+		var selected []string
+		for _, m := range g.members {
+			if slices.Contains(closure, m) {
+				selected = append(selected, m)
+			}
+		}
+		if len(selected) == 0 || (g.kind == models.GroupOneOf && len(selected) > 1) {
+			violations = append(violations, GroupViolation{Group: g.name, Kind: g.kind, Members: g.members, Selected: selected})
+		}
+	}
+	return violations
+}
+
+func paramFlags(params []models.Parameter) []string {
+	flags := make([]string, len(params))
+	for n, p := range params {
+		flags[n] = p.Flag
+	}
+	return flags
+}
+
+// closestFlag returns the known flag nearest to flag, within two edits. Empty when there is none.
+func closestFlag(flag string, known []string) string {
+	closest, best := "", 3
+	for _, k := range known {
+		if dist := editDistance(flag, k); dist < best {
+			closest, best = k, dist
+		}
+	}
+	return closest
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
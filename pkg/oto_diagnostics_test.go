@@ -0,0 +1,102 @@
+package oto
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestDiagnoseCombination(t *testing.T) {
+	s := fme.NewSchema()
+	s.Require("-sT", "-sL")
+	s.Require("-T", "-sT")
+	s.Interfer("-sK", "-sT")
+
+	var params []models.Parameter
+	for _, flag := range []string{"-sL", "-sT", "-sK", "-T"} {
+		params = append(params, models.Parameter{Flag: flag})
+	}
+
+	d := DiagnoseCombination(s, "nmap - 7.98", params, []string{"-sK", "-T", "--sK", "--verbose"})
+	if d.Valid {
+		t.Fatalf("expected an invalid combination")
+	}
+
+	if len(d.Missing) != 2 || d.Missing[0].Flag != "-sT" || d.Missing[0].RequiredBy != "-T" ||
+		d.Missing[1].Flag != "-sL" || !slices.Equal(d.Missing[1].Path, []string{"-T", "-sT", "-sL"}) {
+		t.Fatalf("unexpected missing requirements %+v", d.Missing)
+	}
+	if len(d.Conflicts) != 1 || !slices.Equal(d.Conflicts[0].PathA, []string{"-sK"}) || !slices.Equal(d.Conflicts[0].PathB, []string{"-T", "-sT"}) {
+		t.Fatalf("unexpected conflicts %+v", d.Conflicts)
+	}
+	if !slices.Equal(d.Unknown, []string{"--sK", "--verbose"}) {
+		t.Fatalf("unexpected unknown flags %v", d.Unknown)
+	}
+	for _, want := range []string{"add -sL, required by -sT", "remove -sK or -T, -sK interferes with -sT", "replace --sK with -sK", "remove --verbose, it isn't a parameter of nmap - 7.98"} {
+		if !slices.Contains(d.Suggestions, want) {
+			t.Fatalf("expected suggestion %q in %v", want, d.Suggestions)
+		}
+	}
+
+	if err := (&CombinationError{Diagnostic: d}); !errors.Is(err, fme.ErrCombinationInterfer) {
+		t.Fatalf("expected the error to wrap fme.ErrCombinationInterfer")
+	}
+
+	// Requirements alone are added to the closure by FME
+	if d := DiagnoseCombination(s, "nmap - 7.98", params, []string{"-T", "-sT"}); !d.Valid || len(d.Missing) != 1 {
+		t.Fatalf("expected a valid combination missing -sL, got %+v", d)
+	}
+}
+
+func TestDiagnoseRules(t *testing.T) {
+	s := fme.NewSchema()
+	params := []models.Parameter{
+		{Flag: "-sT", OneOf: []string{"scan"}},
+		{Flag: "-sU", OneOf: []string{"scan"}},
+		{Flag: "-iL", AtLeastOne: []string{"target"}},
+		{Flag: "--host", AtLeastOne: []string{"target"}},
+		{Flag: "-v"},
+		{Flag: "-p", Repeatable: true},
+	}
+
+	d := DiagnoseCombination(s, "nmap - 7.98", params, []string{"-sT", "-sU", "-v", "-v", "-p", "-p"})
+	if d.Valid {
+		t.Fatalf("expected an invalid combination")
+	}
+	if !slices.Equal(d.Repeated, []string{"-v"}) {
+		t.Fatalf("unexpected repeated flags %v", d.Repeated)
+	}
+	if len(d.Groups) != 2 || !slices.Equal(d.Groups[0].Selected, []string{"-sT", "-sU"}) || d.Groups[1].Group != "target" || len(d.Groups[1].Selected) != 0 {
+		t.Fatalf("unexpected group violations %+v", d.Groups)
+	}
+	for _, want := range []string{"give -v once, it isn't repeatable", "keep only one of -sT, -sU, group scan", "add one of -iL, --host, group target"} {
+		if !slices.Contains(d.Suggestions, want) {
+			t.Fatalf("expected suggestion %q in %v", want, d.Suggestions)
+		}
+	}
+
+	if d := DiagnoseCombination(s, "nmap - 7.98", params, []string{"-sU", "--host", "-p", "-p"}); !d.Valid {
+		t.Fatalf("expected a valid combination, got %+v", d)
+	}
+
+	algorithm := &models.Parameter{Flag: "-algorithm"}
+	pkeyopt := &models.Parameter{Flag: "-pkeyopt", Repeatable: true, ValueRules: []models.ValueRule{{When: "rsa_keygen_bits", Flag: "-algorithm", Value: "RSA"}}}
+	values := []*models.FlagValue{{Parameter: algorithm, Value: "EC"}, {Parameter: pkeyopt, Value: "rsa_keygen_bits:2048"}, {Parameter: pkeyopt, Value: "ec_paramgen_curve:P-256"}}
+
+	d = DiagnoseValues("openssl - 3.5.3", values)
+	if d.Valid || len(d.Values) != 1 || !slices.Equal(d.Values[0].Got, []string{"EC"}) {
+		t.Fatalf("unexpected value violations %+v", d.Values)
+	}
+	if !slices.Contains(d.Suggestions, "set -algorithm to RSA, required by -pkeyopt rsa_keygen_bits:2048") {
+		t.Fatalf("unexpected suggestions %v", d.Suggestions)
+	}
+
+	values[0].Value = "RSA"
+	if d := DiagnoseValues("openssl - 3.5.3", values); !d.Valid {
+		t.Fatalf("expected valid values, got %+v", d)
+	}
+}
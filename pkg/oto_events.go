@@ -0,0 +1,62 @@
+package oto
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of the events published on the EventBus.
+const (
+	EventApprovalRequested = "approval.requested"
+	EventApprovalDecided   = "approval.decided"
+)
+
+type Event struct {
+	Type string
+	Time time.Time
+	Data any
+}
+
+// EventBus broadcasts events to every subscriber of the instance.
+// A subscriber that doesn't keep up loses events rather than blocking the publisher.
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[chan Event]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving every new event, and a function to stop the subscription.
+func (b *EventBus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *EventBus) Publish(eventType string, data any) {
+	event := Event{Type: eventType, Time: time.Now(), Data: data}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
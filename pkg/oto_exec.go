@@ -0,0 +1,74 @@
+package oto
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// commandLine returns the program to start for the job and its arguments.
+// Commands requiring root are run through sudo, and flags without value are given alone.
+func commandLine(job *models.Job) (string, []string) {
+	program := job.Command.Executable.Path
+
+	var args []string
+	if job.Command.RequiresRoot {
+		args = append(args, program)
+		program = "sudo"
+	}
+
+	for _, fv := range job.FlagValues {
+		// If the argument doesn't have value, we just add the flag to avoid a whitespace
+		if fv.Value == "" {
+			args = append(args, fv.Parameter.Flag)
+		} else {
+			args = append(args, fv.Parameter.Flag, fv.Value)
+		}
+	}
+	return program, args
+}
+
+// executeJob runs the process of the job. Its output is returned even when it failed or has been cancelled.
+func executeJob(ctx context.Context, job *models.Job, grace time.Duration) (*JobOutput, error) {
+	program, args := commandLine(job)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(program, args...)
+	cmd.Dir = job.WorkingDir
+	if len(job.Env) > 0 {
+		cmd.Env = append(os.Environ(), jobEnv(job)...)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := runProcess(ctx, cmd, grace)
+	return &JobOutput{
+		Stdout: stdout.String(),
+		Stderr: stderr.String(),
+	}, err
+}
+
+// jobEnv returns the variables the job adds to the environment of the worker, as KEY=value sorted by key.
+func jobEnv(job *models.Job) []string {
+	var env []string
+	for _, key := range slices.Sorted(maps.Keys(job.Env)) {
+		env = append(env, key+"="+job.Env[key])
+	}
+	return env
+}
+
+// validEnvName reports whether the key can name a variable of the environment in a shell.
+func validEnvName(key string) bool {
+	for n, r := range key {
+		if r != '_' && !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && (n == 0 || !(r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return key != ""
+}
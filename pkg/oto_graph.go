@@ -0,0 +1,172 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// Formats of the parameter graph.
+const (
+	GraphDOT     = "dot"
+	GraphMermaid = "mermaid"
+)
+
+// ErrUnknownGraphFormat is returned for a graph format other than GraphDOT and GraphMermaid.
+var ErrUnknownGraphFormat = errors.New("unknown graph format, expected dot or mermaid")
+
+// paramGraph holds the requirements and interferences between the parameters of an executable.
+type paramGraph struct {
+	title string
+	flags []string
+	// descriptions of the flags, shown as tooltips in DOT
+	descriptions map[string]string
+	// implied are the flags in the graph only because a flag of the command requires them
+	implied    map[string]bool
+	requires   [][2]string
+	interferes [][2]string
+}
+
+// ParameterGraph returns the graph of the requirements and interferences of the parameters of the executable,
+// as Graphviz DOT or Mermaid. With a command name, it is limited to the flags of the command and the flags they require.
+func (i *Instance) ParameterGraph(ctx context.Context, execTag, cmdName, format string) (string, error) {
+	if format != GraphDOT && format != GraphMermaid {
+		return "", fmt.Errorf("%w: %q", ErrUnknownGraphFormat, format)
+	}
+
+	exec, err := i.Repos.Executables.GetByTag(ctx, execTag)
+	if err != nil {
+		return "", err
+	}
+	params, err := i.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return "", err
+	}
+
+	title, selected := exec.Tag, []string(nil)
+	if cmdName != "" {
+		cmd, err := i.Repos.Commands.GetByName(ctx, cmdName)
+		if err != nil {
+			return "", err
+		}
+		if uint(cmd.ExecutableID) != exec.ID {
+			return "", fmt.Errorf("command %s isn't a command of %s: %w", cmdName, exec.Tag, models.ErrNotFound)
+		}
+		title, selected = fmt.Sprintf("%s (%s)", cmd.Name, exec.Tag), paramFlags(cmd.Parameters)
+	}
+
+	g := newParamGraph(title, params, selected)
+	if format == GraphMermaid {
+		return g.mermaid(), nil
+	}
+	return g.dot(), nil
+}
+
+// newParamGraph returns the graph of the parameters. With selected flags, only they and the flags they require are kept.
+func newParamGraph(title string, params []models.Parameter, selected []string) *paramGraph {
+	g := &paramGraph{title: title, descriptions: make(map[string]string), implied: make(map[string]bool)}
+
+	keep := func(string) bool { return true }
+	if selected != nil {
+		closure := slices.Clone(selected)
+		for n := 0; n < len(closure); n++ {
+			for _, p := range params {
+				if p.Flag != closure[n] {
+					continue
+				}
+				for _, r := range p.Require {
+					if !slices.Contains(closure, r.Flag) {
+						closure = append(closure, r.Flag)
+						g.implied[r.Flag] = true
+					}
+				}
+			}
+		}
+		keep = func(flag string) bool { return slices.Contains(closure, flag) }
+	}
+
+	for _, p := range params {
+		if !keep(p.Flag) {
+			continue
+		}
+		g.flags = append(g.flags, p.Flag)
+		g.descriptions[p.Flag] = p.Description
+		for _, r := range p.Require {
+			g.requires = append(g.requires, [2]string{p.Flag, r.Flag})
+		}
+		for _, other := range p.Interfer {
+			pair := [2]string{min(p.Flag, other.Flag), max(p.Flag, other.Flag)}
+			if keep(other.Flag) && !slices.Contains(g.interferes, pair) {
+				g.interferes = append(g.interferes, pair)
+			}
+		}
+	}
+	return g
+}
+
+// dot renders the graph in Graphviz DOT : requirements are arrows, interferences are red dashed lines
+// and the flags implied by a command are dashed.
+func (g *paramGraph) dot() string {
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", quote(g.title))
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+	for _, flag := range g.flags {
+		style := ""
+		if g.implied[flag] {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [tooltip=%s%s];\n", quote(flag), quote(g.descriptions[flag]), style)
+	}
+	for _, e := range g.requires {
+		fmt.Fprintf(&b, "  %s -> %s [label=\"requires\"];\n", quote(e[0]), quote(e[1]))
+	}
+	for _, e := range g.interferes {
+		fmt.Fprintf(&b, "  %s -> %s [label=\"interferes\", dir=none, style=dashed, color=red];\n", quote(e[0]), quote(e[1]))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// mermaid renders the graph as a Mermaid flowchart. Flags are labels of generated node IDs, since they aren't valid IDs.
+func (g *paramGraph) mermaid() string {
+	ids := make(map[string]string)
+	for n, flag := range g.flags {
+		ids[flag] = fmt.Sprintf("p%d", n)
+	}
+	label := func(s string) string {
+		return `"` + strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s) + `"`
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "---\ntitle: %s\n---\nflowchart LR\n", label(g.title))
+	for _, flag := range g.flags {
+		fmt.Fprintf(&b, "  %s[%s]\n", ids[flag], label(flag))
+	}
+	for _, e := range g.requires {
+		fmt.Fprintf(&b, "  %s -->|requires| %s\n", ids[e[0]], ids[e[1]])
+	}
+	for n, e := range g.interferes {
+		fmt.Fprintf(&b, "  %s -.-|interferes| %s\n", ids[e[0]], ids[e[1]])
+		fmt.Fprintf(&b, "  linkStyle %d stroke:red\n", len(g.requires)+n)
+	}
+
+	var implied []string
+	for _, flag := range g.flags {
+		if g.implied[flag] {
+			implied = append(implied, ids[flag])
+		}
+	}
+	if len(implied) > 0 {
+		b.WriteString("  classDef implied stroke-dasharray: 5 5\n")
+		fmt.Fprintf(&b, "  class %s implied\n", strings.Join(implied, ","))
+	}
+	return b.String()
+}
@@ -0,0 +1,66 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestParameterGraph(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, raw := range []models.ParameterRaw{
+		{Flag: "-sL", Description: `list "targets"`},
+		{Flag: "-sT", Description: "connect scan", RequireIDs: []string{"-sL"}},
+		{Flag: "-sK", Description: "kill scan", InterferIDs: []string{"-sT"}},
+		{Flag: "-v", Description: "verbose"},
+	} {
+		raw.ExecutableTag = "nmap - 7.98"
+		if err := instance.AddParameterRaw(ctx, &raw); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := instance.AddCommand(ctx, "nmap - 7.98", "connect", "", []string{"-sT", "-v"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	dot, err := instance.ParameterGraph(ctx, "nmap - 7.98", "", GraphDOT)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, want := range []string{
+		`digraph "nmap - 7.98" {`,
+		`"-sL" [tooltip="list \"targets\""];`,
+		`"-sT" -> "-sL" [label="requires"];`,
+		`"-sK" -> "-sT" [label="interferes", dir=none, style=dashed, color=red];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Fatalf("expected %q in\n%s", want, dot)
+		}
+	}
+
+	// Limited to the command, -sL is only required by it and -sK is left out
+	mermaid, err := instance.ParameterGraph(ctx, "nmap - 7.98", "connect", GraphMermaid)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, want := range []string{"flowchart LR", `p0["-sL"]`, `p1["-sT"]`, "p1 -->|requires| p0", "class p0 implied"} {
+		if !strings.Contains(mermaid, want) {
+			t.Fatalf("expected %q in\n%s", want, mermaid)
+		}
+	}
+	if strings.Contains(mermaid, "-sK") || strings.Contains(mermaid, "interferes") {
+		t.Fatalf("expected -sK to be left out of\n%s", mermaid)
+	}
+
+	if _, err := instance.ParameterGraph(ctx, "nmap - 7.98", "", "svg"); !errors.Is(err, ErrUnknownGraphFormat) {
+		t.Fatalf("expected ErrUnknownGraphFormat, got %v", err)
+	}
+}
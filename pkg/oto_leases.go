@@ -0,0 +1,228 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// leaseTTL is how long a lease is kept without renewal. The slots of a crashed worker are freed after it.
+const leaseTTL = 30 * time.Second
+
+// leasePollInterval is how often a run waiting for a slot tries again.
+const leasePollInterval = time.Second
+
+// waiterStaleAfter is how long a run waiting for a slot keeps its place without trying again.
+const waiterStaleAfter = 5 * leasePollInterval
+
+// ErrRunSkipped is returned when a concurrency limit with LimitSkip is reached. The run is recorded as skipped.
+var ErrRunSkipped = errors.New("concurrency limit reached, run skipped")
+
+// acquireLeases takes a slot in every concurrency limit of the run, from the widest scope to the job.
+// When a scope is full, its behavior applies : LimitQueue waits for a free slot, LimitSkip returns ErrRunSkipped,
+// LimitCancelPrevious revokes the lease of the oldest run of the scope and waits for it to stop.
+// A waiting run holds no slot, and records the scope it waits for : a freed slot goes to the waiting run
+// with the highest priority, the oldest first. wait is called on each try, the Temporal activity heartbeats there.
+func acquireLeases(ctx context.Context, db *gorm.DB, runID, priority int, limits []models.ScopedLimit, wait func()) error {
+	if len(limits) == 0 {
+		return nil
+	}
+
+	for waiting := false; ; waiting = true {
+		full, err := tryLeases(ctx, db, runID, priority, limits)
+		if err != nil {
+			return err
+		}
+		if full == nil {
+			if waiting {
+				return setWaitingFor(ctx, db, runID, "")
+			}
+			return nil
+		}
+		if full.Limit.Behavior() != models.LimitSkip {
+			if err := setWaitingFor(ctx, db, runID, full.Scope); err != nil {
+				return err
+			}
+		}
+
+		switch full.Limit.Behavior() {
+		case models.LimitSkip:
+			return fmt.Errorf("%w : %d runs of %s in progress", ErrRunSkipped, full.Limit.MaxRuns, full.Scope)
+		case models.LimitCancelPrevious:
+			if err := revokeOldestLease(ctx, db, full.Scope, runID); err != nil {
+				return err
+			}
+		}
+
+		if wait != nil {
+			wait()
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(leasePollInterval):
+		}
+	}
+}
+
+// tryLeases takes the leases the run doesn't hold yet. It returns the first full scope, after releasing
+// every lease of the run, or nil when the run holds a slot in each scope.
+// A scope is full for the run as long as the runs before it waiting for the scope take its free slots.
+func tryLeases(ctx context.Context, db *gorm.DB, runID, priority int, limits []models.ScopedLimit) (*models.ScopedLimit, error) {
+	db = db.WithContext(ctx)
+	now := time.Now()
+
+	scopes := make([]string, len(limits))
+	for i, l := range limits {
+		scopes[i] = l.Scope
+	}
+	if err := db.Where("scope IN ? AND expires_at < ?", scopes, now).Delete(&models.RunLease{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to free expired leases: %w", err)
+	}
+
+	var held []string
+	if err := db.Model(&models.RunLease{}).Where("run_id = ?", runID).Pluck("scope", &held).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch leases of run %d: %w", runID, err)
+	}
+
+	for i, l := range limits {
+		if slices.Contains(held, l.Scope) {
+			continue
+		}
+
+		// The run is ranked as if it was waiting for the scope
+		position, err := models.QueuePosition(ctx, db, &models.Run{Model: gorm.Model{ID: uint(runID)}, Priority: priority, WaitingFor: l.Scope, Status: models.RunPending}, waiterStaleAfter)
+		if err != nil {
+			return nil, err
+		}
+
+		var taken int64
+		if err := db.Model(&models.RunLease{}).Where("scope = ?", l.Scope).Count(&taken).Error; err != nil {
+			return nil, fmt.Errorf("failed to count leases of %s: %w", l.Scope, err)
+		}
+
+		ok := false
+		if position <= l.Limit.MaxRuns-int(taken) {
+			ok, err = takeSlot(db, runID, l, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !ok {
+			if err := releaseLeases(ctx, db, runID); err != nil {
+				return nil, err
+			}
+			return &limits[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// takeSlot inserts a lease on the first free slot of the scope. The unique index on the slot settles concurrent inserts.
+func takeSlot(db *gorm.DB, runID int, l models.ScopedLimit, now time.Time) (bool, error) {
+	for slot := 0; slot < l.Limit.MaxRuns; slot++ {
+		lease := &models.RunLease{Scope: l.Scope, Slot: slot, RunID: runID, ExpiresAt: now.Add(leaseTTL)}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(lease)
+		if res.Error != nil {
+			return false, fmt.Errorf("failed to take a slot of %s: %w", l.Scope, res.Error)
+		}
+		if res.RowsAffected == 1 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setWaitingFor records the scope the run is waiting for. The update also tells it is still waiting.
+func setWaitingFor(ctx context.Context, db *gorm.DB, runID int, scope string) error {
+	if err := db.WithContext(ctx).Model(&models.Run{}).Where("id = ?", runID).Update("waiting_for", scope).Error; err != nil {
+		return fmt.Errorf("failed to update run %d: %w", runID, err)
+	}
+	return nil
+}
+
+// revokeOldestLease asks the oldest run of the scope to stop. Nothing is revoked while a revoked run is still stopping.
+func revokeOldestLease(ctx context.Context, db *gorm.DB, scope string, runID int) error {
+	db = db.WithContext(ctx)
+
+	var stopping int64
+	if err := db.Model(&models.RunLease{}).Where("scope = ? AND revoked = ?", scope, true).Count(&stopping).Error; err != nil {
+		return err
+	}
+	if stopping > 0 {
+		return nil
+	}
+
+	var oldest models.RunLease
+	err := db.Where("scope = ? AND run_id <> ?", scope, runID).Order("created_at, id").First(&oldest).Error
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.Model(&oldest).Update("revoked", true).Error
+}
+
+// renewLeases keeps the leases of the run for ttl more, and reports whether one of them has been revoked.
+func renewLeases(ctx context.Context, db *gorm.DB, runID int, ttl time.Duration) (bool, error) {
+	db = db.WithContext(ctx)
+	if err := db.Model(&models.RunLease{}).Where("run_id = ?", runID).Update("expires_at", time.Now().Add(ttl)).Error; err != nil {
+		return false, fmt.Errorf("failed to renew leases of run %d: %w", runID, err)
+	}
+
+	var revoked int64
+	if err := db.Model(&models.RunLease{}).Where("run_id = ? AND revoked = ?", runID, true).Count(&revoked).Error; err != nil {
+		return false, err
+	}
+	return revoked > 0, nil
+}
+
+// holdLeases renews the leases of the run on each heartbeat until the returned function is called,
+// which waits for a renewal in progress. cancel is called when a newer run revokes one of them.
+func holdLeases(ctx context.Context, db *gorm.DB, runID int, cancel context.CancelFunc) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			revoked, err := renewLeases(ctx, db, runID, leaseTTL)
+			if err != nil {
+				log.Printf("%v", err)
+			}
+			if revoked {
+				cancel()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// releaseLeases frees every slot held by the run.
+func releaseLeases(ctx context.Context, db *gorm.DB, runID int) error {
+	if err := db.WithContext(ctx).Where("run_id = ?", runID).Delete(&models.RunLease{}).Error; err != nil {
+		return fmt.Errorf("failed to release leases of run %d: %w", runID, err)
+	}
+	return nil
+}
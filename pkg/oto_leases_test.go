@@ -0,0 +1,242 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestLeasesQueueAndCancelPrevious(t *testing.T) {
+	runner := newShellJob(t, "true", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	limits := []models.ScopedLimit{{Scope: models.JobScope(1), Limit: models.ConcurrencyLimit{MaxRuns: 1}}}
+	if err := acquireLeases(ctx, runner.db, 1, 0, limits, nil); err != nil {
+		t.Fatalf("failed to acquire the free slot: %v", err)
+	}
+
+	// The second run waits without holding any slot
+	waitCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancel()
+	if err := acquireLeases(waitCtx, runner.db, 2, 0, limits, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second run to wait, got %v", err)
+	}
+	var held int64
+	runner.db.Model(&models.RunLease{}).Where("run_id = ?", 2).Count(&held)
+	if held != 0 {
+		t.Fatalf("expected a waiting run to hold no lease, got %d", held)
+	}
+
+	// With cancel-previous, the first run is asked to stop and its slot is taken once released
+	limits[0].Limit.OnLimit = models.LimitCancelPrevious
+	acquired := make(chan error, 1)
+	go func() { acquired <- acquireLeases(ctx, runner.db, 2, 0, limits, nil) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		revoked, err := renewLeases(ctx, runner.db, 1, leaseTTL)
+		if err != nil {
+			t.Fatalf("failed to renew leases: %v", err)
+		}
+		if revoked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the lease of the first run to be revoked")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := releaseLeases(ctx, runner.db, 1); err != nil {
+		t.Fatalf("failed to release leases: %v", err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected the second run to take the slot, got %v", err)
+	}
+}
+
+func TestLocalRunnersShareLimits(t *testing.T) {
+	runner := newShellJob(t, "sleep 30", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	if err := runner.repos.Jobs.SaveConcurrency(ctx, job.ID, models.ConcurrencyLimit{MaxRuns: 1, OnLimit: models.LimitSkip}); err != nil {
+		t.Fatalf("failed to save limit: %v", err)
+	}
+
+	first, err := runner.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		run, _ := runner.repos.Runs.Get(ctx, first.ID)
+		if run != nil && run.Status == models.RunRunning {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the first run to be running")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Another process sharing the database claims the second run, the limit of the job skips it
+	other := NewLocalRunner(runner.db, runner.repos, time.Second, 1, models.ConcurrencyLimit{}, time.Hour)
+	defer other.Close()
+	second, err := other.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	if _, err := other.Wait(ctx, second.ID, 10*time.Second); !errors.Is(err, ErrRunSkipped) {
+		t.Fatalf("expected ErrRunSkipped, got %v", err)
+	}
+
+	run, err := runner.repos.Runs.Get(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch run: %v", err)
+	}
+	if run.Status != models.RunSkipped || len(run.Attempts) != 0 {
+		t.Fatalf("expected a skipped run without attempts, got %s with %d", run.Status, len(run.Attempts))
+	}
+}
+
+func TestWaitersShareFreeSlots(t *testing.T) {
+	runner := newShellJob(t, "true", models.RetryPolicy{})
+	runner.Close()
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	limits := []models.ScopedLimit{{Scope: models.JobScope(job.ID), Limit: models.ConcurrencyLimit{MaxRuns: 2}}}
+	first, _ := saveRun(ctx, runner.repos.Runs, job, 5, "", "first", nil, LocalQueue)
+	if err := setWaitingFor(ctx, runner.db, int(first.ID), limits[0].Scope); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// The second waiter takes the other free slot without waiting for the first one
+	second, _ := saveRun(ctx, runner.repos.Runs, job, 0, "", "second", nil, LocalQueue)
+	waitCtx, cancel := context.WithTimeout(ctx, leasePollInterval/2)
+	defer cancel()
+	if err := acquireLeases(waitCtx, runner.db, int(second.ID), second.Priority, limits, nil); err != nil {
+		t.Fatalf("expected the second waiter to take a free slot, got %v", err)
+	}
+	if err := acquireLeases(waitCtx, runner.db, int(first.ID), first.Priority, limits, nil); err != nil {
+		t.Fatalf("expected the first waiter to take the last slot, got %v", err)
+	}
+}
+
+func TestPriorityOrdersQueueAndSlots(t *testing.T) {
+	runner := newShellJob(t, "true", models.RetryPolicy{})
+	runner.Close() // runs stay queued
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	urgent := 5
+	low, _ := saveRun(ctx, runner.repos.Runs, job, 0, "", "low", nil, LocalQueue)
+	high, _ := saveRun(ctx, runner.repos.Runs, job, job.RunPriority(&urgent), "", "high", nil, LocalQueue)
+
+	if position, _ := models.QueuePosition(ctx, runner.db, low, waiterStaleAfter); position != 2 {
+		t.Fatalf("expected the low priority run to be second, got %d", position)
+	}
+	claimed, err := models.ClaimRun(ctx, runner.db, LocalQueue, waiterStaleAfter)
+	if err != nil || claimed.ID != high.ID {
+		t.Fatalf("expected the high priority run to be claimed first, got %v %v", claimed, err)
+	}
+
+	// Once the slot is freed, it goes to the waiting run with the highest priority, even if it came last
+	limits := []models.ScopedLimit{{Scope: models.JobScope(job.ID), Limit: models.ConcurrencyLimit{MaxRuns: 1}}}
+	if err := acquireLeases(ctx, runner.db, 100, 0, limits, nil); err != nil {
+		t.Fatalf("failed to acquire the free slot: %v", err)
+	}
+	order := make(chan uint, 2)
+	var waiters sync.WaitGroup
+	defer waiters.Wait()
+	for _, run := range []*models.Run{low, high} {
+		waiters.Add(1)
+		go func() {
+			defer waiters.Done()
+			if err := acquireLeases(ctx, runner.db, int(run.ID), run.Priority, limits, nil); err == nil {
+				order <- run.ID
+				releaseLeases(ctx, runner.db, int(run.ID))
+			}
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	state, _ := runner.repos.Runs.Get(ctx, low.ID)
+	if position, _ := models.QueuePosition(ctx, runner.db, state, waiterStaleAfter); state.WaitingFor != limits[0].Scope || position != 2 {
+		t.Fatalf("expected the low priority run to wait second for %s, got %q at %d", limits[0].Scope, state.WaitingFor, position)
+	}
+
+	releaseLeases(ctx, runner.db, 100)
+	if first := <-order; first != high.ID {
+		t.Fatalf("expected the high priority run to take the slot first, got run %d", first)
+	}
+	if second := <-order; second != low.ID {
+		t.Fatalf("expected the low priority run to follow, got run %d", second)
+	}
+}
+
+func TestLocalWaiterFreesProcessSlot(t *testing.T) {
+	runner := newShellJob(t, "true", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	if err := runner.repos.Jobs.SaveConcurrency(ctx, job.ID, models.ConcurrencyLimit{MaxRuns: 1}); err != nil {
+		t.Fatalf("failed to save limit: %v", err)
+	}
+	if err := runner.db.Create(models.NewJob("free", job.Command, job.FlagValues)).Error; err != nil {
+		t.Fatalf("failed to save job: %v", err)
+	}
+	limits := []models.ScopedLimit{{Scope: models.JobScope(job.ID), Limit: models.ConcurrencyLimit{MaxRuns: 1}}}
+	if err := acquireLeases(ctx, runner.db, 100, 0, limits, nil); err != nil {
+		t.Fatalf("failed to acquire the free slot: %v", err)
+	}
+
+	// The limited run waits as pending, without the only slot of the runner
+	limited, err := runner.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		run, _ := runner.repos.Runs.Get(ctx, limited.ID)
+		if run != nil && run.Status == models.RunPending && run.WaitingFor != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the limited run to wait as pending")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	free, err := runner.Start(ctx, "free", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	if _, err := runner.Wait(ctx, free.ID, 5*time.Second); err != nil {
+		t.Fatalf("expected the other run to take the slot, got %v", err)
+	}
+
+	if err := releaseLeases(ctx, runner.db, 100); err != nil {
+		t.Fatalf("failed to release leases: %v", err)
+	}
+	if _, err := runner.Wait(ctx, limited.ID, 5*time.Second); err != nil {
+		t.Fatalf("expected the limited run to succeed once the limit is free, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package oto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// ErrInvalidManifest is returned when a manifest can't be read into a bundle.
+var ErrInvalidManifest = fmt.Errorf("%w: invalid manifest", ErrInvalidBundle)
+
+// ApplyOptions changes how Apply converges on the manifests.
+type ApplyOptions struct {
+	// DryRun returns the plan without saving it
+	DryRun bool
+	// Prune deletes the records missing from the manifests : schedules, workflows, jobs, commands and executables
+	Prune bool
+}
+
+// manifestDocument is a document of a manifest : the kind of the record, and its fields in the bundle format.
+type manifestDocument[T any] struct {
+	Kind   string `yaml:"kind"`
+	Record T      `yaml:",inline"`
+}
+
+// LoadManifests reads the YAML manifests of the files, and of the .yaml and .yml files of the directories, into a bundle.
+// Directories are walked in lexical order.
+func LoadManifests(paths ...string) (*Bundle, error) {
+	b := &Bundle{Version: BundleVersion}
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(file string, entry os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() || (file != path && !slices.Contains([]string{".yaml", ".yml"}, filepath.Ext(file))) {
+				return nil
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			return ParseManifest(b, data, file)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// ParseManifest adds the records of the documents of a YAML manifest to the bundle. Each document has a kind :
+// executable, command, job, workflow or schedule, and the fields of the record in the bundle format.
+// Unknown fields are refused. source names the manifest in the errors.
+func ParseManifest(b *Bundle, data []byte, source string) error {
+	file, err := parser.ParseBytes(data, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidManifest, source, err)
+	}
+
+	for n, doc := range file.Docs {
+		if doc.Body == nil {
+			continue
+		}
+		var head struct {
+			Kind string `yaml:"kind"`
+		}
+		if err := yaml.NodeToValue(doc.Body, &head); err != nil {
+			return fmt.Errorf("%w: %s: document %d: %w", ErrInvalidManifest, source, n+1, err)
+		}
+
+		switch head.Kind {
+		case KindExecutable:
+			err = appendRecord(&b.Executables, doc.Body)
+		case KindCommand:
+			err = appendRecord(&b.Commands, doc.Body)
+		case KindJob:
+			err = appendRecord(&b.Jobs, doc.Body)
+		case KindWorkflow:
+			err = appendRecord(&b.Workflows, doc.Body)
+		case KindSchedule:
+			err = appendRecord(&b.Schedules, doc.Body)
+		default:
+			err = fmt.Errorf("unknown kind %q, expected executable, command, job, workflow or schedule", head.Kind)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: document %d: %w", ErrInvalidManifest, source, n+1, err)
+		}
+	}
+	return nil
+}
+
+func appendRecord[T any](records *[]T, body ast.Node) error {
+	var doc manifestDocument[T]
+	if err := yaml.NodeToValue(body, &doc, yaml.DisallowUnknownField()); err != nil {
+		return err
+	}
+	*records = append(*records, doc.Record)
+	return nil
+}
+
+// WriteManifests writes the bundle as a YAML manifest, a document per record. Empty fields are left out.
+func WriteManifests(w io.Writer, b *Bundle) error {
+	var docs []any
+	for _, e := range b.Executables {
+		e.Parameters = slices.Clone(e.Parameters)
+		for n := range e.Parameters {
+			e.Parameters[n].ExecutableTag = ""
+		}
+		docs = append(docs, manifestDocument[BundleExecutable]{KindExecutable, e})
+	}
+	for _, c := range b.Commands {
+		docs = append(docs, manifestDocument[BundleCommand]{KindCommand, c})
+	}
+	for _, j := range b.Jobs {
+		docs = append(docs, manifestDocument[BundleJob]{KindJob, j})
+	}
+	for _, wf := range b.Workflows {
+		docs = append(docs, manifestDocument[BundleWorkflow]{KindWorkflow, wf})
+	}
+	for _, s := range b.Schedules {
+		docs = append(docs, manifestDocument[models.ScheduleRaw]{KindSchedule, s})
+	}
+
+	var out bytes.Buffer
+	for n, doc := range docs {
+		data, err := yaml.MarshalWithOptions(doc, yaml.OmitEmpty())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			out.WriteString("---\n")
+		}
+		out.Write(data)
+	}
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Apply converges the configuration on the manifests : the records of the bundle are created or updated as ImportBundle does,
+// and with Prune, the records missing from the manifests are deleted, after the ones depending on them.
+// Everything is saved in a single transaction.
+func (i *Instance) Apply(ctx context.Context, b *Bundle, opts ApplyOptions) (*Plan, error) {
+	return i.importBundle(ctx, b, opts.DryRun, opts.Prune)
+}
+
+// planPrune returns the deletions of the records missing from the bundle, the ones depending on a record before it.
+func planPrune(current, b *Bundle) []Change {
+	var changes []Change
+	prune := func(kind string, currentKeys, keys []string) {
+		for _, key := range currentKeys {
+			if !slices.Contains(keys, key) {
+				changes = append(changes, Change{Action: ActionDelete, Kind: kind, Name: key})
+			}
+		}
+	}
+
+	scheduleName := func(s *models.ScheduleRaw) string { return s.Name }
+	workflowName := func(w *BundleWorkflow) string { return w.Name }
+	jobName := func(j *BundleJob) string { return j.Name }
+	commandName := func(c *BundleCommand) string { return c.Name }
+	prune(KindSchedule, keysOf(current.Schedules, scheduleName), keysOf(b.Schedules, scheduleName))
+	prune(KindWorkflow, keysOf(current.Workflows, workflowName), keysOf(b.Workflows, workflowName))
+	prune(KindJob, keysOf(current.Jobs, jobName), keysOf(b.Jobs, jobName))
+	prune(KindCommand, keysOf(current.Commands, commandName), keysOf(b.Commands, commandName))
+	prune(KindExecutable, keysOf(current.Executables, (*BundleExecutable).Tag), keysOf(b.Executables, (*BundleExecutable).Tag))
+	return changes
+}
+
+func keysOf[T any](records []T, key func(*T) string) []string {
+	keys := make([]string, len(records))
+	for n := range records {
+		keys[n] = key(&records[n])
+	}
+	return keys
+}
@@ -0,0 +1,170 @@
+package oto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+const testManifest = `
+kind: executable
+name: nmap
+version: "7.98"
+path: /usr/bin/nmap
+parameters:
+  - flag: -sL
+    description: list scan
+  - flag: -sT
+    description: connect scan
+    require_ids: [-sL]
+  - flag: -p
+    description: ports
+    requires_value: true
+    value_type: port
+---
+kind: command
+name: ports
+executable: nmap - 7.98
+flags: [-sT, -p]
+---
+kind: job
+name: ports-80
+command: ports
+flag_values:
+  - flag: -p
+    value: "80"
+---
+kind: workflow
+name: nightly
+nodes:
+  - kind: approval
+    approvers: [alice]
+    timeout: 1h
+  - kind: job
+    job: ports-80
+`
+
+const testMasscanManifest = `
+kind: executable
+name: masscan
+version: "1.3"
+path: /usr/bin/masscan
+---
+kind: schedule
+name: every-night
+job: ports-80
+spec: 0 3 * * *
+`
+
+func TestApplyManifests(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	b := &Bundle{Version: BundleVersion}
+	if err := ParseManifest(b, []byte(testManifest), "nmap.yaml"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := ParseManifest(b, []byte(testMasscanManifest), "masscan.yaml"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := instance.Apply(ctx, b, ApplyOptions{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	wf, err := instance.Repos.Workflows.GetByName(ctx, "nightly")
+	if err != nil || len(wf.Nodes) != 2 || wf.Nodes[0].Timeout != time.Hour {
+		t.Fatalf("unexpected workflow %+v: %v", wf, err)
+	}
+
+	// The written manifest is read back to the same configuration
+	exported, _ := instance.ExportBundle(ctx)
+	var out bytes.Buffer
+	if err := WriteManifests(&out, exported); err != nil {
+		t.Fatalf("%v", err)
+	}
+	written := &Bundle{Version: BundleVersion}
+	if err := ParseManifest(written, out.Bytes(), "written.yaml"); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if plan, err := instance.Apply(ctx, written, ApplyOptions{Prune: true, DryRun: true}); err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("expected no changes, got\n%s: %v", plan, err)
+	}
+
+	// Pruning deletes what the manifests no longer hold, dependents first
+	b = &Bundle{Version: BundleVersion}
+	if err := ParseManifest(b, []byte(testManifest), "nmap.yaml"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if plan, err := instance.Apply(ctx, b, ApplyOptions{}); err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("expected apply without prune to keep the records, got\n%s: %v", plan, err)
+	}
+	plan, err := instance.Apply(ctx, b, ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if plan.String() != "- schedule every-night\n- executable masscan - 1.3\n" {
+		t.Fatalf("unexpected plan\n%s", plan)
+	}
+	if _, err := instance.Repos.Executables.GetByTag(ctx, "masscan - 1.3"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected masscan to be pruned, got %v", err)
+	}
+
+	// A pruned record still used by a managed one is refused
+	b.Jobs = nil
+	if _, err := instance.Apply(ctx, b, ApplyOptions{Prune: true}); !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), "job ports-80 doesn't exist") {
+		t.Fatalf("expected the workflow to need its job, got %v", err)
+	}
+
+	err = ParseManifest(&Bundle{}, []byte("kind: command\nname: ports\nflag: -p\n"), "typo.yaml")
+	if !errors.Is(err, ErrInvalidManifest) || !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidManifest for an unknown field, got %v", err)
+	}
+}
+
+func TestPruneThenApplyAgain(t *testing.T) {
+	instance, db := newSqliteInstance(t)
+	ctx := context.Background()
+
+	b := &Bundle{Version: BundleVersion}
+	if err := ParseManifest(b, []byte(testManifest), "nmap.yaml"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := ParseManifest(b, []byte(testMasscanManifest), "masscan.yaml"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if _, err := instance.Apply(ctx, b, ApplyOptions{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	job, _ := instance.Repos.Jobs.GetByName(ctx, "ports-80")
+	run := models.NewRun(job)
+	if err := instance.Repos.Runs.Create(ctx, run); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// A job isn't pruned while one of its runs is pending
+	if _, err := instance.Apply(ctx, &Bundle{Version: BundleVersion}, ApplyOptions{Prune: true}); !errors.Is(err, models.ErrActiveRuns) {
+		t.Fatalf("expected the prune to be refused, got %v", err)
+	}
+	if _, err := instance.Repos.Jobs.GetByName(ctx, "ports-80"); err != nil {
+		t.Fatalf("expected the job to be kept, got %v", err)
+	}
+	if err := finishRun(ctx, db, int(run.ID), models.RunSucceeded, ""); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Every record is pruned, then the same names are applied again
+	plan, err := instance.Apply(ctx, &Bundle{Version: BundleVersion}, ApplyOptions{Prune: true})
+	if err != nil || len(plan.Changes) != 6 {
+		t.Fatalf("expected every record to be pruned, got\n%s: %v", plan, err)
+	}
+	if runs, _ := instance.Repos.Runs.ListByJob(ctx, job.ID); len(runs) != 1 {
+		t.Fatalf("expected the runs of the pruned job to be kept, got %d", len(runs))
+	}
+	if plan, err := instance.Apply(ctx, b, ApplyOptions{}); err != nil || len(plan.Changes) != 9 {
+		t.Fatalf("expected the pruned records to be created again, got\n%s: %v", plan, err)
+	}
+}
@@ -0,0 +1,160 @@
+package oto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+
+	"github.com/Bl4omArchie/oto/models"
+	"github.com/Bl4omArchie/oto/packs"
+)
+
+// PackSchemaVersion is the version of the format of the tool packs read by OTO.
+const PackSchemaVersion = 1
+
+// ErrInvalidPack is returned when a tool pack can't be read or installed.
+var ErrInvalidPack = errors.New("invalid tool pack")
+
+// Pack describes a tool pack embedded in OTO : the catalog of the parameters of an executable,
+// for the versions it supports.
+type Pack struct {
+	Name          string `json:"name"`
+	Version       string `json:"version"`
+	SchemaVersion int    `json:"schema_version"`
+	Executable    string `json:"executable"`
+	// Versions are the versions of the executable matching the catalog, the last one is installed by default
+	Versions    []string `json:"versions"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Author      string   `json:"author"`
+	Parameters  int      `json:"parameters"`
+	// Installed are the tags of the executables of the pack in the instance, only filled by ListPacks
+	Installed []string `json:"installed,omitempty"`
+}
+
+// Packs returns the tool packs embedded in OTO, by name.
+func Packs() ([]Pack, error) {
+	files, err := fs.Glob(packs.FS, "*/pack.json")
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Pack, 0, len(files))
+	for _, file := range files {
+		p, _, err := loadPack(path.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, nil
+}
+
+// loadPack reads the metadata and the catalog of an embedded pack.
+func loadPack(name string) (*Pack, []models.ParameterRaw, error) {
+	var p Pack
+	if err := decodePackFile(path.Join(name, "pack.json"), &p); err != nil {
+		return nil, nil, err
+	}
+	var catalog []models.ParameterRaw
+	if err := decodePackFile(path.Join(name, "catalog.json"), &catalog); err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case p.SchemaVersion != PackSchemaVersion:
+		return nil, nil, fmt.Errorf("%w: pack %s has schema version %d, expected %d", ErrInvalidPack, name, p.SchemaVersion, PackSchemaVersion)
+	case p.Name != name:
+		return nil, nil, fmt.Errorf("%w: pack %s is named %s", ErrInvalidPack, name, p.Name)
+	case p.Executable == "" || len(p.Versions) == 0:
+		return nil, nil, fmt.Errorf("%w: pack %s has no executable or version", ErrInvalidPack, name)
+	}
+	p.Parameters = len(catalog)
+	return &p, catalog, nil
+}
+
+func decodePackFile(file string, v any) error {
+	data, err := fs.ReadFile(packs.FS, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("couldn't find pack %s: %w", path.Dir(file), models.ErrNotFound)
+	} else if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrInvalidPack, file, err)
+	}
+	return nil
+}
+
+// ListPacks returns the embedded tool packs, with the tags of their executables already in the instance.
+func (i *Instance) ListPacks(ctx context.Context) ([]Pack, error) {
+	list, err := Packs()
+	if err != nil {
+		return nil, err
+	}
+	execs, err := i.Repos.Executables.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for n := range list {
+		for _, exec := range execs {
+			if exec.Name == list[n].Executable {
+				list[n].Installed = append(list[n].Installed, exec.Tag)
+			}
+		}
+	}
+	return list, nil
+}
+
+// InstallPack creates the executable of a tool pack with its parameters, in a single transaction. An empty version installs
+// the last version supported by the pack, an empty path the path of the pack.
+func (i *Instance) InstallPack(ctx context.Context, name, version, execPath string) (*models.Executable, error) {
+	p, catalog, err := loadPack(name)
+	if err != nil {
+		return nil, err
+	}
+	if version == "" {
+		version = p.Versions[len(p.Versions)-1]
+	} else if !slices.Contains(p.Versions, version) {
+		return nil, fmt.Errorf("%w: pack %s supports %s %v, not %s", ErrInvalidPack, name, p.Executable, p.Versions, version)
+	}
+	if execPath == "" {
+		execPath = p.Path
+	}
+
+	i.schemas.changes.Lock()
+	defer i.schemas.changes.Unlock()
+
+	exec := models.NewExecutable(p.Executable, version, execPath, p.Description)
+	if _, err := i.Repos.Executables.GetByTag(ctx, exec.Tag); err == nil {
+		return nil, fmt.Errorf("%w: %s is already installed", ErrInvalidPack, exec.Tag)
+	} else if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	s, problems := checkCatalog(exec, nil, catalog)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: pack %s: %w", ErrInvalidPack, name, errors.Join(problems...))
+	}
+
+	err = i.Repos.Transaction(ctx, func(tx *models.Repositories) error {
+		if err := tx.Executables.Create(ctx, exec); err != nil {
+			return fmt.Errorf("failed to save Executable: %w", err)
+		}
+		return saveCatalog(ctx, tx, exec, catalog)
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.schemas.publish(exec.Tag, s)
+	return exec, nil
+}
@@ -0,0 +1,59 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestInstallPacks(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	list, err := instance.ListPacks(ctx)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	var names []string
+	for _, p := range list {
+		names = append(names, p.Name)
+		if p.Author == "" || p.Parameters == 0 || len(p.Installed) != 0 {
+			t.Fatalf("unexpected pack %+v", p)
+		}
+	}
+	if !slices.Equal(names, []string{"masscan", "nmap", "openssl"}) {
+		t.Fatalf("unexpected packs %v", names)
+	}
+
+	// Every embedded catalog forms a valid schema
+	for _, p := range list {
+		exec, err := instance.InstallPack(ctx, p.Name, "", "")
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		params, _ := instance.Repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+		if exec.Path != p.Path || len(params) != p.Parameters {
+			t.Fatalf("unexpected executable %+v with %d parameters", exec, len(params))
+		}
+	}
+	if d, err := instance.ValidateFlags(ctx, "openssl - 3.5.3", []string{"genpkey", "-algorithm", "-out"}); err != nil || !d.Valid {
+		t.Fatalf("expected the schema of openssl, got %+v: %v", d, err)
+	}
+
+	list, _ = instance.ListPacks(ctx)
+	if !slices.Equal(list[1].Installed, []string{"nmap - 7.98"}) {
+		t.Fatalf("expected nmap to be installed, got %v", list[1].Installed)
+	}
+	if _, err := instance.InstallPack(ctx, "nmap", "", ""); !errors.Is(err, ErrInvalidPack) {
+		t.Fatalf("expected an installed pack to be refused, got %v", err)
+	}
+	if _, err := instance.InstallPack(ctx, "nmap", "6.0", ""); !errors.Is(err, ErrInvalidPack) {
+		t.Fatalf("expected an unsupported version to be refused, got %v", err)
+	}
+	if _, err := instance.InstallPack(ctx, "curl", "", ""); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
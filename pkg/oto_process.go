@@ -0,0 +1,42 @@
+package oto
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// DefaultKillGracePeriod is the time given to a process between SIGTERM and SIGKILL when its run is cancelled.
+const DefaultKillGracePeriod = 10 * time.Second
+
+// runProcess starts cmd in its own process group and waits for it.
+// When ctx is cancelled, the whole group receives SIGTERM, then SIGKILL once the grace period is over.
+// Whatever the process wrote before being stopped stays in cmd.Stdout and cmd.Stderr.
+func runProcess(ctx context.Context, cmd *exec.Cmd, grace time.Duration) error {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = grace
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	terminateProcessGroup(cmd)
+	select {
+	case <-done:
+	case <-time.After(grace):
+		killProcessGroup(cmd)
+		<-done
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package oto
+
+import (
+	"os/exec"
+)
+
+// Process groups are only supported on unix : the process itself is stopped.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func terminateProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package oto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunProcessCancelKeepsOutput(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The shell ignores SIGTERM, so the process group must be killed after the grace period
+	var stdout bytes.Buffer
+	cmd := exec.Command("sh", "-c", "trap '' TERM; echo partial; sleep 30")
+	cmd.Stdout = &stdout
+
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := runProcess(ctx, cmd, 300*time.Millisecond)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("process wasn't killed after the grace period (%v)", elapsed)
+	}
+	if !strings.Contains(stdout.String(), "partial") {
+		t.Fatalf("partial output has been lost: %q", stdout.String())
+	}
+}
@@ -0,0 +1,122 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestInstanceWithMemoryRepositories(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddExecutable("curl", "8.0", "/usr/bin/curl", "transfer tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-sL", "list scan", false, false, models.String, []string{}, []string{}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddCommand(ctx, "nmap - 7.98", "list", "list targets", []string{"-sL"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// Parameters are looked up by executable, not by a column given by the caller
+	nmap, _ := instance.Repos.Executables.GetByTag(ctx, "nmap - 7.98")
+	curl, _ := instance.Repos.Executables.GetByTag(ctx, "curl - 8.0")
+	if _, err := instance.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-sL"); err != nil {
+		t.Fatalf("expected -sL in nmap: %v", err)
+	}
+	if _, err := instance.Repos.Parameters.GetByFlag(ctx, curl.ID, "-sL"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for -sL in curl, got %v", err)
+	}
+
+	cmds, err := instance.Repos.Commands.Find(ctx, models.CommandFilter{ExecutableID: nmap.ID})
+	if err != nil || len(cmds) != 1 || cmds[0].Name != "list" {
+		t.Fatalf("expected the command list of nmap, got %v %v", cmds, err)
+	}
+
+	if _, err := instance.Repos.Jobs.GetByName(ctx, "missing"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFlagsScopedToExecutable(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	// nmap and masscan both have -p, masscan is loaded last
+	for _, exec := range [][2]string{{"nmap", "7.98"}, {"masscan", "1.3"}} {
+		if err := instance.AddExecutable(exec[0], exec[1], "/usr/bin/"+exec[0], "scanning tool"); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-p", "ports", false, true, models.Port, nil, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameter(ctx, "masscan - 1.3", "-p", "ports", false, true, models.Port, nil, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameter(ctx, "masscan - 1.3", "--banners", "grab banners", false, false, models.None, []string{"-p"}, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-sV", "versions", false, false, models.None, []string{"--banners"}, nil); err == nil {
+		t.Fatalf("expected --banners of masscan not to be found for nmap")
+	}
+
+	masscan, _ := instance.Repos.Executables.GetByTag(ctx, "masscan - 1.3")
+	banners, err := instance.Repos.Parameters.GetByFlag(ctx, masscan.ID, "--banners")
+	if err != nil || len(banners.Require) != 1 || uint(banners.Require[0].ExecutableID) != masscan.ID {
+		t.Fatalf("expected --banners to require the -p of masscan, got %+v: %v", banners, err)
+	}
+
+	if err := instance.AddCommand(ctx, "masscan - 1.3", "banners", "", []string{"--banners", "-p"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	cmd, _ := instance.Repos.Commands.GetByName(ctx, "banners")
+	for _, p := range cmd.Parameters {
+		if uint(p.ExecutableID) != masscan.ID {
+			t.Fatalf("expected the flags of masscan, got %s of executable %d", p.Flag, p.ExecutableID)
+		}
+	}
+
+	if err := instance.AddJob(ctx, "banners", "banners-80", []JobFlagValue{{Flag: "-p", Value: "80"}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	job, _ := instance.Repos.Jobs.GetByName(ctx, "banners-80")
+	if len(job.FlagValues) != 1 || uint(job.FlagValues[0].Parameter.ExecutableID) != masscan.ID {
+		t.Fatalf("expected the value of the -p of masscan, got %+v", job.FlagValues)
+	}
+
+	// A repeatable flag keeps each of its values, in order
+	if err := instance.AddParameterRaw(ctx, &models.ParameterRaw{Flag: "--exclude", ExecutableTag: "masscan - 1.3", RequiresValue: true, ValueType: models.String, Repeatable: true}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddCommand(ctx, "masscan - 1.3", "excluding", "", []string{"-p", "--exclude"}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	values := []JobFlagValue{{Flag: "--exclude", Value: "10.0.0.1"}, {Flag: "-p", Value: "80"}, {Flag: "--exclude", Value: "10.0.0.2"}}
+	if err := instance.AddJob(ctx, "excluding", "excluding-80", values); err != nil {
+		t.Fatalf("%v", err)
+	}
+	job, _ = instance.Repos.Jobs.GetByName(ctx, "excluding-80")
+	if _, args := commandLine(job); strings.Join(args, " ") != "--exclude 10.0.0.1 -p 80 --exclude 10.0.0.2" {
+		t.Fatalf("expected both values of --exclude, got %v", args)
+	}
+
+	// A value for a flag the command doesn't have is refused
+	var invalid *CombinationError
+	err = instance.AddJob(ctx, "banners", "banners-rate", []JobFlagValue{{Flag: "-p", Value: "80"}, {Flag: "--rate", Value: "1000"}})
+	if !errors.As(err, &invalid) || len(invalid.Diagnostic.Unknown) != 1 || invalid.Diagnostic.Unknown[0] != "--rate" {
+		t.Fatalf("expected --rate to be refused, got %v", err)
+	}
+	if _, err := instance.Repos.Jobs.GetByName(ctx, "banners-rate"); !errors.Is(err, models.ErrNotFound) {
+		t.Fatalf("expected the refused job not to be saved")
+	}
+}
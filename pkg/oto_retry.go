@@ -0,0 +1,48 @@
+package oto
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// Failure classes reported by the activities. Use them in RetryPolicy.NonRetryableErrors.
+const (
+	ErrorJobNotFound = "JobNotFound" // the job doesn't exist anymore, never retried
+	ErrorExecFailure = "ExecFailure" // the executable couldn't be started
+	ErrorExitStatus  = "ExitStatus"  // the executable returned a non-zero exit code
+	ErrorRunSkipped  = "RunSkipped"  // a concurrency limit with LimitSkip was reached
+)
+
+// NewTemporalRetryPolicy converts a retry policy of a job into its Temporal equivalent.
+func NewTemporalRetryPolicy(p models.RetryPolicy) *temporal.RetryPolicy {
+	return &temporal.RetryPolicy{
+		MaximumAttempts:        p.MaximumAttempts,
+		InitialInterval:        p.InitialInterval,
+		BackoffCoefficient:     p.BackoffCoefficient,
+		MaximumInterval:        p.MaximumInterval,
+		NonRetryableErrorTypes: p.NonRetryableErrors,
+	}
+}
+
+// failureClass returns the failure class of the error of a process.
+func failureClass(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return ErrorExitStatus
+	}
+	return ErrorExecFailure
+}
+
+// classifyRunError wraps the error of a process into an application error carrying its failure class.
+func classifyRunError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return temporal.NewApplicationErrorWithCause(fmt.Sprintf("process exited with code %d", exitErr.ExitCode()), ErrorExitStatus, err)
+	}
+	return temporal.NewApplicationErrorWithCause("couldn't start the process", failureClass(err), err)
+}
@@ -0,0 +1,27 @@
+package oto
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestJobRetryPolicyInheritance(t *testing.T) {
+	cmd := &models.Command{Retry: models.RetryPolicy{MaximumAttempts: 5, NonRetryableErrors: []string{ErrorExitStatus}}}
+	job := &models.Job{Command: cmd, Retry: models.RetryPolicy{InitialInterval: 10 * time.Second}}
+
+	p := NewTemporalRetryPolicy(job.RetryPolicy())
+	if p.MaximumAttempts != 5 {
+		t.Fatalf("maximum attempts should be inherited from the command, got %d", p.MaximumAttempts)
+	}
+	if p.InitialInterval != 10*time.Second {
+		t.Fatalf("initial interval should come from the job, got %v", p.InitialInterval)
+	}
+	if p.MaximumInterval != models.DefaultRetryPolicy().MaximumInterval {
+		t.Fatalf("maximum interval should fallback on the default policy, got %v", p.MaximumInterval)
+	}
+	if len(p.NonRetryableErrorTypes) != 1 || p.NonRetryableErrorTypes[0] != ErrorExitStatus {
+		t.Fatalf("unexpected non retryable errors %v", p.NonRetryableErrorTypes)
+	}
+}
@@ -0,0 +1,175 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// Backends of the Runner, selected by OTO_RUNNER.
+const (
+	RunnerTemporal = "temporal"
+	RunnerLocal    = "local"
+)
+
+// ErrTemporalRequired is returned by the features only available with the Temporal runner, such as workers and workflows.
+var ErrTemporalRequired = errors.New("this feature requires the temporal runner")
+
+// RunOptions are given by the trigger of a run. Unset fields keep the settings of the job.
+type RunOptions struct {
+	// Priority overrides the priority of the job. Runs with a higher priority are dispatched first.
+	Priority *int
+	// IdempotencyKey makes the trigger safe to repeat : within the idempotency window, starting the job again
+	// with the same key returns the run started the first time.
+	IdempotencyKey string
+}
+
+// Runner executes the runs of jobs. Whatever the backend, runs and their attempts are recorded the same way in the database.
+type Runner interface {
+	// Start creates a run of the job and returns it without waiting for the process.
+	Start(ctx context.Context, jobName string, opts RunOptions) (*models.Run, error)
+
+	// Wait waits for the end of the run and returns its output.
+	// With a timeout greater than zero, ErrWaitTimeout is returned if the run isn't done in time.
+	Wait(ctx context.Context, runID uint, timeout time.Duration) (*JobOutput, error)
+
+	// Cancel stops the run. The process gets SIGTERM, then SIGKILL after the grace period.
+	Cancel(ctx context.Context, runID uint) error
+
+	// Close releases the backend, once the runs in progress are stopped.
+	Close()
+}
+
+// TemporalRunner runs every job in WorkflowRunJob, on the task queue of a capable worker.
+type TemporalRunner struct {
+	client    client.Client
+	db        *gorm.DB
+	repos     *models.Repositories
+	taskQueue string
+	window    time.Duration
+}
+
+// NewTemporalRunner returns a runner starting the workflows with c. window is how long an idempotency key is kept.
+func NewTemporalRunner(c client.Client, db *gorm.DB, repos *models.Repositories, taskQueue string, window time.Duration) *TemporalRunner {
+	return &TemporalRunner{
+		client:    c,
+		db:        db,
+		repos:     repos,
+		taskQueue: taskQueue,
+		window:    window,
+	}
+}
+
+// Start routes the run to the task queue of a worker able to run the job, ErrNoCapableWorker is returned when there is none.
+// With an idempotency key, the workflow ID is derived from the key : Temporal refuses a second workflow
+// while the first one is open, the database keeps the key for the idempotency window.
+// The ID of the execution is saved too, Wait and Cancel use it since the workflow ID is reused once the window is over.
+func (r *TemporalRunner) Start(ctx context.Context, jobName string, opts RunOptions) (*models.Run, error) {
+	workflowOptions := client.StartWorkflowOptions{
+		ID: fmt.Sprintf("job-%s-%d", jobName, time.Now().UnixNano()),
+	}
+	if opts.IdempotencyKey != "" {
+		workflowOptions.ID = fmt.Sprintf("job-%s-key-%s", jobName, opts.IdempotencyKey)
+		workflowOptions.WorkflowIDReusePolicy = enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE
+		workflowOptions.WorkflowIDConflictPolicy = enumspb.WORKFLOW_ID_CONFLICT_POLICY_FAIL
+	}
+
+	job, err := r.repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return nil, err
+	}
+
+	return startOnce(ctx, r.repos.Runs, job, opts.IdempotencyKey, r.window, func() (*models.Run, error) {
+		input, err := createJobRun(ctx, r.db, r.repos.Runs, job, job.RunPriority(opts.Priority), opts.IdempotencyKey, workflowOptions.ID, nil, r.taskQueue)
+		if err != nil {
+			return nil, err
+		}
+		workflowOptions.TaskQueue = input.TaskQueue
+		workflowOptions.Priority = temporalPriority(input.Priority)
+
+		we, err := r.client.ExecuteWorkflow(ctx, workflowOptions, WorkflowRunJob, *input)
+		if err != nil {
+			if temporal.IsWorkflowExecutionAlreadyStartedError(err) {
+				err = fmt.Errorf("a run with idempotency key %s is still in progress: %w", opts.IdempotencyKey, err)
+			}
+			finishRun(ctx, r.db, input.RunID, models.RunFailed, err.Error())
+			// The trigger can try again with the same key
+			r.db.WithContext(ctx).Model(&models.Run{}).Where("id = ?", input.RunID).Update("dedup_key", nil)
+			return nil, fmt.Errorf("failed to start run of job %s: %w", jobName, err)
+		}
+		if err := r.db.WithContext(ctx).Model(&models.Run{}).Where("id = ?", input.RunID).Update("temporal_run_id", we.GetRunID()).Error; err != nil {
+			return nil, fmt.Errorf("failed to update run %d: %w", input.RunID, err)
+		}
+
+		return r.repos.Runs.Get(ctx, uint(input.RunID))
+	})
+}
+
+func (r *TemporalRunner) Wait(ctx context.Context, runID uint, timeout time.Duration) (*JobOutput, error) {
+	run, err := r.repos.Runs.Get(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var result JobOutput
+	if err := r.client.GetWorkflow(ctx, run.WorkflowID, run.TemporalRunID).Get(ctx, &result); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrWaitTimeout
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (r *TemporalRunner) Cancel(ctx context.Context, runID uint) error {
+	run, err := fetchCancellableRun(ctx, r.repos.Runs, runID)
+	if err != nil {
+		return err
+	}
+
+	if err := r.client.CancelWorkflow(ctx, run.WorkflowID, run.TemporalRunID); err != nil {
+		return fmt.Errorf("failed to cancel run %d: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (r *TemporalRunner) Close() {
+	r.client.Close()
+}
+
+// temporalPriority converts the priority of a run into a Temporal priority key, where 1 is dispatched first.
+// Priority 0 is the default key 3, each step moves one key, within the default range of the server from 1 to 5.
+func temporalPriority(priority int) temporal.Priority {
+	return temporal.Priority{PriorityKey: min(max(3-priority, 1), 5)}
+}
+
+// fetchCancellableRun returns the run when it has been started and isn't finished.
+func fetchCancellableRun(ctx context.Context, runs models.RunRepository, runID uint) (*models.Run, error) {
+	run, err := runs.Get(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	if run.Finished() {
+		return nil, fmt.Errorf("run %d is already %s", run.ID, run.Status)
+	}
+	if run.WorkflowID == "" {
+		return nil, fmt.Errorf("run %d hasn't been started yet", run.ID)
+	}
+	return run, nil
+}
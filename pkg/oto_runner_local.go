@@ -0,0 +1,400 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// LocalQueue is the queue of the runs waiting for a local runner.
+const LocalQueue = "local"
+
+// DefaultLocalConcurrency is the number of runs a local runner executes at the same time.
+const DefaultLocalConcurrency = 4
+
+// localPollInterval is how often the queue is checked for runs started by other processes,
+// and how often Wait checks the database for a run executed elsewhere.
+const localPollInterval = time.Second
+
+// LocalRunner runs the jobs in the current process, without Temporal.
+// Started runs are queued in the database, then claimed by any local runner sharing the database.
+// The retry policy and the concurrency limits of the job are applied the same way WorkflowRunJob does.
+type LocalRunner struct {
+	db          *gorm.DB
+	repos       *models.Repositories
+	grace       time.Duration
+	concurrency int
+	global      models.ConcurrencyLimit
+	window      time.Duration
+
+	mu      sync.Mutex
+	running map[uint]*localRun
+	wg      sync.WaitGroup
+
+	// slots holds a value for each run executed by this process, a run waiting for a concurrency limit holds none
+	slots chan struct{}
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// localRun is a run in progress in this process.
+type localRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	output *JobOutput
+	err    error
+}
+
+// NewLocalRunner returns a runner claiming the queued runs until it is closed.
+// concurrency is the number of runs executed by this process, global the limit shared by every worker.
+// window is how long an idempotency key is kept.
+func NewLocalRunner(db *gorm.DB, repos *models.Repositories, grace time.Duration, concurrency int, global models.ConcurrencyLimit, window time.Duration) *LocalRunner {
+	if concurrency <= 0 {
+		concurrency = DefaultLocalConcurrency
+	}
+
+	r := &LocalRunner{
+		db:          db,
+		repos:       repos,
+		grace:       grace,
+		concurrency: concurrency,
+		global:      global,
+		window:      window,
+		running:     make(map[uint]*localRun),
+		slots:       make(chan struct{}, concurrency),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go r.dispatch()
+	return r
+}
+
+// Start queues a run of the job. It is executed as soon as a local runner has a free slot, higher priorities first.
+func (r *LocalRunner) Start(ctx context.Context, jobName string, opts RunOptions) (*models.Run, error) {
+	job, err := r.repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return nil, err
+	}
+
+	return startOnce(ctx, r.repos.Runs, job, opts.IdempotencyKey, r.window, func() (*models.Run, error) {
+		run, err := saveRun(ctx, r.repos.Runs, job, job.RunPriority(opts.Priority), opts.IdempotencyKey, fmt.Sprintf("local-%s-%d", jobName, time.Now().UnixNano()), nil, LocalQueue)
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+		return r.repos.Runs.Get(ctx, run.ID)
+	})
+}
+
+// dispatch claims queued runs while there are free slots, until the runner is closed.
+func (r *LocalRunner) dispatch() {
+	defer close(r.done)
+	ticker := time.NewTicker(localPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for r.claim() {
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim starts the next queued run when a slot is free. It reports whether a run has been started.
+func (r *LocalRunner) claim() bool {
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		return false
+	}
+
+	run, err := models.ClaimRun(context.Background(), r.db, LocalQueue, waiterStaleAfter)
+	if err == nil && run != nil {
+		var job *models.Job
+		job, err = r.repos.Jobs.Get(context.Background(), uint(run.JobID))
+		if err != nil {
+			finishRun(context.Background(), r.db, int(run.ID), models.RunFailed, fmt.Sprintf("couldn't find job %d. %v", run.JobID, err))
+			<-r.slots
+			return true
+		}
+
+		// The run outlives the request that started it
+		runCtx, cancel := context.WithCancel(context.Background())
+		lr := &localRun{cancel: cancel, done: make(chan struct{})}
+
+		r.mu.Lock()
+		r.running[run.ID] = lr
+		r.mu.Unlock()
+
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer cancel()
+			r.execute(runCtx, int(run.ID), run.Priority, job, lr)
+		}()
+		return true
+	}
+
+	if err != nil {
+		log.Printf("failed to claim a run: %v", err)
+	}
+	<-r.slots
+	return false
+}
+
+// execute makes the attempts of the run until one succeeds, the retry policy gives up or the run is cancelled.
+// The run is claimed with a slot of this process, which it gives back when it has to wait for a concurrency limit.
+func (r *LocalRunner) execute(ctx context.Context, runID, priority int, job *models.Job, lr *localRun) {
+	holding := true
+	defer func() {
+		if holding {
+			<-r.slots
+		}
+		r.mu.Lock()
+		delete(r.running, uint(runID))
+		r.mu.Unlock()
+		close(lr.done)
+	}()
+
+	// A run waiting for a concurrency limit goes back to pending and frees its slot for the other runs.
+	// Its scope is recorded before, so that no runner claims it meanwhile.
+	wait := func() {
+		if !holding {
+			return
+		}
+		err := r.db.WithContext(ctx).Model(&models.Run{}).Where("id = ? AND status = ?", runID, models.RunRunning).Update("status", models.RunPending).Error
+		if err != nil {
+			log.Printf("failed to put run %d back to pending: %v", runID, err)
+			return
+		}
+		holding = false
+		<-r.slots
+	}
+	err := acquireLeases(ctx, r.db, runID, priority, job.ConcurrencyLimits(r.global), wait)
+	if err == nil {
+		stopLeases := holdLeases(ctx, r.db, runID, lr.cancel)
+		defer stopLeases()
+		if !holding {
+			err = r.resume(ctx, runID)
+			holding = err == nil
+		}
+	}
+	if err != nil && !holding && (errors.Is(err, errRunTaken) || r.closing()) {
+		// Cancelled elsewhere, or left pending for the next local runner
+		lr.err = err
+		if err := releaseLeases(context.WithoutCancel(ctx), r.db, runID); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+	if err != nil {
+		status := models.RunFailed
+		switch {
+		case errors.Is(err, ErrRunSkipped):
+			status = models.RunSkipped
+		case ctx.Err() != nil:
+			status, err = models.RunCancelled, ctx.Err()
+		}
+		lr.err = err
+		if err := finishRun(context.WithoutCancel(ctx), r.db, runID, status, err.Error()); err != nil {
+			log.Printf("failed to record the end of run %d: %v", runID, err)
+		}
+		return
+	}
+
+	policy := job.RetryPolicy()
+	status, errMsg := models.RunSucceeded, ""
+	for number := int32(1); ; number++ {
+		attempt, err := startAttempt(ctx, r.db, runID, number, job)
+		if err != nil {
+			status, errMsg, lr.err = models.RunFailed, err.Error(), err
+			break
+		}
+
+		lr.output, lr.err = executeJob(ctx, job, r.grace)
+		finishAttempt(ctx, r.db, attempt, lr.output, lr.err)
+
+		if ctx.Err() != nil {
+			status, errMsg, lr.err = models.RunCancelled, "run cancelled", ctx.Err()
+			break
+		}
+		if lr.err == nil {
+			break
+		}
+
+		status, errMsg = models.RunFailed, lr.err.Error()
+		if !policy.Retryable(number, failureClass(lr.err)) {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(policy.Backoff(number)):
+		}
+		// Cancelled during the backoff
+		if ctx.Err() != nil {
+			status, errMsg, lr.err = models.RunCancelled, "run cancelled", ctx.Err()
+			break
+		}
+	}
+
+	if err := finishRun(context.WithoutCancel(ctx), r.db, runID, status, errMsg); err != nil {
+		log.Printf("failed to record the end of run %d: %v", runID, err)
+	}
+}
+
+// errRunTaken is returned when a run which got its leases isn't pending anymore, it has been cancelled by another process.
+var errRunTaken = errors.New("run no longer pending")
+
+// closing reports whether Close has been called.
+func (r *LocalRunner) closing() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// resume takes a slot of this process for a pending run which got its leases, then moves it back to running.
+// Its leases keep other runners from claiming it meanwhile.
+func (r *LocalRunner) resume(ctx context.Context, runID int) error {
+	select {
+	case r.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	res := r.db.WithContext(ctx).Model(&models.Run{}).
+		Where("id = ? AND status = ?", runID, models.RunPending).
+		Update("status", models.RunRunning)
+	if res.Error == nil && res.RowsAffected == 1 {
+		return nil
+	}
+	<-r.slots
+	if res.Error != nil {
+		return fmt.Errorf("failed to update run %d: %w", runID, res.Error)
+	}
+	return fmt.Errorf("%w : run %d", errRunTaken, runID)
+}
+
+// Wait waits on the run when it is in progress in this process, otherwise the database is polled until it is finished.
+func (r *LocalRunner) Wait(ctx context.Context, runID uint, timeout time.Duration) (*JobOutput, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(localPollInterval / 4)
+	defer ticker.Stop()
+	for {
+		r.mu.Lock()
+		lr, ok := r.running[runID]
+		r.mu.Unlock()
+
+		if ok {
+			select {
+			case <-lr.done:
+				return lr.output, lr.err
+			case <-ctx.Done():
+				return nil, waitError(ctx)
+			}
+		}
+
+		run, err := r.repos.Runs.Get(ctx, runID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, waitError(ctx)
+			}
+			return nil, err
+		}
+		if run.Finished() {
+			return runOutput(run)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, waitError(ctx)
+		}
+	}
+}
+
+// Cancel removes a queued run from the queue, or stops it when it is in progress in this process.
+func (r *LocalRunner) Cancel(ctx context.Context, runID uint) error {
+	run, err := fetchCancellableRun(ctx, r.repos.Runs, runID)
+	if err != nil {
+		return err
+	}
+
+	if run.Status == models.RunPending {
+		now := time.Now()
+		res := r.db.WithContext(ctx).Model(&models.Run{}).
+			Where("id = ? AND status = ?", run.ID, models.RunPending).
+			Updates(map[string]any{"status": models.RunCancelled, "error": "run cancelled", "finished_at": &now})
+		if res.Error != nil {
+			return fmt.Errorf("failed to cancel run %d: %w", run.ID, res.Error)
+		}
+		if res.RowsAffected == 1 {
+			// A run waiting for a concurrency limit in this process stops waiting
+			r.mu.Lock()
+			if lr, ok := r.running[run.ID]; ok {
+				lr.cancel()
+			}
+			r.mu.Unlock()
+			return nil
+		}
+		// Claimed in the meantime
+	}
+
+	r.mu.Lock()
+	lr, ok := r.running[run.ID]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("run %d isn't running in this process", run.ID)
+	}
+
+	lr.cancel()
+	return nil
+}
+
+// Close stops claiming runs, cancels the ones in progress and waits for their processes to stop.
+// Queued runs, and the ones waiting for a concurrency limit, stay in the queue for the next local runner.
+func (r *LocalRunner) Close() {
+	close(r.stop)
+	<-r.done
+
+	r.mu.Lock()
+	for _, lr := range r.running {
+		lr.cancel()
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func waitError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrWaitTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,166 @@
+package oto
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bl4omArchie/simple"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// newShellJob saves a job running the given script with sh, in a new sqlite database.
+func newShellJob(t *testing.T, script string, retry models.RetryPolicy) *LocalRunner {
+	db, err := simple.OpenDatabase(simple.GetSqlite(filepath.Join(t.TempDir(), "oto.db")))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if err := migrateModels(db); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	sh := models.NewExecutable("sh", "1", "/bin/sh", "shell")
+	param := models.NewParameter("-c", "script", sh, false, true, models.String, nil, nil)
+	cmd := models.NewCommand("shell", "run a script", sh, nil)
+	job := models.NewJob("script", cmd, []*models.FlagValue{models.NewFlagValue(param, script)})
+	job.Retry = retry
+	if err := db.Create(job).Error; err != nil {
+		t.Fatalf("failed to save job: %v", err)
+	}
+
+	return NewLocalRunner(db, models.NewGormRepositories(db), time.Second, 1, models.ConcurrencyLimit{}, time.Hour)
+}
+
+func TestLocalRunnerRetriesAndRecords(t *testing.T) {
+	runner := newShellJob(t, "echo attempt; exit 3", models.RetryPolicy{MaximumAttempts: 2, InitialInterval: time.Millisecond})
+	defer runner.Close()
+	ctx := context.Background()
+
+	run, err := runner.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	output, err := runner.Wait(ctx, run.ID, 10*time.Second)
+	if err == nil {
+		t.Fatalf("expected the run to fail")
+	}
+	if output.Stdout != "attempt\n" {
+		t.Fatalf("unexpected output %q", output.Stdout)
+	}
+
+	run, err = runner.repos.Runs.Get(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch run: %v", err)
+	}
+	if run.Status != models.RunFailed || len(run.Attempts) != 2 {
+		t.Fatalf("expected a failed run with 2 attempts, got %s with %d", run.Status, len(run.Attempts))
+	}
+	if run.LastAttempt().ExitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", run.LastAttempt().ExitCode)
+	}
+	if hash, _ := simple.HashFile("sha256", "/bin/sh"); run.LastAttempt().BinarySHA256 != hash {
+		t.Fatalf("expected the hash of /bin/sh to be recorded, got %q", run.LastAttempt().BinarySHA256)
+	}
+}
+
+func TestLocalRunnerCancel(t *testing.T) {
+	runner := newShellJob(t, "echo started; sleep 30", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	run, err := runner.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	if _, err := runner.Wait(ctx, run.ID, 200*time.Millisecond); err != ErrWaitTimeout {
+		t.Fatalf("expected ErrWaitTimeout, got %v", err)
+	}
+
+	if err := runner.Cancel(ctx, run.ID); err != nil {
+		t.Fatalf("failed to cancel run: %v", err)
+	}
+	if _, err := runner.Wait(ctx, run.ID, 10*time.Second); err == nil {
+		t.Fatalf("expected the cancelled run to return an error")
+	}
+
+	run, err = runner.repos.Runs.Get(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch run: %v", err)
+	}
+	if run.Status != models.RunCancelled || run.LastAttempt().Stdout != "started\n" {
+		t.Fatalf("expected a cancelled run keeping its output, got %s with %q", run.Status, run.LastAttempt().Stdout)
+	}
+}
+
+func TestLocalRunnerCancelDuringBackoff(t *testing.T) {
+	runner := newShellJob(t, "echo attempt; exit 3", models.RetryPolicy{MaximumAttempts: 3, InitialInterval: time.Hour})
+	defer runner.Close()
+	ctx := context.Background()
+
+	run, err := runner.Start(ctx, "script", RunOptions{})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	// The run waits for its second attempt once the first one is recorded
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		run, err = runner.repos.Runs.Get(ctx, run.ID)
+		if err == nil && run.LastAttempt() != nil && run.LastAttempt().Status == models.RunFailed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a failed first attempt, got %+v: %v", run, err)
+		}
+	}
+
+	if err := runner.Cancel(ctx, run.ID); err != nil {
+		t.Fatalf("failed to cancel run: %v", err)
+	}
+	if _, err := runner.Wait(ctx, run.ID, 10*time.Second); err == nil {
+		t.Fatalf("expected the cancelled run to return an error")
+	}
+	run, err = runner.repos.Runs.Get(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch run: %v", err)
+	}
+	if run.Status != models.RunCancelled || len(run.Attempts) != 1 {
+		t.Fatalf("expected a cancelled run with 1 attempt, got %s with %d", run.Status, len(run.Attempts))
+	}
+}
+
+func TestLocalRunnerIdempotencyKey(t *testing.T) {
+	runner := newShellJob(t, "echo done", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	first, err := runner.Start(ctx, "script", RunOptions{IdempotencyKey: "deploy-42"})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	again, err := runner.Start(ctx, "script", RunOptions{IdempotencyKey: "deploy-42"})
+	if err != nil {
+		t.Fatalf("failed to repeat the trigger: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Fatalf("expected run %d for a repeated key, got %d", first.ID, again.ID)
+	}
+
+	other, err := runner.Start(ctx, "script", RunOptions{IdempotencyKey: "deploy-43"})
+	if err != nil {
+		t.Fatalf("failed to start run: %v", err)
+	}
+	if other.ID == first.ID {
+		t.Fatalf("expected a new run for another key")
+	}
+
+	// Once the window is over, the key starts a new run
+	runner.window = 0
+	later, err := runner.Start(ctx, "script", RunOptions{IdempotencyKey: "deploy-42"})
+	if err != nil {
+		t.Fatalf("failed to start run after the window: %v", err)
+	}
+	if later.ID == first.ID || later.IdempotencyKey != "deploy-42" {
+		t.Fatalf("expected a new run holding the key, got run %d with key %q", later.ID, later.IdempotencyKey)
+	}
+}
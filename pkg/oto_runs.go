@@ -0,0 +1,216 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+
+	"github.com/Bl4omArchie/simple"
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// ErrWaitTimeout is returned by WaitRun when the run isn't done before the timeout.
+var ErrWaitTimeout = errors.New("run still in progress")
+
+// ErrIdempotencyConflict is returned when an idempotency key is given again to start another job.
+var ErrIdempotencyConflict = errors.New("idempotency key already used by a run of another job")
+
+// RunState is the polling view of a run.
+type RunState struct {
+	RunID      uint             `json:"run_id"`
+	WorkflowID string           `json:"workflow_id"`
+	Job        string           `json:"job"`
+	Status     models.RunStatus `json:"status"`
+	Priority   int              `json:"priority"`
+	// QueuePosition is 1 for the next run to be dispatched, 0 once the run is dispatched.
+	// While WaitingFor is set, the run is queued behind the concurrency limit of this scope.
+	QueuePosition int        `json:"queue_position,omitempty"`
+	WaitingFor    string     `json:"waiting_for,omitempty"`
+	Attempts      int        `json:"attempts"`
+	Error         string     `json:"error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+}
+
+func NewRunState(run *models.Run) *RunState {
+	state := &RunState{
+		RunID:      run.ID,
+		WorkflowID: run.WorkflowID,
+		Status:     run.Status,
+		Priority:   run.Priority,
+		WaitingFor: run.WaitingFor,
+		Attempts:   len(run.Attempts),
+		Error:      run.Error,
+		CreatedAt:  run.CreatedAt,
+		FinishedAt: run.FinishedAt,
+	}
+	if run.Job != nil {
+		state.Job = run.Job.Name
+	}
+	return state
+}
+
+// === Run recording, shared by every runner ===
+
+// createJobRun saves a pending run for the job and returns the input of WorkflowRunJob,
+// with the task queue of a worker able to run it. No run is saved when there is no such worker.
+func createJobRun(ctx context.Context, db *gorm.DB, runs models.RunRepository, job *models.Job, priority int, key string, workflowID string, workflowRunID *int, defaultQueue string) (*RunJobInput, error) {
+	taskQueue, err := selectTaskQueue(ctx, db, job, defaultQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := saveRun(ctx, runs, job, priority, key, workflowID, workflowRunID, taskQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunJobInput{
+		RunID:       int(run.ID),
+		JobName:     job.Name,
+		TaskQueue:   taskQueue,
+		RetryPolicy: job.RetryPolicy(),
+		Priority:    priority,
+	}, nil
+}
+
+// saveRun saves a pending run for the job, in the given queue.
+// With an idempotency key, models.ErrIdempotencyKeyUsed is returned when another run holds it.
+func saveRun(ctx context.Context, runs models.RunRepository, job *models.Job, priority int, key string, workflowID string, workflowRunID *int, queue string) (*models.Run, error) {
+	run := models.NewRun(job)
+	run.Priority = priority
+	if key != "" {
+		run.IdempotencyKey = key
+		run.DedupKey = &key
+	}
+	run.WorkflowID = workflowID
+	run.WorkflowRunID = workflowRunID
+	run.Queue = queue
+	if err := runs.Create(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to save run: %w", err)
+	}
+	return run, nil
+}
+
+// startOnce calls start unless a run of the job already holds the idempotency key within the window, that run is returned instead.
+// A key held by a run older than the window is released, so that start can take it.
+func startOnce(ctx context.Context, runs models.RunRepository, job *models.Job, key string, window time.Duration, start func() (*models.Run, error)) (*models.Run, error) {
+	if key == "" {
+		return start()
+	}
+
+	run, err := heldRun(ctx, runs, job, key, window)
+	if err != nil || run != nil {
+		return run, err
+	}
+
+	run, err = start()
+	if errors.Is(err, models.ErrIdempotencyKeyUsed) {
+		// Another trigger with the same key saved its run first
+		run, err = heldRun(ctx, runs, job, key, window)
+		if err == nil && run == nil {
+			err = fmt.Errorf("failed to start run of job %s: %w", job.Name, models.ErrIdempotencyKeyUsed)
+		}
+	}
+	return run, err
+}
+
+// heldRun returns the run holding the idempotency key, nil when the key is free once the expired one is released.
+func heldRun(ctx context.Context, runs models.RunRepository, job *models.Job, key string, window time.Duration) (*models.Run, error) {
+	if err := runs.ReleaseIdempotencyKey(ctx, key, time.Now().Add(-window)); err != nil {
+		return nil, fmt.Errorf("failed to release idempotency key %s: %w", key, err)
+	}
+
+	run, err := runs.GetByIdempotencyKey(ctx, key)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if run.JobID != int(job.ID) {
+		return nil, fmt.Errorf("%w : %s", ErrIdempotencyConflict, key)
+	}
+	return runs.Get(ctx, run.ID)
+}
+
+// startAttempt records a new attempt of the run, which becomes running, with the process it starts for the job
+// and the hash of the binary it runs.
+func startAttempt(ctx context.Context, db *gorm.DB, runID int, number int32, job *models.Job) (*models.RunAttempt, error) {
+	attempt := models.NewRunAttempt(runID, number)
+	program, args := commandLine(job)
+	attempt.Argv, attempt.WorkingDir, attempt.Env = append([]string{program}, args...), job.WorkingDir, job.Env
+	attempt.BinarySHA256, _ = simple.HashFile("sha256", job.Command.Executable.Path)
+	if err := db.WithContext(ctx).Create(attempt).Error; err != nil {
+		return nil, fmt.Errorf("failed to save run attempt: %w", err)
+	}
+	if err := db.WithContext(ctx).Model(&models.Run{}).Where("id = ?", runID).Update("status", models.RunRunning).Error; err != nil {
+		return nil, fmt.Errorf("failed to update run: %w", err)
+	}
+	return attempt, nil
+}
+
+// finishAttempt saves the output of an attempt, even a partial one when the run has been cancelled.
+func finishAttempt(ctx context.Context, db *gorm.DB, attempt *models.RunAttempt, output *JobOutput, err error) {
+	now := time.Now()
+	attempt.FinishedAt = &now
+	attempt.Stdout = output.Stdout
+	attempt.Stderr = output.Stderr
+	attempt.Status = models.RunSucceeded
+
+	switch {
+	case ctx.Err() != nil:
+		attempt.Status = models.RunCancelled
+		attempt.Error = ctx.Err().Error()
+	case err != nil:
+		attempt.Status = models.RunFailed
+		attempt.Error = err.Error()
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			attempt.ExitCode = exitErr.ExitCode()
+		}
+	}
+
+	// The context may be cancelled, the attempt must be saved anyway
+	if err := db.WithContext(context.WithoutCancel(ctx)).Save(attempt).Error; err != nil {
+		log.Printf("failed to save attempt %d of run %d: %v", attempt.Attempt, attempt.RunID, err)
+	}
+}
+
+// finishRun stores the final status of a run once every attempt is done, and frees its concurrency slots.
+func finishRun(ctx context.Context, db *gorm.DB, runID int, status models.RunStatus, errMsg string) error {
+	now := time.Now()
+	err := db.WithContext(ctx).Model(&models.Run{}).Where("id = ?", runID).Updates(map[string]any{
+		"status":      status,
+		"error":       errMsg,
+		"finished_at": &now,
+		"waiting_for": "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	return releaseLeases(ctx, db, runID)
+}
+
+// runOutput returns the output of a finished run, taken from its last attempt.
+// The error of the run is returned when it didn't succeed.
+func runOutput(run *models.Run) (*JobOutput, error) {
+	output := &JobOutput{}
+	if last := run.LastAttempt(); last != nil {
+		output.Stdout = last.Stdout
+		output.Stderr = last.Stderr
+	}
+	if run.Status == models.RunSkipped {
+		return output, fmt.Errorf("run %d: %w", run.ID, ErrRunSkipped)
+	}
+	if run.Status != models.RunSucceeded {
+		return output, fmt.Errorf("run %d %s: %s", run.ID, run.Status, run.Error)
+	}
+	return output, nil
+}
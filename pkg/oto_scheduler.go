@@ -0,0 +1,125 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron"
+	"gorm.io/gorm"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// schedulerInterval is how often the scheduler looks for due schedules.
+const schedulerInterval = 5 * time.Second
+
+// Scheduler starts the runs of the due schedules through the runner of the instance.
+// Schedules are claimed in the database, so several schedulers sharing it never start the same run twice.
+// A schedule missed while no scheduler was running is triggered once, then follows its spec again.
+type Scheduler struct {
+	db        *gorm.DB
+	schedules models.ScheduleRepository
+	runner    Runner
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewScheduler(db *gorm.DB, schedules models.ScheduleRepository, runner Runner) *Scheduler {
+	return &Scheduler{
+		db:        db,
+		schedules: schedules,
+		runner:    runner,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// ParseSchedule returns the next time the spec is due after the given time.
+func ParseSchedule(spec string, after time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(after), nil
+}
+
+// Run triggers the due schedules until Stop is called.
+func (s *Scheduler) Run() {
+	defer close(s.done)
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(context.Background(), time.Now())
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop waits for the scheduler to finish its current tick.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	schedules, err := s.schedules.Due(ctx, now)
+	if err != nil {
+		log.Printf("failed to fetch due schedules: %v", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		s.trigger(ctx, &schedule, now)
+	}
+}
+
+// trigger claims the schedule by moving its next run forward, then starts a run of its job.
+func (s *Scheduler) trigger(ctx context.Context, schedule *models.Schedule, now time.Time) {
+	next, err := ParseSchedule(schedule.Spec, now)
+	if err != nil {
+		s.db.WithContext(ctx).Model(schedule).Updates(map[string]any{"enabled": false, "last_error": err.Error()})
+		return
+	}
+
+	res := s.db.WithContext(ctx).Model(&models.Schedule{}).
+		Where("id = ? AND next_run_at = ?", schedule.ID, schedule.NextRunAt).
+		Updates(map[string]any{"next_run_at": next, "last_run_at": now})
+	if res.Error != nil {
+		log.Printf("failed to claim schedule %s: %v", schedule.Name, res.Error)
+		return
+	}
+	// Triggered by another scheduler
+	if res.RowsAffected == 0 {
+		return
+	}
+
+	updates := map[string]any{"last_error": ""}
+	if schedule.Job == nil {
+		// The job has been deleted, the schedule stays disabled until it is fixed
+		updates["last_error"], updates["enabled"] = fmt.Sprintf("job %d not found", schedule.JobID), false
+		if err := s.db.WithContext(ctx).Model(&models.Schedule{}).Where("id = ?", schedule.ID).Updates(updates).Error; err != nil {
+			log.Printf("failed to update schedule %s: %v", schedule.Name, err)
+		}
+		return
+	}
+
+	// The key is the same for every scheduler catching this occurrence
+	key := fmt.Sprintf("schedule-%s-%d", schedule.Name, schedule.NextRunAt.Unix())
+	run, err := s.runner.Start(ctx, schedule.Job.Name, RunOptions{Priority: schedule.Priority, IdempotencyKey: key})
+	if err != nil {
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_run_id"] = int(run.ID)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Schedule{}).Where("id = ?", schedule.ID).Updates(updates).Error; err != nil {
+		log.Printf("failed to update schedule %s: %v", schedule.Name, err)
+	}
+}
@@ -0,0 +1,83 @@
+package oto
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestSchedulerTriggersOnce(t *testing.T) {
+	runner := newShellJob(t, "echo scheduled", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	due := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := runner.repos.Schedules.Create(ctx, models.NewSchedule("every-hour", job, "@hourly", due)); err != nil {
+		t.Fatalf("failed to save schedule: %v", err)
+	}
+
+	// Two schedulers sharing the database must start a single run
+	now := time.Now()
+	NewScheduler(runner.db, runner.repos.Schedules, runner).tick(ctx, now)
+	NewScheduler(runner.db, runner.repos.Schedules, runner).tick(ctx, now)
+
+	schedule, err := runner.repos.Schedules.GetByName(ctx, "every-hour")
+	if err != nil {
+		t.Fatalf("failed to fetch schedule: %v", err)
+	}
+	if schedule.LastRunID == nil || !schedule.NextRunAt.After(now) {
+		t.Fatalf("expected the schedule to be triggered and moved forward, got %+v", schedule)
+	}
+
+	runs, err := runner.repos.Runs.ListByJob(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch runs: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("expected 1 run, got %d", len(runs))
+	}
+
+	output, err := runner.Wait(ctx, uint(*schedule.LastRunID), 10*time.Second)
+	if err != nil || output.Stdout != "scheduled\n" {
+		t.Fatalf("unexpected result of the scheduled run: %v %+v", err, output)
+	}
+}
+
+func TestSchedulerDisablesMissingJob(t *testing.T) {
+	runner := newShellJob(t, "true", models.RetryPolicy{})
+	defer runner.Close()
+	ctx := context.Background()
+
+	job, err := runner.repos.Jobs.GetByName(ctx, "script")
+	if err != nil {
+		t.Fatalf("failed to fetch job: %v", err)
+	}
+	due := time.Now().Add(-time.Minute).Truncate(time.Second)
+	if err := runner.repos.Schedules.Create(ctx, models.NewSchedule("every-hour", job, "@hourly", due)); err != nil {
+		t.Fatalf("failed to save schedule: %v", err)
+	}
+	if err := runner.repos.Jobs.Delete(ctx, job.ID); !errors.Is(err, models.ErrScheduled) {
+		t.Fatalf("expected a scheduled job to be kept, got %v", err)
+	}
+
+	// A job removed behind the repository leaves the schedule without job
+	if err := runner.db.Delete(&models.Job{}, job.ID).Error; err != nil {
+		t.Fatalf("failed to delete job: %v", err)
+	}
+	NewScheduler(runner.db, runner.repos.Schedules, runner).tick(ctx, time.Now())
+
+	schedule, err := runner.repos.Schedules.GetByName(ctx, "every-hour")
+	if err != nil {
+		t.Fatalf("failed to fetch schedule: %v", err)
+	}
+	if schedule.Enabled || schedule.LastError == "" {
+		t.Fatalf("expected the schedule to be disabled with an error, got %+v", schedule)
+	}
+}
@@ -0,0 +1,165 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/Bl4omArchie/fme"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// schemaRegistry holds the FME schema of each executable, keyed by tag, built from its parameters in the database.
+// A published schema is never modified : a change of parameters builds a new schema, which replaces
+// the previous one once the parameter is saved. Readers can use a schema without locking.
+type schemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]*fme.Schema
+
+	// changes serializes the changes of parameters, so that each one is checked against the last schema
+	changes sync.Mutex
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: make(map[string]*fme.Schema)}
+}
+
+// load rebuilds the schema of every executable. An executable whose parameters don't form a valid schema
+// is logged and left out, its schema is built again on its next use.
+func (r *schemaRegistry) load(ctx context.Context, repos *models.Repositories) error {
+	execs, err := repos.Executables.List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load schemas: %w", err)
+	}
+
+	for n := range execs {
+		if _, err := r.rebuild(ctx, repos, &execs[n]); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+	return nil
+}
+
+// get returns the schema of the executable, built from the database the first time. It mustn't be modified.
+func (r *schemaRegistry) get(ctx context.Context, repos *models.Repositories, exec *models.Executable) (*fme.Schema, error) {
+	r.mu.RLock()
+	s, ok := r.schemas[exec.Tag]
+	r.mu.RUnlock()
+	if ok {
+		return s, nil
+	}
+	return r.rebuild(ctx, repos, exec)
+}
+
+// rebuild builds the schema of the executable from its parameters and publishes it.
+func (r *schemaRegistry) rebuild(ctx context.Context, repos *models.Repositories, exec *models.Executable) (*fme.Schema, error) {
+	params, err := repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := buildSchema(params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema for %s: %w", exec.Tag, err)
+	}
+	r.publish(exec.Tag, s)
+	return s, nil
+}
+
+// addParameter checks the schema of the executable with the new parameter, then saves the parameter with save.
+// The new schema is published once the parameter is saved, neither changes when one of them fails.
+func (r *schemaRegistry) addParameter(ctx context.Context, repos *models.Repositories, exec *models.Executable, param *models.Parameter, save func() error) error {
+	r.changes.Lock()
+	defer r.changes.Unlock()
+
+	params, err := repos.Parameters.Find(ctx, models.ParameterFilter{ExecutableID: exec.ID})
+	if err != nil {
+		return err
+	}
+
+	s, err := buildSchema(append(params, *param))
+	if err != nil {
+		return err
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	r.publish(exec.Tag, s)
+	return nil
+}
+
+func (r *schemaRegistry) publish(tag string, s *fme.Schema) {
+	r.mu.Lock()
+	r.schemas[tag] = s
+	r.mu.Unlock()
+}
+
+// drop removes the schema of a deleted executable.
+func (r *schemaRegistry) drop(tag string) {
+	r.mu.Lock()
+	delete(r.schemas, tag)
+	r.mu.Unlock()
+}
+
+// buildSchema declares the relations of the parameters in a new schema.
+func buildSchema(params []models.Parameter) (*fme.Schema, error) {
+	s := fme.NewSchema()
+	for _, param := range params {
+		for _, dependency := range param.Require {
+			if _, err := s.Require(param.Flag, dependency.Flag); err != nil {
+				return nil, fmt.Errorf("incorrect `requirement` dependency %s -> %s : %w", param.Flag, dependency.Flag, err)
+			}
+		}
+		for _, interfer := range param.Interfer {
+			if _, err := s.Interfer(param.Flag, interfer.Flag); err != nil {
+				return nil, fmt.Errorf("incorrect `interference` dependency %s -> %s : %w", param.Flag, interfer.Flag, err)
+			}
+		}
+	}
+
+	if err := s.ValidateSchema(); err != nil {
+		return nil, err
+	}
+	if err := validateRules(s, params); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// validateRules checks the groups and the value rules of the parameters : the members of a one-of group
+// can't require each other, a group has a single kind, and a value rule requires another parameter of the executable.
+func validateRules(s *fme.Schema, params []models.Parameter) error {
+	g := newFlagGraph(s)
+	groups := paramGroups(params)
+	for n, group := range groups {
+		for _, other := range groups[n+1:] {
+			if other.name == group.name {
+				return fmt.Errorf("group %s is declared both as %s and %s", group.name, group.kind, other.kind)
+			}
+		}
+		if group.kind != models.GroupOneOf {
+			continue
+		}
+		for _, a := range group.members {
+			closure, _ := g.closure([]string{a})
+			for _, b := range group.members {
+				if a != b && slices.Contains(closure, b) {
+					return fmt.Errorf("%s requires %s, they can't be in the one-of group %s", a, b, group.name)
+				}
+			}
+		}
+	}
+
+	flags := paramFlags(params)
+	for _, param := range params {
+		for _, rule := range param.ValueRules {
+			if rule.Flag == param.Flag || !slices.Contains(flags, rule.Flag) {
+				return fmt.Errorf("value rule of %s requires %s, which isn't another parameter of the executable", param.Flag, rule.Flag)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestSchemaRegistry(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	if err := instance.AddExecutable("nmap", "7.98", "/usr/bin/nmap", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-sL", "list scan", false, false, models.String, nil, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-sT", "connect scan", false, false, models.String, []string{"-sL"}, nil); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// -sT requires -sL, they can't interfere
+	if err := instance.AddParameter(ctx, "nmap - 7.98", "-sK", "invalid", false, false, models.String, []string{"-sT"}, []string{"-sL"}); err == nil {
+		t.Fatalf("expected the contradicting parameter to be refused")
+	}
+	nmap, _ := instance.Repos.Executables.GetByTag(ctx, "nmap - 7.98")
+	if _, err := instance.Repos.Parameters.GetByFlag(ctx, nmap.ID, "-sK"); err == nil {
+		t.Fatalf("expected the refused parameter not to be saved")
+	}
+
+	// Members of a one-of group can't require each other
+	if err := instance.AddExecutable("masscan", "1.3", "/usr/bin/masscan", "scanning tool"); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameterRaw(ctx, &models.ParameterRaw{ExecutableTag: "masscan - 1.3", Flag: "--ping", Description: "ping scan", ValueType: models.None, OneOf: []string{"scan"}}); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := instance.AddParameterRaw(ctx, &models.ParameterRaw{ExecutableTag: "masscan - 1.3", Flag: "--banners", Description: "grab banners", ValueType: models.None, RequireIDs: []string{"--ping"}, OneOf: []string{"scan"}}); err == nil {
+		t.Fatalf("expected a one-of member requiring another to be refused")
+	}
+
+	// Parameters added while commands are checked
+	var wg sync.WaitGroup
+	for n := range 8 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			flag := fmt.Sprintf("-p%d", n)
+			if err := instance.AddParameter(ctx, "nmap - 7.98", flag, "port", false, true, models.Port, []string{"-sT"}, nil); err != nil {
+				t.Errorf("%v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := instance.ValidateFlags(ctx, "nmap - 7.98", []string{"-sT"}); err != nil {
+				t.Errorf("%v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// A new registry is rebuilt from the database
+	instance.schemas = newSchemaRegistry()
+	if err := instance.schemas.load(ctx, instance.Repos); err != nil {
+		t.Fatalf("%v", err)
+	}
+	d, err := instance.ValidateFlags(ctx, "nmap - 7.98", []string{"-p3"})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !d.Valid || len(d.Missing) != 2 {
+		t.Fatalf("expected -p3 to require -sT and -sL, got %+v", d)
+	}
+}
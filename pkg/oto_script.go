@@ -0,0 +1,113 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Bl4omArchie/simple"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// scriptProcess is the process a script starts : its resolved command line, sudo included, its directory, its environment
+// and the hash of the binary of the executable.
+type scriptProcess struct {
+	argv   []string
+	dir    string
+	env    map[string]string
+	sha256 string
+}
+
+// JobScript returns a POSIX shell script running the job by hand, outside of OTO, the way its runs start it.
+func (i *Instance) JobScript(ctx context.Context, jobName string) (string, error) {
+	job, err := i.Repos.Jobs.GetByName(ctx, jobName)
+	if err != nil {
+		return "", err
+	}
+	return shellScript(job, "none, the current configuration of the job", jobProcess(job))
+}
+
+// RunScript returns a POSIX shell script starting the process of the last attempt of a run again. A run which hasn't
+// started an attempt yet gets the current configuration of its job.
+func (i *Instance) RunScript(ctx context.Context, runID uint) (string, error) {
+	run, err := i.Repos.Runs.Get(ctx, runID)
+	if err != nil {
+		return "", err
+	}
+	job, err := i.Repos.Jobs.Get(ctx, uint(run.JobID))
+	if err != nil {
+		return "", err
+	}
+
+	attempt := run.LastAttempt()
+	if attempt == nil || len(attempt.Argv) == 0 {
+		return shellScript(job, fmt.Sprintf("%d, %s, no attempt recorded, the current configuration of the job", run.ID, run.Status), jobProcess(job))
+	}
+	hash := attempt.BinarySHA256 + ", recorded by the attempt"
+	if attempt.BinarySHA256 == "" {
+		hash = "not recorded by the attempt"
+	}
+	return shellScript(job, fmt.Sprintf("%d, attempt %d, %s", run.ID, attempt.Attempt, attempt.Status),
+		scriptProcess{attempt.Argv, attempt.WorkingDir, attempt.Env, hash})
+}
+
+// jobProcess returns the process the job starts now, with the hash of the binary on this host.
+func jobProcess(job *models.Job) scriptProcess {
+	program, args := commandLine(job)
+	hash, err := simple.HashFile("sha256", job.Command.Executable.Path)
+	if err != nil {
+		hash = fmt.Sprintf("unavailable on this host, %v", err)
+	}
+	return scriptProcess{append([]string{program}, args...), job.WorkingDir, job.Env, hash}
+}
+
+// shellScript writes the script of the process. Its header names the job, the executable with the hash of its binary,
+// and the run.
+func shellScript(job *models.Job, run string, p scriptProcess) (string, error) {
+	exec := job.Command.Executable
+
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	fmt.Fprintf(&script, "# Job %s of command %s, exported by oto\n", oneLine(job.Name), oneLine(job.Command.Name))
+	fmt.Fprintf(&script, "# Executable: %s, %s\n", oneLine(exec.Tag), oneLine(exec.Path))
+	fmt.Fprintf(&script, "# sha256: %s\n", oneLine(p.sha256))
+	fmt.Fprintf(&script, "# Run: %s\n", run)
+	script.WriteString("set -eu\n")
+
+	if p.dir != "" {
+		fmt.Fprintf(&script, "cd %s\n", shellQuote(p.dir))
+	}
+	for _, key := range slices.Sorted(maps.Keys(p.env)) {
+		if !validEnvName(key) {
+			return "", fmt.Errorf("job %s: %q isn't a valid environment variable name", job.Name, key)
+		}
+		fmt.Fprintf(&script, "export %s=%s\n", key, shellQuote(p.env[key]))
+	}
+
+	words := make([]string, len(p.argv))
+	for n, arg := range p.argv {
+		words[n] = shellQuote(arg)
+	}
+	fmt.Fprintf(&script, "exec %s\n", strings.Join(words, " "))
+	return script.String(), nil
+}
+
+// shellQuote returns the word as the shell reads it back : unchanged when it only holds safe characters,
+// single quoted otherwise.
+func shellQuote(word string) string {
+	safe := func(r rune) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-+=:,./@%", r)
+	}
+	if word != "" && !strings.ContainsFunc(word, func(r rune) bool { return !safe(r) }) {
+		return word
+	}
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
+
+// oneLine keeps a value of the header on its comment line.
+func oneLine(s string) string {
+	return strings.NewReplacer("\n", " ", "\r", " ").Replace(s)
+}
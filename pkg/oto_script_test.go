@@ -0,0 +1,58 @@
+package oto
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func TestJobScript(t *testing.T) {
+	instance := &Instance{Repos: models.NewMemoryRepositories(), schemas: newSchemaRegistry()}
+	ctx := context.Background()
+
+	message := `it's "quoted" $HOME`
+	b := &Bundle{Version: BundleVersion,
+		Executables: []BundleExecutable{{Name: "echo", Version: "9.1", Path: "/bin/echo",
+			Parameters: []models.ParameterRaw{{Flag: "--message", RequiresValue: true, ValueType: models.String}}}},
+		Commands: []BundleCommand{{Name: "say", Executable: "echo - 9.1", Flags: []string{"--message"}}},
+		Jobs: []BundleJob{{Name: "say-it", Command: "say", FlagValues: []JobFlagValue{{Flag: "--message", Value: message}},
+			WorkingDir: "/tmp", Env: map[string]string{"GREETING": "hello world"}}},
+	}
+	if _, err := instance.ImportBundle(ctx, b, false); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	script, err := instance.JobScript(ctx, "say-it")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, line := range []string{"#!/bin/sh\n", "# Executable: echo - 9.1, /bin/echo\n", "# sha256: ", "cd /tmp\n", "export GREETING='hello world'\n",
+		`exec /bin/echo --message 'it'\''s "quoted" $HOME'` + "\n"} {
+		if !strings.Contains(script, line) {
+			t.Fatalf("expected %q in\n%s", line, script)
+		}
+	}
+	if out, err := exec.Command("/bin/sh", "-c", script).Output(); err != nil || string(out) != "--message "+message+"\n" {
+		t.Fatalf("unexpected output %q of\n%s: %v", out, script, err)
+	}
+
+	// The script of a run starts the process of its last attempt, even when the job changed since,
+	// with the hash of the binary it ran
+	job, _ := instance.Repos.Jobs.GetByName(ctx, "say-it")
+	run := models.NewRun(job)
+	run.Status = models.RunFailed
+	run.Attempts = []models.RunAttempt{{Attempt: 1, Status: models.RunFailed, Argv: []string{"sudo", "/bin/echo", "--message", "old"}, BinarySHA256: "0ff1ce"}}
+	if err := instance.Repos.Runs.Create(ctx, run); err != nil {
+		t.Fatalf("%v", err)
+	}
+	script, err = instance.RunScript(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !strings.Contains(script, "# Run: 1, attempt 1, failed\n") || !strings.Contains(script, "# sha256: 0ff1ce, recorded by the attempt\n") || !strings.HasSuffix(script, "set -eu\nexec sudo /bin/echo --message old\n") {
+		t.Fatalf("unexpected script of the run\n%s", script)
+	}
+}
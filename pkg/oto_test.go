@@ -20,32 +20,27 @@ func TestFillDatabase(t *testing.T) {
 		t.Fatalf("%v", err)
 	}
 
-	s, err := instance.AddExecutableSchema(ctx, "nmap - 7.98")
+	err = instance.AddParameter(ctx, "nmap - 7.98", "-sL", "scan option for determine which host are online", false, false, models.String, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "-sL", "scan option for determine which host are online", false, false, models.String, []string{}, []string{}, s)
+	err = instance.AddParameter(ctx, "nmap - 7.98", "-sT", "scan type", false, false, models.String, []string{"-sL"}, []string{})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "-sT", "scan type", false, false, models.String, []string{"-sL"}, []string{}, s)
+	err = instance.AddParameter(ctx, "nmap - 7.98", "-sK", "scan with -sT", false, false, models.String, []string{}, []string{"-sT"})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "-sK", "scan with -sT", false, false, models.String, []string{}, []string{"-sT"}, s)
+	err = instance.AddParameter(ctx, "nmap - 7.98", "-T", "option depending on -sL", false, false, models.String, []string{"-sL", "-sT"}, []string{})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "-T", "option depending on -sL", false, false, models.String, []string{"-sL", "-sT"}, []string{}, s)
-	if err != nil {
-		t.Fatalf("%v", err)
-	}
-
-	err = instance.AddCommand(ctx, "nmap - 7.98", "-sL", "determine which hosts are online", []string{"-sT", "-T"}, s)
+	err = instance.AddCommand(ctx, "nmap - 7.98", "-sL", "determine which hosts are online", []string{"-sT", "-T"})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
@@ -59,27 +54,28 @@ func TestIncorrectSchema(t *testing.T) {
 
 	var ctx context.Context = context.Background()
 
-	s, err := instance.AddExecutableSchema(ctx, "nmap - 7.98")
+	err = instance.AddParameter(ctx, "nmap - 7.98", "c", "scan with -sT", false, false, models.String, []string{}, []string{})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "c", "scan with -sT", false, false, models.String, []string{}, []string{"a"}, s)
+	err = instance.AddParameter(ctx, "nmap - 7.98", "b", "scan type", false, false, models.String, []string{"c"}, []string{})
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "b", "scan type", false, false, models.String, []string{"c"}, []string{}, s)
-	if err != nil {
-		t.Fatalf("%v", err)
+	// a requires c through b, it can't interfere with it
+	err = instance.AddParameter(ctx, "nmap - 7.98", "a", "scan option for determine which host are online", false, false, models.String, []string{"b"}, []string{"c"})
+	if err == nil {
+		t.Fatalf("Schema validation didn't fail even though it should have")
 	}
 
-	err = instance.AddParameter(ctx, "nmap - 7.98", "a", "scan option for determine which host are online", false, false, models.String, []string{"b"}, []string{}, s)
+	// The refused parameter leaves the schema unchanged
+	s, err := instance.Schema(ctx, "nmap - 7.98")
 	if err != nil {
 		t.Fatalf("%v", err)
 	}
-
-	if err := s.ValidateSchema(); err == nil {
-		t.Fatalf("Schema validation didn't fail even though it should have")
+	if err := s.ValidateSchema(); err != nil {
+		t.Fatalf("%v", err)
 	}
 }
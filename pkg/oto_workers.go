@@ -0,0 +1,272 @@
+package oto
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+	"gorm.io/gorm/clause"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+// DefaultTaskQueue is used when the config doesn't set a task queue. A worker without a task queue in its options
+// polls its own queue, named after the queue of the manager and its ID, so runs are only routed to capable workers.
+const DefaultTaskQueue = "oto-tasks"
+
+type WorkerStatus string
+
+const (
+	WorkerRunning  WorkerStatus = "running"
+	WorkerStopping WorkerStatus = "stopping"
+	WorkerStopped  WorkerStatus = "stopped"
+	WorkerFailed   WorkerStatus = "failed"
+)
+
+// WorkerOptions configures a worker. Zero values fallback on the defaults of the manager and of Temporal.
+type WorkerOptions struct {
+	TaskQueue               string       `json:"task_queue"`
+	MaxConcurrentActivities int          `json:"max_concurrent_activities"`
+	MaxConcurrentWorkflows  int          `json:"max_concurrent_workflows"`
+	Capabilities            Capabilities `json:"capabilities"`
+}
+
+type WorkerItem struct {
+	WorkerID    string
+	Options     WorkerOptions
+	Worker      worker.Worker
+	OutputError chan (error)
+
+	record     *models.Worker
+	unverified map[string]string
+	status     WorkerStatus
+	lastError  error
+	startedAt  time.Time
+	stop       chan any
+	done       chan struct{}
+}
+
+// WorkerInfo is the status of a worker, as listed by the manager.
+type WorkerInfo struct {
+	WorkerID  string        `json:"worker_id"`
+	Options   WorkerOptions `json:"options"`
+	Status    WorkerStatus  `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+
+	// Capabilities verified on the host, and the ones that couldn't be with the reason
+	VerifiedPaths map[string]string `json:"verified_paths"`
+	Root          bool              `json:"root"`
+	Unverified    map[string]string `json:"unverified,omitempty"`
+}
+
+// WorkerManager starts, stops and lists the Temporal workers of an instance.
+// Each worker polls its own task queue, and every workflow and activity of OTO is registered on it.
+type WorkerManager struct {
+	client      client.Client
+	activities  *Activities
+	taskQueue   string
+	stopTimeout time.Duration
+
+	mu      sync.Mutex
+	workers map[string]*WorkerItem
+}
+
+func NewWorkerManager(c client.Client, acts *Activities, taskQueue string, stopTimeout time.Duration) *WorkerManager {
+	if taskQueue == "" {
+		taskQueue = DefaultTaskQueue
+	}
+	return &WorkerManager{
+		client:      c,
+		activities:  acts,
+		taskQueue:   taskQueue,
+		stopTimeout: stopTimeout,
+		workers:     make(map[string]*WorkerItem),
+	}
+}
+
+// Start creates a new worker and runs it concurrently. A stopped or failed worker can be started again with the same ID.
+// Each worker polls its own task queue : a queue shared with another worker is refused, since a run routed
+// to the capabilities of one worker could be picked by the other.
+func (m *WorkerManager) Start(workerID string, opts WorkerOptions) error {
+	if opts.TaskQueue == "" {
+		opts.TaskQueue = m.workerQueue(workerID)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if w, ok := m.workers[workerID]; ok && (w.status == WorkerRunning || w.status == WorkerStopping) {
+		return fmt.Errorf("worker %s already exists", workerID)
+	}
+	for _, w := range m.workers {
+		if w.WorkerID != workerID && w.Options.TaskQueue == opts.TaskQueue && (w.status == WorkerRunning || w.status == WorkerStopping) {
+			return fmt.Errorf("task queue %s is already polled by worker %s", opts.TaskQueue, w.WorkerID)
+		}
+	}
+
+	w := &WorkerItem{
+		WorkerID: workerID,
+		Options:  opts,
+		Worker: worker.New(m.client, opts.TaskQueue, worker.Options{
+			Identity:                               workerID,
+			MaxConcurrentActivityExecutionSize:     opts.MaxConcurrentActivities,
+			MaxConcurrentWorkflowTaskExecutionSize: opts.MaxConcurrentWorkflows,
+			WorkerStopTimeout:                      m.stopTimeout,
+		}),
+		OutputError: make(chan error, 1),
+		status:      WorkerRunning,
+		startedAt:   time.Now(),
+		stop:        make(chan any),
+		done:        make(chan struct{}),
+	}
+
+	w.Worker.RegisterWorkflow(WorkflowRunJob)
+	w.Worker.RegisterWorkflow(WorkflowRunWorkflow)
+	w.Worker.RegisterActivity(m.activities)
+
+	// Advertise what the host is able to run, so jobs are routed to this worker's task queue
+	w.record, w.unverified = verifyCapabilities(context.Background(), m.activities.Repos.Executables, opts.Capabilities)
+	w.record.WorkerID = workerID
+	w.record.TaskQueue = opts.TaskQueue
+	if err := m.advertise(w, WorkerRunning, nil); err != nil {
+		return fmt.Errorf("failed to register worker %s: %w", workerID, err)
+	}
+
+	go func() {
+		// Run returns once the stop channel is closed, after in-going activities are done
+		if err := w.Worker.Run(w.stop); err != nil {
+			w.OutputError <- err
+		}
+		close(w.OutputError)
+	}()
+	go m.watch(w)
+	go m.heartbeat(w)
+
+	m.workers[workerID] = w
+	return nil
+}
+
+// workerQueue returns the task queue of a worker, derived from its ID.
+func (m *WorkerManager) workerQueue(workerID string) string {
+	return m.taskQueue + "-" + workerID
+}
+
+// watch records the error reported by a worker on its OutputError channel.
+func (m *WorkerManager) watch(w *WorkerItem) {
+	defer close(w.done)
+	err, failed := <-w.OutputError
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if failed {
+		w.status = WorkerFailed
+		w.lastError = err
+	} else {
+		w.status = WorkerStopped
+	}
+
+	if err := m.advertise(w, w.status, w.lastError); err != nil {
+		log.Printf("failed to update worker %s: %v", w.WorkerID, err)
+	}
+}
+
+// heartbeat keeps the advertisement of the worker alive until it stops.
+func (m *WorkerManager) heartbeat(w *WorkerItem) {
+	ticker := time.NewTicker(WorkerHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			err := m.activities.DB.Model(&models.Worker{}).Where("worker_id = ?", w.WorkerID).Update("heartbeat_at", time.Now()).Error
+			if err != nil {
+				log.Printf("failed to send heartbeat of worker %s: %v", w.WorkerID, err)
+			}
+		}
+	}
+}
+
+// advertise saves the worker and its capabilities in the database.
+func (m *WorkerManager) advertise(w *WorkerItem, status WorkerStatus, workerErr error) error {
+	w.record.Status = string(status)
+	w.record.HeartbeatAt = time.Now()
+	w.record.Error = ""
+	if workerErr != nil {
+		w.record.Error = workerErr.Error()
+	}
+
+	return m.activities.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "worker_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "task_queue", "host", "executables", "verified_paths", "root", "labels", "status", "error", "heartbeat_at"}),
+	}).Create(w.record).Error
+}
+
+// Stop drains the worker : it stops polling and waits for in-going tasks, at most the stop timeout.
+func (m *WorkerManager) Stop(workerID string) error {
+	m.mu.Lock()
+	w, ok := m.workers[workerID]
+	if !ok {
+		m.mu.Unlock()
+		return fmt.Errorf("couldn't find worker %s. First create the worker", workerID)
+	}
+	if w.status != WorkerRunning {
+		m.mu.Unlock()
+		return fmt.Errorf("worker %s is already %s", workerID, w.status)
+	}
+	w.status = WorkerStopping
+	close(w.stop)
+	m.mu.Unlock()
+
+	<-w.done
+	return nil
+}
+
+// List returns the status of every worker, sorted by ID.
+func (m *WorkerManager) List() []WorkerInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]WorkerInfo, 0, len(m.workers))
+	for _, w := range m.workers {
+		info := WorkerInfo{
+			WorkerID:  w.WorkerID,
+			Options:   w.Options,
+			Status:    w.status,
+			StartedAt: w.startedAt,
+		}
+		if w.lastError != nil {
+			info.Error = w.lastError.Error()
+		}
+		if w.record != nil {
+			info.VerifiedPaths = w.record.VerifiedPaths
+			info.Root = w.record.Root
+			info.Unverified = w.unverified
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(a, b int) bool { return infos[a].WorkerID < infos[b].WorkerID })
+	return infos
+}
+
+// Shutdown drains every running worker concurrently.
+func (m *WorkerManager) Shutdown() {
+	var wg sync.WaitGroup
+	for _, info := range m.List() {
+		if info.Status != WorkerRunning {
+			continue
+		}
+		wg.Add(1)
+		go func(workerID string) {
+			defer wg.Done()
+			_ = m.Stop(workerID)
+		}(info.WorkerID)
+	}
+	wg.Wait()
+}
@@ -2,23 +2,171 @@ package oto
 
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
+
+	"github.com/Bl4omArchie/oto/models"
 )
 
-func WorkflowRunJob(ctx workflow.Context, jobName string) (*JobOutput, error) {
+// acts is only used to reference the activities by their method, the worker registers the real ones.
+var acts *Activities
+
+// maxLeaseWait is how long a run may stay queued behind a concurrency limit before failing.
+const maxLeaseWait = 24 * time.Hour
+
+// maxAttemptDuration only bounds an attempt of a run, a dead worker is detected by the heartbeat timeout.
+// It is large since the local runner doesn't limit the attempts and scans may take hours.
+const maxAttemptDuration = 24 * time.Hour
+
+func WorkflowRunJob(ctx workflow.Context, input RunJobInput) (*JobOutput, error) {
 	ao := workflow.ActivityOptions{
-		StartToCloseTimeout: time.Minute,
+		StartToCloseTimeout: maxAttemptDuration,
+		HeartbeatTimeout:    3 * heartbeatInterval,
+		WaitForCancellation: true,
+		RetryPolicy:         NewTemporalRetryPolicy(input.RetryPolicy),
 	}
-	ctx = workflow.WithActivityOptions(ctx, ao)
+	runCtx := workflow.WithActivityOptions(ctx, ao)
+
+	// Wait for a slot in every concurrency limit of the job, the run stays pending meanwhile
+	leaseCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: maxLeaseWait,
+		HeartbeatTimeout:    3 * heartbeatInterval,
+		WaitForCancellation: true,
+	})
+	err := workflow.ExecuteActivity(leaseCtx, acts.AcquireLeases, input).Get(leaseCtx, nil)
 
 	var output JobOutput
-	err := workflow.ExecuteActivity(ctx, "RunCommand", jobName).Get(ctx, &output)
+	if err == nil {
+		err = workflow.ExecuteActivity(runCtx, acts.RunJob, input).Get(runCtx, &output)
+	}
+
+	// Record the final status of the run, whatever the result of the attempts was
+	status, errMsg := models.RunSucceeded, ""
+	var appErr *temporal.ApplicationError
+	switch {
+	case temporal.IsCanceledError(err):
+		status, errMsg = models.RunCancelled, "run cancelled"
+	case errors.As(err, &appErr) && appErr.Type() == ErrorRunSkipped:
+		status, errMsg = models.RunSkipped, appErr.Message()
+	case err != nil:
+		status, errMsg = models.RunFailed, err.Error()
+	}
+
+	// A disconnected context lets the status be recorded even when the workflow is cancelled
+	finishCtx, _ := workflow.NewDisconnectedContext(ctx)
+	finishCtx = workflow.WithActivityOptions(finishCtx, workflow.ActivityOptions{StartToCloseTimeout: 10 * time.Second})
+	if ferr := workflow.ExecuteActivity(finishCtx, acts.FinishRun, input.RunID, status, errMsg).Get(finishCtx, nil); ferr != nil {
+		workflow.GetLogger(ctx).Error("failed to record the end of the run", "error", ferr)
+	}
+
 	if err != nil {
 		return nil, err
 	}
-
 	return &output, nil
 }
 
+// ApprovalSignal is the signal sent to a workflow run waiting on an approval node.
+const ApprovalSignal = "approval"
+
+// WorkflowRunWorkflow runs the nodes of a workflow in order.
+// Job nodes are child workflows of WorkflowRunJob, approval nodes wait for the ApprovalSignal.
+// The workflow stops at the first failed job or rejected approval.
+func WorkflowRunWorkflow(ctx workflow.Context, input RunWorkflowInput) error {
+	actCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: 10 * time.Second})
+
+	var err error
+	status, errMsg := models.RunSucceeded, ""
+	for _, node := range input.Nodes {
+		switch node.Kind {
+		case models.NodeApproval:
+			var approved bool
+			approved, err = awaitApproval(ctx, actCtx, input.WorkflowRunID, node)
+			if err == nil && !approved {
+				status, errMsg = models.RunRejected, fmt.Sprintf("approval of node %d rejected", node.Position)
+			}
+		case models.NodeJob:
+			err = runJobNode(ctx, actCtx, input.WorkflowRunID, node)
+		default:
+			err = fmt.Errorf("unknown node kind %q", node.Kind)
+		}
+
+		if err != nil || status != models.RunSucceeded {
+			break
+		}
+	}
+
+	switch {
+	case temporal.IsCanceledError(err):
+		status, errMsg = models.RunCancelled, "run cancelled"
+	case err != nil:
+		status, errMsg = models.RunFailed, err.Error()
+	}
+
+	finishCtx, _ := workflow.NewDisconnectedContext(actCtx)
+	if ferr := workflow.ExecuteActivity(finishCtx, acts.FinishWorkflowRun, input.WorkflowRunID, status, errMsg).Get(finishCtx, nil); ferr != nil {
+		workflow.GetLogger(ctx).Error("failed to record the end of the workflow run", "error", ferr)
+	}
+	return err
+}
+
+// awaitApproval pauses the workflow until a decision is received for the node, or until its timeout rejects it.
+func awaitApproval(ctx, actCtx workflow.Context, workflowRunID int, node models.WorkflowNode) (bool, error) {
+	var approval models.Approval
+	if err := workflow.ExecuteActivity(actCtx, acts.RequestApproval, workflowRunID, node).Get(ctx, &approval); err != nil {
+		return false, err
+	}
+
+	var decision ApprovalDecision
+	decided, expired := false, false
+
+	selector := workflow.NewSelector(ctx)
+	selector.AddReceive(workflow.GetSignalChannel(ctx, ApprovalSignal), func(c workflow.ReceiveChannel, more bool) {
+		var d ApprovalDecision
+		c.Receive(ctx, &d)
+		// Ignore decisions sent for a previous approval of the run
+		if d.ApprovalID == approval.ID {
+			decision, decided = d, true
+		}
+	})
+	if node.Timeout > 0 {
+		selector.AddFuture(workflow.NewTimer(ctx, node.Timeout), func(f workflow.Future) {
+			expired = true
+		})
+	}
+
+	for !decided && !expired {
+		selector.Select(ctx)
+		if err := ctx.Err(); err != nil {
+			return false, temporal.NewCanceledError()
+		}
+	}
+
+	if !decided {
+		decision = ApprovalDecision{ApprovalID: approval.ID, Expired: true, By: "oto", Comment: "approval timed out"}
+	}
+	if err := workflow.ExecuteActivity(actCtx, acts.DecideApproval, decision).Get(ctx, nil); err != nil {
+		return false, err
+	}
+	return decision.Approved, nil
+}
+
+// runJobNode creates the run of a job node and executes it as a child workflow.
+func runJobNode(ctx, actCtx workflow.Context, workflowRunID int, node models.WorkflowNode) error {
+	if node.Job == nil {
+		return fmt.Errorf("job node %d has no job", node.Position)
+	}
+
+	childID := fmt.Sprintf("%s-node-%d", workflow.GetInfo(ctx).WorkflowExecution.ID, node.Position)
+
+	var input RunJobInput
+	if err := workflow.ExecuteActivity(actCtx, acts.CreateJobRun, workflowRunID, node.Job.Name, childID, node.Priority).Get(ctx, &input); err != nil {
+		return err
+	}
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{WorkflowID: childID, TaskQueue: input.TaskQueue, Priority: temporalPriority(input.Priority)})
+	return workflow.ExecuteChildWorkflow(childCtx, WorkflowRunJob, input).Get(childCtx, nil)
+}
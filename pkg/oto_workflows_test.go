@@ -0,0 +1,85 @@
+package oto
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/Bl4omArchie/oto/models"
+)
+
+func newWorkflowTestEnv() *testsuite.TestWorkflowEnvironment {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	acts := &Activities{}
+	env.RegisterActivity(acts.RequestApproval)
+	env.RegisterActivity(acts.DecideApproval)
+	env.RegisterActivity(acts.FinishWorkflowRun)
+	env.RegisterActivity(acts.AcquireLeases)
+	env.RegisterActivity(acts.RunJob)
+	env.RegisterActivity(acts.FinishRun)
+	return env
+}
+
+func TestApprovalTimeoutRejectsRun(t *testing.T) {
+	env := newWorkflowTestEnv()
+
+	var decision ApprovalDecision
+	env.OnActivity("RequestApproval", mock.Anything, 1, mock.Anything).Return(&models.Approval{Status: models.ApprovalPending}, nil)
+	env.OnActivity("DecideApproval", mock.Anything, mock.Anything).Return(func(_ context.Context, d ApprovalDecision) error {
+		decision = d
+		return nil
+	})
+	env.OnActivity("FinishWorkflowRun", mock.Anything, 1, models.RunRejected, mock.Anything).Return(nil).Once()
+
+	nodes := []models.WorkflowNode{*models.NewApprovalNode(0, []string{"alice"}, time.Hour)}
+	env.ExecuteWorkflow(WorkflowRunWorkflow, RunWorkflowInput{WorkflowRunID: 1, Nodes: nodes})
+
+	if !env.IsWorkflowCompleted() || env.GetWorkflowError() != nil {
+		t.Fatalf("workflow should complete without error: %v", env.GetWorkflowError())
+	}
+	if !decision.Expired || decision.Approved {
+		t.Fatalf("approval should have expired, got %+v", decision)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestApprovalSignalResumesRun(t *testing.T) {
+	env := newWorkflowTestEnv()
+
+	env.OnActivity("RequestApproval", mock.Anything, 1, mock.Anything).Return(&models.Approval{}, nil)
+	env.OnActivity("DecideApproval", mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity("FinishWorkflowRun", mock.Anything, 1, models.RunSucceeded, "").Return(nil).Once()
+
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ApprovalSignal, ApprovalDecision{Approved: true, By: "alice"})
+	}, time.Minute)
+
+	nodes := []models.WorkflowNode{*models.NewApprovalNode(0, nil, 0)}
+	env.ExecuteWorkflow(WorkflowRunWorkflow, RunWorkflowInput{WorkflowRunID: 1, Nodes: nodes})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestConcurrencyLimitSkipsRun(t *testing.T) {
+	env := newWorkflowTestEnv()
+
+	env.OnActivity("AcquireLeases", mock.Anything, mock.Anything).Return(temporal.NewNonRetryableApplicationError("limit reached", ErrorRunSkipped, nil))
+	env.OnActivity("FinishRun", mock.Anything, 1, models.RunSkipped, "limit reached").Return(nil).Once()
+
+	env.ExecuteWorkflow(WorkflowRunJob, RunJobInput{RunID: 1, JobName: "scan"})
+
+	if env.GetWorkflowError() == nil {
+		t.Fatalf("expected the skipped run to return an error")
+	}
+	// RunJob has no expectation : the job must not be executed
+	env.AssertExpectations(t)
+}
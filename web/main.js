@@ -15,6 +15,14 @@ async function loadPage(pageFile) {
     const html = await response.text();
     pageContent.innerHTML = html;
 
+    // Scripts inserted with innerHTML don't run, replace them with new elements
+    pageContent.querySelectorAll('script').forEach(old => {
+      const script = document.createElement('script');
+      [...old.attributes].forEach(attr => script.setAttribute(attr.name, attr.value));
+      script.textContent = old.textContent;
+      old.replaceWith(script);
+    });
+
     // Update title and breadcrumb
     const title = pageFile.split('.')[0];
     pageTitle.textContent = title.charAt(0).toUpperCase() + title.slice(1);
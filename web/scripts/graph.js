@@ -0,0 +1,65 @@
+// Run each time the page is loaded : the page script is inserted again, so nothing is declared globally
+(async () => {
+  const { default: mermaid } = await import('https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs');
+
+  const API_URL = 'http://localhost:1515';
+
+  const form = document.getElementById('graphForm');
+  const executableSelect = document.getElementById('graphExecutable');
+  const commandInput = document.getElementById('graphCommand');
+  const dotLink = document.getElementById('graphDot');
+  const view = document.getElementById('graphView');
+  const errorBox = document.getElementById('graphError');
+
+  mermaid.initialize({ startOnLoad: false, securityLevel: 'strict', maxEdges: 2000 });
+
+  function graphURL(format) {
+    const tag = encodeURIComponent(executableSelect.value);
+    const params = new URLSearchParams({ format });
+    if (commandInput.value) params.set('command', commandInput.value);
+    return `${API_URL}/executables/${tag}/graph?${params}`;
+  }
+
+  // Fill the executable selector from the API
+  async function loadExecutables() {
+    try {
+      const res = await fetch(`${API_URL}/executables`);
+      const executables = await res.json();
+      executableSelect.innerHTML = '';
+      executables.forEach(exe => {
+        const option = document.createElement('option');
+        option.value = exe.Tag;
+        option.textContent = exe.Tag;
+        executableSelect.appendChild(option);
+      });
+    } catch (err) {
+      console.error('Failed to load executables:', err);
+    }
+  }
+
+  // Fetch the Mermaid graph and render it
+  async function renderGraph() {
+    errorBox.classList.add('hidden');
+    dotLink.href = graphURL('dot');
+    try {
+      const res = await fetch(graphURL('mermaid'));
+      if (!res.ok) {
+        const body = await res.json();
+        throw new Error(body.details || body.error);
+      }
+      const { svg } = await mermaid.render('parameter-graph', await res.text());
+      view.innerHTML = svg;
+    } catch (err) {
+      view.innerHTML = '';
+      errorBox.textContent = `Couldn't render the graph: ${err.message}`;
+      errorBox.classList.remove('hidden');
+    }
+  }
+
+  form.addEventListener('submit', (e) => {
+    e.preventDefault();
+    renderGraph();
+  });
+
+  loadExecutables();
+})();